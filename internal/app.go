@@ -2,12 +2,45 @@ package internal
 
 import (
 	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 func NewApp() *App {
-	return &App{}
+	a := &App{}
+	if path, err := appPrefsPath(); err == nil {
+		a.prefsPath = path
+		if prefs, err := a.GetAppPreferences(); err == nil {
+			a.SetSymlinkPolicy(prefs.SymlinkPolicy)
+		}
+	}
+	return a
 }
 
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 }
+
+// EventListener receives the events the app sends to the frontend.
+type EventListener func(name string, data ...interface{})
+
+// ListenForEvents passes the events the app emits to listen, for running
+// without Wails, as tests do. Call it before opening a vault: a vault is
+// watched for changes only if someone receives the events. It is not an
+// App method so that Wails does not bind it.
+func ListenForEvents(a *App, listen EventListener) {
+	a.listener = listen
+}
+
+// emit sends an event to the frontend, and to the listener if there is
+// one. It is a no-op when the app is not running under Wails and nobody
+// listens.
+func (a *App) emit(name string, data ...interface{}) {
+	if a.listener != nil {
+		a.listener(name, data...)
+	}
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, name, data...)
+}
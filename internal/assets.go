@@ -4,19 +4,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 func (a *App) ReadBinaryFile(relativePath string) (string, error) {
-	if a.currentVault == "" {
-		return "", fmt.Errorf("no vault opened")
-	}
-
-	fullPath := filepath.Join(a.currentVault, relativePath)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return "", fmt.Errorf("invalid path: outside vault")
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return "", err
 	}
 
 	content, err := os.ReadFile(fullPath)
@@ -28,14 +24,15 @@ func (a *App) ReadBinaryFile(relativePath string) (string, error) {
 }
 
 func (a *App) WriteBinaryFile(relativePath string, base64Data string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	fullPath := filepath.Join(a.currentVault, relativePath)
-
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
 	}
 
 	data, err := base64.StdEncoding.DecodeString(base64Data)
@@ -43,5 +40,17 @@ func (a *App) WriteBinaryFile(relativePath string, base64Data string) error {
 		return fmt.Errorf("failed to decode base64 data: %w", err)
 	}
 
-	return os.WriteFile(fullPath, data, 0644)
-}
\ No newline at end of file
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	if fw.stale() {
+		return nil
+	}
+	a.watcher.ignoreSelf(fullPath)
+	if err := writeFileAtomic(fullPath, data); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	fw.saved()
+	a.fileWritten(fullPath)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultFilePerm os.FileMode = 0644
+
+// DiskFullError is returned when a save could not complete because the
+// volume ran out of space. The original file is left untouched.
+type DiskFullError struct {
+	Path string
+	Err  error
+}
+
+func (e *DiskFullError) Error() string {
+	return fmt.Sprintf("disk full: could not save %s", filepath.Base(e.Path))
+}
+
+func (e *DiskFullError) Unwrap() error {
+	return e.Err
+}
+
+// writeFileAtomic replaces path with data so that a crash at any point leaves
+// either the old or the new content on disk, never a truncated file. The data
+// is written to a temp file in the same directory, fsynced, renamed over the
+// target and the directory is fsynced so the rename itself is durable.
+//
+// An existing file keeps its permission bits; new files get defaultFilePerm.
+// If path is a symlink the link target is replaced, not the link.
+func writeFileAtomic(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	perm := defaultFilePerm
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot overwrite directory %s", filepath.Base(path))
+		}
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return wrapWriteError(path, err)
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temp file on any failure before the rename.
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return wrapWriteError(path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return wrapWriteError(path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return wrapWriteError(path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return wrapWriteError(path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return wrapWriteError(path, err)
+	}
+	committed = true
+
+	if err := syncDir(dir); err != nil {
+		return wrapWriteError(path, err)
+	}
+
+	return nil
+}
+
+// writeNewFile creates path with data. It fails if anything exists at path,
+// a dangling symlink included, so it never writes where a link points.
+func writeNewFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return wrapWriteError(path, err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return wrapWriteError(path, err)
+}
+
+func wrapWriteError(path string, err error) error {
+	if isDiskFull(err) {
+		return &DiskFullError{Path: path, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// syncDir flushes directory metadata so a rename into dir survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package internal
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorHandleDiskFull syscall.Errno = 39
+	errorDiskFull       syscall.Errno = 112
+)
+
+// syncDir is a no-op on Windows: directories cannot be opened for fsync and
+// NTFS journals the rename performed by MoveFileEx.
+func syncDir(dir string) error {
+	return nil
+}
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, errorDiskFull) || errors.Is(err, errorHandleDiskFull)
+}
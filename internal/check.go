@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of problems reported by CheckVault.
+const (
+	IssueBrokenLink     = "broken-link"
+	IssueAmbiguousLink  = "ambiguous-link"
+	IssueUnportableName = "unportable-name"
+	IssueSymlink        = "symlink"
+)
+
+// CheckVault looks for problems in the open vault: links that resolve to
+// nothing or to one of several notes with the same name, file names that
+// are not valid on every platform, and symlinks the path policy rejects.
+func (a *App) CheckVault() ([]VaultIssue, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+	idx, err := buildLinkIndex(a.currentVault)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []VaultIssue{}
+	add := func(kind, rel string, line int, detail string) {
+		issues = append(issues, VaultIssue{Kind: kind, Path: filepath.FromSlash(rel), Line: line, Detail: detail})
+	}
+
+	byName := make(map[string][]string)
+	for _, f := range idx.files {
+		name := trimNoteExt(path.Base(f))
+		byName[name] = append(byName[name], f)
+	}
+
+	for _, f := range idx.files {
+		if reason := unportableName(f); reason != "" {
+			add(IssueUnportableName, f, 0, reason)
+		}
+
+		if _, err := resolveInVault(a.currentVault, f, a.symlinkPolicy, false, false); err != nil {
+			switch {
+			case errors.Is(err, ErrSymlinkEscape):
+				add(IssueSymlink, f, 0, "points outside the vault")
+			case errors.Is(err, ErrSymlinkDenied):
+				add(IssueSymlink, f, 0, "symlinks are not allowed")
+			case errors.Is(err, ErrSymlinkDangling):
+				add(IssueSymlink, f, 0, "points to nothing")
+			}
+		}
+
+		for _, l := range idx.outgoing[f] {
+			if resolveLink(l.target, idx.files) == "" {
+				add(IssueBrokenLink, f, l.line, l.target)
+				continue
+			}
+			if strings.Contains(l.target, "/") {
+				continue
+			}
+			name := trimNoteExt(l.target)
+			if matches := byName[name]; len(matches) > 1 && resolveLink(name, rootFiles(matches)) == "" {
+				add(IssueAmbiguousLink, f, l.line, l.target+" matches "+strings.Join(matches, ", "))
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
+
+// rootFiles returns the files directly in the vault root. A link whose
+// name matches one of them is not ambiguous.
+func rootFiles(files []string) []string {
+	var root []string
+	for _, f := range files {
+		if !strings.Contains(f, "/") {
+			root = append(root, f)
+		}
+	}
+	return root
+}
+
+// unportableName explains why a slash path cannot be used on every
+// platform, or returns "".
+func unportableName(rel string) string {
+	for _, part := range strings.Split(rel, "/") {
+		switch {
+		case strings.ContainsAny(part, `<>:"|?*\`):
+			return part + ` contains one of < > : " | ? * \`
+		case strings.HasSuffix(part, ".") || strings.HasSuffix(part, " "):
+			return part + " ends with a dot or space"
+		case isReservedName(part):
+			return part + " is reserved on Windows"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,548 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exit codes of the command-line interface.
+const (
+	ExitOK       = 0
+	ExitError    = 1
+	ExitUsage    = 2
+	ExitNoResult = 3 // search found nothing
+	ExitIssues   = 4 // check found problems
+	ExitInUse    = 5 // the vault is open elsewhere and nobody took the write
+)
+
+// vaultEnv names the environment variable that selects the vault when
+// --vault is not given. Without either, the current directory is used.
+const vaultEnv = "CHALKMD_VAULT"
+
+// Write commands run without building the vault's indexes. If the app has
+// the vault open, they are handed to it over its instance socket, so it
+// makes the change and shows it; if another process without a socket holds
+// the vault, they exit with ExitInUse.
+
+type cliCommand struct {
+	usage string
+	help  string
+	run   func(c *cliRun, args []string) error
+	// readOnly commands also work while another process has the vault open.
+	readOnly bool
+}
+
+var cliCommands = map[string]cliCommand{
+	"new": {
+		usage: "new [--content TEXT] PATH",
+		help:  "Create a note. Fails if it already exists.",
+		run:   cliNew,
+	},
+	"append": {
+		usage: "append PATH [TEXT...]",
+		help:  "Append text, or standard input, to a note, creating it if needed.",
+		run:   cliAppend,
+	},
+	"search": {
+		usage:    "search [--limit N] [--case-sensitive] [--match-diacritics] QUERY...",
+		help:     "Search note contents. Exits with 3 if nothing matches.",
+		run:      cliSearch,
+		readOnly: true,
+	},
+	"mv": {
+		usage: "mv [--no-links] OLD NEW",
+		help:  "Move or rename a file or folder, updating links to it.",
+		run:   cliMove,
+	},
+	"export": {
+		usage:    "export DEST.zip|DEST.json",
+		help:     "Export the vault as a zip archive or as JSON.",
+		run:      cliExport,
+		readOnly: true,
+	},
+	"check": {
+		usage:    "check",
+		help:     "Report broken or ambiguous links, unportable names and rejected symlinks. Exits with 4 if any are found.",
+		run:      cliCheck,
+		readOnly: true,
+	},
+}
+
+// cliRun is one subcommand invocation.
+type cliRun struct {
+	name     string
+	readOnly bool
+	stdin    io.Reader
+	stdout   io.Writer
+	app      *App
+	vault    string
+	json     bool
+	root     string // the vault's absolute path
+	instance string // socket of the instance writes are handed to, if any
+}
+
+// cliWrite is what a write command changes, resolved from its arguments so
+// that another instance can make the change.
+type cliWrite struct {
+	Command string `json:"command"`
+	Path    string `json:"path"`
+	NewPath string `json:"newPath,omitempty"`
+	Text    string `json:"text,omitempty"`
+	NoLinks bool   `json:"noLinks,omitempty"`
+}
+
+type cliWriteResult struct {
+	Path   string           `json:"path"`
+	Size   int              `json:"size"`
+	Report LinkUpdateReport `json:"report"`
+}
+
+// cliExit ends a subcommand with code. A nil err means the output already
+// says everything, as when a search finds nothing.
+type cliExit struct {
+	code int
+	err  error
+}
+
+func (e *cliExit) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func usageError(format string, args ...interface{}) error {
+	return &cliExit{code: ExitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// RunCLI runs the subcommand named by args[0], if there is one, and reports
+// whether it did; otherwise the caller starts the GUI. Note paths are
+// relative to the vault, other paths to the working directory.
+func RunCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) (code int, handled bool) {
+	if len(args) == 0 {
+		return ExitOK, false
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printCLIUsage(stdout)
+		return ExitOK, true
+	}
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		return ExitOK, false
+	}
+
+	// The headless app keeps the user's symlink policy but does not add
+	// the vault to the recent vaults, and builds indexes only on demand.
+	app := NewApp()
+	app.prefsPath = ""
+	app.headless = true
+	defer app.closeVault()
+
+	c := &cliRun{name: args[0], readOnly: cmd.readOnly, stdin: stdin, stdout: stdout, app: app}
+	err := cmd.run(c, args[1:])
+	if err == nil {
+		return ExitOK, true
+	}
+
+	code = ExitError
+	var exit *cliExit
+	if errors.As(err, &exit) {
+		code = exit.code
+		if exit.err == nil {
+			return code, true
+		}
+	}
+	if c.json {
+		json.NewEncoder(stderr).Encode(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(stderr, "chalkmd %s: %v\n", c.name, err)
+		if code == ExitUsage {
+			fmt.Fprintf(stderr, "usage: chalkmd %s\n", cmd.usage)
+		}
+	}
+	return code, true
+}
+
+func printCLIUsage(w io.Writer) {
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: chalkmd [COMMAND [--vault DIR] [--json] ARGS...]")
+	fmt.Fprintln(w, "\nWithout a command, the app starts. Commands:")
+	for _, name := range names {
+		cmd := cliCommands[name]
+		fmt.Fprintf(w, "\n  chalkmd %s\n      %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintf(w, "\nThe vault is --vault, else $%s, else the current directory.\n", vaultEnv)
+	fmt.Fprintln(w, "Write commands on a vault open in the app are made by the app.")
+	fmt.Fprintln(w, "Exit codes: 0 ok, 1 error, 2 usage, 3 no search results, 4 check found issues,")
+	fmt.Fprintln(w, "5 the vault is in use by a process that cannot take the command.")
+}
+
+// flags returns a flag set with the options every subcommand takes.
+func (c *cliRun) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("chalkmd "+c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&c.vault, "vault", "", "vault folder")
+	fs.BoolVar(&c.json, "json", false, "print results as JSON")
+	return fs
+}
+
+// parse parses args, allowing flags between positional arguments until a
+// "--", checks the number of positional arguments and opens the vault.
+// max < 0 means any number.
+func (c *cliRun) parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, usageError("help requested")
+			}
+			return nil, &cliExit{code: ExitUsage, err: err}
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	switch {
+	case len(positional) < min:
+		return nil, usageError("missing arguments")
+	case max >= 0 && len(positional) > max:
+		return nil, usageError("too many arguments")
+	}
+
+	dir := c.vault
+	if dir == "" {
+		dir = os.Getenv(vaultEnv)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	c.root = abs
+	err = c.app.OpenVault(abs)
+	if errors.Is(err, ErrVaultInUse) {
+		switch {
+		case c.readOnly:
+			err = c.app.OpenVaultReadOnly(abs)
+		default:
+			if c.instance = vaultInstance("", abs); c.instance != "" {
+				err = nil
+			} else {
+				err = &cliExit{code: ExitInUse, err: err}
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return positional, nil
+}
+
+// write makes the change of a write command, or hands it to the instance
+// that has the vault open.
+func (c *cliRun) write(w cliWrite) (cliWriteResult, error) {
+	if c.instance == "" {
+		return c.app.runCLIWrite(w)
+	}
+	reply, err := sendInstance(c.instance, ipcRequest{Type: ipcWrite, Vault: c.root, Write: &w})
+	switch {
+	case err != nil:
+		return cliWriteResult{}, fmt.Errorf("failed to reach the app that has the vault open: %w", err)
+	case !reply.Accepted:
+		return cliWriteResult{}, &cliExit{code: ExitInUse, err: ErrVaultInUse}
+	case reply.Error != "":
+		return cliWriteResult{}, errors.New(reply.Error)
+	}
+	return *reply.Write, nil
+}
+
+// runCLIWrite makes the change of a write command in the open vault and
+// reports it as the watcher would, for the window if there is one.
+func (a *App) runCLIWrite(w cliWrite) (cliWriteResult, error) {
+	switch w.Command {
+	case "new":
+		return a.cliNewNote(w.Path, w.Text)
+	case "append":
+		return a.cliAppendNote(w.Path, w.Text)
+	case "mv":
+		return a.cliMoveFile(w.Path, w.NewPath, w.NoLinks)
+	}
+	return cliWriteResult{}, fmt.Errorf("unknown command %q", w.Command)
+}
+
+// cliResolve returns the full path of a note and its path relative to the
+// vault. Write commands may run alongside the window, so it takes a.mu.
+func (a *App) cliResolve(relativePath string) (string, string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return "", "", err
+	}
+	return fullPath, a.vaultRel(fullPath), nil
+}
+
+// print writes v as JSON with --json, and runs text otherwise.
+func (c *cliRun) print(v interface{}, text func(w io.Writer)) {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+		return
+	}
+	text(c.stdout)
+}
+
+// notePath adds the .md extension CreateFile would add.
+func notePath(rel string) string {
+	if !strings.HasSuffix(rel, ".md") {
+		return rel + ".md"
+	}
+	return rel
+}
+
+func cliNew(c *cliRun, args []string) error {
+	fs := c.flags()
+	content := fs.String("content", "", "initial content")
+	args, err := c.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.write(cliWrite{Command: "new", Path: args[0], Text: *content})
+	if err != nil {
+		return err
+	}
+	c.print(map[string]string{"path": result.Path}, func(w io.Writer) {
+		fmt.Fprintln(w, result.Path)
+	})
+	return nil
+}
+
+func (a *App) cliNewNote(relativePath, content string) (cliWriteResult, error) {
+	rel := notePath(relativePath)
+	fullPath, cleanRel, err := a.cliResolve(rel)
+	if err != nil {
+		return cliWriteResult{}, err
+	}
+	if exists(fullPath) {
+		return cliWriteResult{}, fmt.Errorf("already exists: %s", rel)
+	}
+	if _, err := a.CreateFile(rel); err != nil {
+		return cliWriteResult{}, err
+	}
+	if content != "" {
+		if err := a.WriteFile(rel, content); err != nil {
+			return cliWriteResult{}, err
+		}
+	}
+
+	rel = cleanRel
+	a.emit(EventFileCreated, VaultEvent{Path: filepath.FromSlash(rel)})
+	return cliWriteResult{Path: rel}, nil
+}
+
+func cliAppend(c *cliRun, args []string) error {
+	args, err := c.parse(c.flags(), args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if len(args) > 1 {
+		text = strings.Join(args[1:], " ")
+	} else {
+		data, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read standard input: %w", err)
+		}
+		text = string(data)
+	}
+
+	result, err := c.write(cliWrite{Command: "append", Path: args[0], Text: text})
+	if err != nil {
+		return err
+	}
+	c.print(map[string]interface{}{"path": result.Path, "size": result.Size}, func(w io.Writer) {})
+	return nil
+}
+
+func (a *App) cliAppendNote(relativePath, text string) (cliWriteResult, error) {
+	rel := notePath(relativePath)
+	fullPath, cleanRel, err := a.cliResolve(rel)
+	if err != nil {
+		return cliWriteResult{}, err
+	}
+	var content string
+	event := EventFileChanged
+	if exists(fullPath) {
+		if content, err = a.ReadFile(rel); err != nil {
+			return cliWriteResult{}, err
+		}
+	} else if _, err := a.CreateFile(rel); err != nil {
+		return cliWriteResult{}, err
+	} else {
+		event = EventFileCreated
+	}
+
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += text
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		content += "\n"
+	}
+	if err := a.WriteFile(rel, content); err != nil {
+		return cliWriteResult{}, err
+	}
+
+	rel = cleanRel
+	a.emit(event, VaultEvent{Path: filepath.FromSlash(rel)})
+	return cliWriteResult{Path: rel, Size: len(content)}, nil
+}
+
+func cliSearch(c *cliRun, args []string) error {
+	fs := c.flags()
+	var opts SearchOptions
+	fs.IntVar(&opts.Limit, "limit", 0, "maximum number of notes")
+	fs.BoolVar(&opts.CaseSensitive, "case-sensitive", false, "match case")
+	fs.BoolVar(&opts.MatchDiacritics, "match-diacritics", false, "match accents")
+	args, err := c.parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	results, err := c.app.SearchVault(strings.Join(args, " "), opts)
+	if err != nil {
+		return err
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	c.print(results, func(w io.Writer) {
+		for _, r := range results {
+			for _, m := range r.Matches {
+				fmt.Fprintf(w, "%s:%d: %s\n", r.Path, m.Line, m.Snippet)
+			}
+		}
+	})
+	if len(results) == 0 {
+		return &cliExit{code: ExitNoResult}
+	}
+	return nil
+}
+
+func cliMove(c *cliRun, args []string) error {
+	fs := c.flags()
+	noLinks := fs.Bool("no-links", false, "do not update links")
+	args, err := c.parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.write(cliWrite{Command: "mv", Path: args[0], NewPath: args[1], NoLinks: *noLinks})
+	if err != nil {
+		return err
+	}
+	report := result.Report
+	c.print(report, func(w io.Writer) {
+		for _, u := range report.UpdatedFiles {
+			fmt.Fprintf(w, "%s: %d links updated\n", u.Path, u.Links)
+		}
+	})
+	return nil
+}
+
+func (a *App) cliMoveFile(oldPath, newPath string, noLinks bool) (cliWriteResult, error) {
+	report := LinkUpdateReport{UpdatedFiles: []LinkUpdate{}}
+	var err error
+	if noLinks {
+		err = a.MoveFile(oldPath, newPath)
+	} else {
+		report, err = a.MoveFileWithLinks(oldPath, newPath)
+	}
+	if err != nil {
+		return cliWriteResult{}, err
+	}
+
+	fullPath, rel, err := a.cliResolve(newPath)
+	if err != nil {
+		return cliWriteResult{}, err
+	}
+	event := VaultEvent{Path: filepath.FromSlash(rel), OldPath: filepath.Clean(filepath.FromSlash(oldPath))}
+	if info, err := os.Stat(fullPath); err == nil {
+		event.IsDir = info.IsDir()
+	}
+	a.emit(EventFileRenamed, event)
+	for _, u := range report.UpdatedFiles {
+		a.emit(EventFileChanged, VaultEvent{Path: u.Path})
+	}
+	return cliWriteResult{Report: report}, nil
+}
+
+func cliExport(c *cliRun, args []string) error {
+	args, err := c.parse(c.flags(), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	dest, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if err := c.app.ExportVault(dest); err != nil {
+		return err
+	}
+	c.print(map[string]string{"path": dest}, func(w io.Writer) {
+		fmt.Fprintln(w, dest)
+	})
+	return nil
+}
+
+func cliCheck(c *cliRun, args []string) error {
+	if _, err := c.parse(c.flags(), args, 0, 0); err != nil {
+		return err
+	}
+
+	issues, err := c.app.CheckVault()
+	if err != nil {
+		return err
+	}
+	c.print(issues, func(w io.Writer) {
+		for _, issue := range issues {
+			if issue.Line > 0 {
+				fmt.Fprintf(w, "%s:%d: %s: %s\n", issue.Path, issue.Line, issue.Kind, issue.Detail)
+			} else {
+				fmt.Fprintf(w, "%s: %s: %s\n", issue.Path, issue.Kind, issue.Detail)
+			}
+		}
+	})
+	if len(issues) > 0 {
+		return &cliExit{code: ExitIssues}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	vaultConfigFile    = "config.json"
+	vaultConfigVersion = 2
+
+	// EventConfigChanged is emitted with the new VaultConfig after it was
+	// updated.
+	EventConfigChanged = "vault:config-changed"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrConfigTooNew is returned when updating a config written by a newer
+	// chalkmd, which this version must not overwrite.
+	ErrConfigTooNew = errors.New("vault config was written by a newer version of chalkmd")
+)
+
+func defaultVaultConfig() VaultConfig {
+	return VaultConfig{
+		Version:            vaultConfigVersion,
+		ImageFolder:        "Z Pasted Images",
+		AutoSaveInterval:   100,
+		IndentSize:         4,
+		TrashMode:          TrashSystem,
+		TrashRetentionDays: defaultTrashRetentionDays,
+	}
+}
+
+// configField checks one setting, as decoded from JSON, and returns it in
+// its normalized form.
+type configField func(v interface{}) (interface{}, error)
+
+var vaultConfigSchema = map[string]configField{
+	"imageFolder": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		return cleanImageFolder(s)
+	},
+	"autoSaveInterval":   intField(10, 60000),
+	"indentSize":         intField(1, 16),
+	"trashRetentionDays": intField(0, 3650),
+	"trashMode": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		m, err := parseTrashMode(s)
+		return string(m), err
+	},
+}
+
+func intField(min, max int) configField {
+	return func(v interface{}) (interface{}, error) {
+		var n float64
+		switch v := v.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		default:
+			return nil, errors.New("must be a number")
+		}
+		if n != math.Trunc(n) || n < float64(min) || n > float64(max) {
+			return nil, fmt.Errorf("must be a whole number from %d to %d", min, max)
+		}
+		return int(n), nil
+	}
+}
+
+// cleanImageFolder normalizes a vault-relative folder to forward slashes
+// without a trailing separator. "" is the vault root.
+func cleanImageFolder(folder string) (string, error) {
+	folder = strings.ReplaceAll(folder, `\`, "/")
+	if strings.HasPrefix(folder, "/") || filepath.VolumeName(folder) != "" || (len(folder) > 1 && folder[1] == ':') {
+		return "", errors.New("must be relative to the vault")
+	}
+	folder = path.Clean(folder)
+	if folder == "." {
+		return "", nil
+	}
+	if folder == ".." || strings.HasPrefix(folder, "../") {
+		return "", errors.New("must be inside the vault")
+	}
+	if first, _, _ := strings.Cut(folder, "/"); first == configDirName {
+		return "", fmt.Errorf("cannot be inside %s", configDirName)
+	}
+	return folder, nil
+}
+
+// vaultConfigMigrations upgrade a raw config from the version it is indexed
+// by to the next one.
+var vaultConfigMigrations = map[int]func(raw map[string]interface{}){
+	// Version 1 configs carry no version number. They hold only the trash
+	// settings, or settings copied from the old app-wide settings.json,
+	// whose imageFolder used Windows separators.
+	1: func(raw map[string]interface{}) {
+		if folder, ok := raw["imageFolder"].(string); ok {
+			raw["imageFolder"] = strings.TrimRight(strings.ReplaceAll(folder, `\`, "/"), "/")
+		}
+		delete(raw, "developmentMode")
+		delete(raw, "developmentSettings")
+	},
+}
+
+func vaultConfigPath(root string) string {
+	return filepath.Join(root, configDirName, vaultConfigFile)
+}
+
+// loadVaultConfig reads the vault's config, migrating it to the current
+// version if needed; the migrated config is saved if writable is set.
+// Missing or invalid settings fall back to their defaults; an unreadable
+// file yields the defaults and is left alone.
+func loadVaultConfig(root string, writable bool) VaultConfig {
+	data, err := os.ReadFile(vaultConfigPath(root))
+	if err != nil {
+		return defaultVaultConfig()
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return defaultVaultConfig()
+	}
+
+	version := 1
+	if v, ok := raw["version"].(float64); ok && v >= 1 {
+		version = int(v)
+	}
+	migrated := version < vaultConfigVersion
+	for ; version < vaultConfigVersion; version++ {
+		if migrate, ok := vaultConfigMigrations[version]; ok {
+			migrate(raw)
+		}
+	}
+
+	for key, v := range raw {
+		check, ok := vaultConfigSchema[key]
+		if !ok {
+			delete(raw, key)
+			continue
+		}
+		if clean, err := check(v); err != nil {
+			delete(raw, key)
+		} else {
+			raw[key] = clean
+		}
+	}
+
+	cfg := defaultVaultConfig()
+	decodeConfig(raw, &cfg)
+	cfg.Version = version
+
+	if migrated && writable {
+		saveVaultConfig(root, cfg)
+	}
+	return cfg
+}
+
+// decodeConfig sets the fields of cfg named in raw.
+func decodeConfig(raw map[string]interface{}, cfg *VaultConfig) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+func saveVaultConfig(root string, cfg VaultConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(root, configDirName), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(vaultConfigPath(root), data)
+}
+
+// GetVaultConfig returns the settings of the open vault.
+func (a *App) GetVaultConfig() (VaultConfig, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return VaultConfig{}, ErrNoVault
+	}
+	return a.config, nil
+}
+
+// UpdateVaultConfig changes the settings named in patch, validating each
+// one, and saves the vault config. Nothing is changed if any setting is
+// invalid. It returns the updated config and emits EventConfigChanged.
+func (a *App) UpdateVaultConfig(patch map[string]interface{}) (VaultConfig, error) {
+	if err := a.writable(); err != nil {
+		return VaultConfig{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return VaultConfig{}, ErrNoVault
+	}
+	if a.config.Version > vaultConfigVersion {
+		return VaultConfig{}, ErrConfigTooNew
+	}
+
+	clean := make(map[string]interface{}, len(patch))
+	for key, v := range patch {
+		check, ok := vaultConfigSchema[key]
+		if !ok {
+			return VaultConfig{}, fmt.Errorf("%w: unknown setting %q", ErrInvalidConfig, key)
+		}
+		value, err := check(v)
+		if err != nil {
+			return VaultConfig{}, fmt.Errorf("%w: %s %v", ErrInvalidConfig, key, err)
+		}
+		clean[key] = value
+	}
+
+	cfg := a.config
+	if err := decodeConfig(clean, &cfg); err != nil {
+		return VaultConfig{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if err := saveVaultConfig(a.currentVault, cfg); err != nil {
+		return VaultConfig{}, fmt.Errorf("failed to save vault config: %w", err)
+	}
+	a.config = cfg
+
+	a.emit(EventConfigChanged, cfg)
+	return cfg, nil
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportedNote is one note in a JSON export.
+type exportedNote struct {
+	Path    string   `json:"path"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	Links   []string `json:"links"`
+}
+
+// ExportVault writes the open vault's files to destPath, which must be
+// outside the vault. A .zip destination gets every file, keeping folders;
+// a .json destination gets the notes with their tags and link targets.
+// Hidden files and folders, such as .chalkmd, are not exported.
+func (a *App) ExportVault(destPath string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return ErrNoVault
+	}
+	dest, err := filepath.Abs(destPath)
+	if err != nil {
+		return err
+	}
+	vault, err := filepath.Abs(a.currentVault)
+	if err != nil {
+		return err
+	}
+	if withinDir(vault, dest) {
+		return fmt.Errorf("export destination must be outside the vault: %s", destPath)
+	}
+
+	var write func(w io.Writer, files []string) error
+	switch strings.ToLower(filepath.Ext(dest)) {
+	case ".zip":
+		write = a.exportZip
+	case ".json":
+		write = a.exportJSON
+	default:
+		return fmt.Errorf("unsupported export format %q: use .zip or .json", filepath.Ext(dest))
+	}
+
+	files, err := vaultFiles(a.currentVault)
+	if err != nil {
+		return fmt.Errorf("failed to list vault: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp, files); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	return nil
+}
+
+func (a *App) exportZip(w io.Writer, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fullPath, err := a.resolvePath(f)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = f
+		header.Method = zip.Deflate
+		out, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(fullPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (a *App) exportJSON(w io.Writer, files []string) error {
+	notes := []exportedNote{}
+	for _, f := range files {
+		if !isNote(f) {
+			continue
+		}
+		fullPath, err := a.resolvePath(f)
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return err
+		}
+
+		note := exportedNote{
+			Path:    f,
+			Content: string(content),
+			Tags:    extractTags(string(content)),
+			Links:   []string{},
+		}
+		if note.Tags == nil {
+			note.Tags = []string{}
+		}
+		for _, l := range parseWikiLinks(string(content)) {
+			note.Links = append(note.Links, l.target)
+		}
+		notes = append(notes, note)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Vault string         `json:"vault"`
+		Notes []exportedNote `json:"notes"`
+	}{filepath.Base(a.currentVault), notes})
+}
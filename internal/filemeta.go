@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/gob"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Kinds of files reported in FileInfo.Kind.
+const (
+	FileKindFolder = "folder"
+	FileKindNote   = "note"
+	FileKindImage  = "image"
+	FileKindPDF    = "pdf"
+	FileKindAudio  = "audio"
+	FileKindCanvas = "canvas"
+	FileKindOther  = "other"
+)
+
+const (
+	fileMetaFile    = "file-meta.gob"
+	fileMetaVersion = 1
+)
+
+// fileKinds maps lowercase extensions to their kind and MIME type. Other
+// extensions are of kind other, with the type the OS knows, if any.
+var fileKinds = map[string]struct{ kind, mime string }{
+	"md":     {FileKindNote, "text/markdown"},
+	"png":    {FileKindImage, "image/png"},
+	"jpg":    {FileKindImage, "image/jpeg"},
+	"jpeg":   {FileKindImage, "image/jpeg"},
+	"gif":    {FileKindImage, "image/gif"},
+	"webp":   {FileKindImage, "image/webp"},
+	"svg":    {FileKindImage, "image/svg+xml"},
+	"bmp":    {FileKindImage, "image/bmp"},
+	"avif":   {FileKindImage, "image/avif"},
+	"pdf":    {FileKindPDF, "application/pdf"},
+	"mp3":    {FileKindAudio, "audio/mpeg"},
+	"wav":    {FileKindAudio, "audio/wav"},
+	"m4a":    {FileKindAudio, "audio/mp4"},
+	"ogg":    {FileKindAudio, "audio/ogg"},
+	"flac":   {FileKindAudio, "audio/flac"},
+	"webm":   {FileKindAudio, "audio/webm"},
+	"3gp":    {FileKindAudio, "audio/3gpp"},
+	"canvas": {FileKindCanvas, "application/json"},
+}
+
+// fileKind classifies a file by its extension, given without the dot.
+func fileKind(ext string) (kind, mimeType string) {
+	if k, ok := fileKinds[ext]; ok {
+		return k.kind, k.mime
+	}
+	mimeType, _, _ = strings.Cut(mime.TypeByExtension("."+ext), ";")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return FileKindOther, mimeType
+}
+
+// fileMeta is what is cached about one file: its creation time and, for
+// notes, their stats. It is valid while ModTime and Size match the file.
+type fileMeta struct {
+	ModTime int64
+	Size    int64
+	Created int64 // unix nanoseconds; 0 if unknown
+	Stats   *NoteStats
+}
+
+// fileMetaIndex caches file metadata that is costly to compute, so listing
+// the vault only re-reads notes that changed. It also keeps creation times
+// across saves: an atomic save replaces the file, and with it the creation
+// time the file system reports.
+type fileMetaIndex struct {
+	root string
+
+	mu    sync.Mutex
+	files map[string]*fileMeta // by slash path
+	dirty bool
+}
+
+type persistedFileMeta struct {
+	Version int
+	Files   map[string]*fileMeta
+}
+
+func openFileMetaIndex(root string) *fileMetaIndex {
+	idx := &fileMetaIndex{root: root, files: make(map[string]*fileMeta)}
+	data, err := os.ReadFile(idx.indexPath())
+	if err != nil {
+		return idx
+	}
+	var persisted persistedFileMeta
+	if gob.NewDecoder(bytes.NewReader(data)).Decode(&persisted) == nil && persisted.Version == fileMetaVersion && persisted.Files != nil {
+		idx.files = persisted.Files
+	}
+	return idx
+}
+
+func (idx *fileMetaIndex) indexPath() string {
+	return filepath.Join(idx.root, configDirName, fileMetaFile)
+}
+
+// save writes the cache to .chalkmd/ if it changed since the last save.
+func (idx *fileMetaIndex) save() error {
+	if idx == nil {
+		return nil
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.dirty {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(persistedFileMeta{Version: fileMetaVersion, Files: idx.files}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.indexPath()), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(idx.indexPath(), buf.Bytes()); err != nil {
+		return err
+	}
+	idx.dirty = false
+	return nil
+}
+
+// lookup returns the metadata of the file rel at fullPath, whose current
+// state is info, computing it if the cached entry is outdated. A nil index
+// caches nothing.
+func (idx *fileMetaIndex) lookup(rel, fullPath string, info os.FileInfo) fileMeta {
+	if idx == nil {
+		return computeFileMeta(fullPath, info, nil)
+	}
+	idx.mu.Lock()
+	cached := idx.files[rel]
+	idx.mu.Unlock()
+	if cached != nil && cached.ModTime == info.ModTime().UnixNano() && cached.Size == info.Size() {
+		return *cached
+	}
+
+	// The note is read without the lock, so lookups run side by side.
+	// Entries are replaced, never changed, so cached stays valid.
+	meta := computeFileMeta(fullPath, info, cached)
+	idx.mu.Lock()
+	idx.files[rel] = &meta
+	idx.dirty = true
+	idx.mu.Unlock()
+	return meta
+}
+
+// computeFileMeta reads the metadata of fullPath. The creation time of an
+// earlier entry for the path is kept if it is older.
+func computeFileMeta(fullPath string, info os.FileInfo, earlier *fileMeta) fileMeta {
+	meta := fileMeta{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+	if created := fileCreated(fullPath, info); !created.IsZero() {
+		meta.Created = created.UnixNano()
+	}
+	if earlier != nil && earlier.Created != 0 && (meta.Created == 0 || earlier.Created < meta.Created) {
+		meta.Created = earlier.Created
+	}
+	if isNote(fullPath) {
+		if content, err := os.ReadFile(fullPath); err == nil {
+			meta.Stats = noteStats(string(content))
+		}
+	}
+	return meta
+}
+
+// update refreshes the entry of a file the app just wrote, before a later
+// save replaces it and its creation time.
+func (idx *fileMetaIndex) update(rel string) {
+	if idx == nil || isHiddenPath(rel) {
+		return
+	}
+	fullPath := filepath.Join(idx.root, filepath.FromSlash(rel))
+	if info, err := os.Lstat(fullPath); err == nil && !info.IsDir() {
+		idx.lookup(rel, fullPath, info)
+	}
+}
+
+func (idx *fileMetaIndex) remove(rel string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for p := range idx.files {
+		if p == rel || strings.HasPrefix(p, rel+"/") {
+			delete(idx.files, p)
+			idx.dirty = true
+		}
+	}
+}
+
+func (idx *fileMetaIndex) rename(oldRel, newRel string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	moved := make(map[string]*fileMeta)
+	for p, meta := range idx.files {
+		if rest, ok := strings.CutPrefix(p, oldRel); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			delete(idx.files, p)
+			moved[newRel+rest] = meta
+		}
+	}
+	for p, meta := range moved {
+		idx.files[p] = meta
+		idx.dirty = true
+	}
+}
+
+// retain drops the entries of files that no longer exist.
+func (idx *fileMetaIndex) retain(present map[string]bool) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for p := range idx.files {
+		if !present[p] {
+			delete(idx.files, p)
+			idx.dirty = true
+		}
+	}
+}
+
+// noteStats counts the words (runs of letters and digits, as search
+// tokenizes them) and characters of a note's body and finds its first
+// heading.
+func noteStats(content string) *NoteStats {
+	body := parseFrontmatter(content).body
+	return &NoteStats{
+		Words:      len(tokenize(body)),
+		Characters: utf8.RuneCountInString(body),
+		Heading:    firstHeading(body),
+	}
+}
+
+// fileInfo describes a vault entry for ListVaultContents.
+func (a *App) fileInfo(rel string, info os.FileInfo) FileInfo {
+	fullPath := filepath.Join(a.currentVault, filepath.FromSlash(rel))
+	fi := FileInfo{
+		Name:     info.Name(),
+		Path:     filepath.FromSlash(rel),
+		IsDir:    info.IsDir(),
+		Modified: info.ModTime().Format(time.RFC3339),
+	}
+	if info.IsDir() {
+		fi.Kind = FileKindFolder
+		if created := fileCreated(fullPath, info); !created.IsZero() {
+			fi.Created = created.Format(time.RFC3339)
+		}
+		return fi
+	}
+
+	fi.Size = info.Size()
+	fi.Extension = strings.ToLower(strings.TrimPrefix(filepath.Ext(info.Name()), "."))
+	fi.Kind, fi.MimeType = fileKind(fi.Extension)
+	meta := a.meta.lookup(rel, fullPath, info)
+	if meta.Created != 0 {
+		fi.Created = time.Unix(0, meta.Created).Format(time.RFC3339)
+	}
+	fi.Stats = meta.Stats
+	return fi
+}
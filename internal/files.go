@@ -11,14 +11,12 @@ import (
 // read
 
 func (a *App) ReadFile(relativePath string) (string, error) {
-	if a.currentVault == "" {
-		return "", fmt.Errorf("no vault opened")
-	}
-
-	fullPath := filepath.Join(a.currentVault, relativePath)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return "", fmt.Errorf("invalid path: outside vault")
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return "", err
 	}
 
 	content, err := os.ReadFile(fullPath)
@@ -32,18 +30,22 @@ func (a *App) ReadFile(relativePath string) (string, error) {
 // create
 
 func (a *App) CreateFile(relativePath string) (string, error) {
-	if a.currentVault == "" {
-		return "", fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return "", err
 	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	fullPath := filepath.Join(a.currentVault, relativePath)
-
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return "", fmt.Errorf("invalid path: outside vault")
+	if !strings.HasSuffix(relativePath, ".md") {
+		relativePath += ".md"
+	}
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return "", err
 	}
 
-	if !strings.HasSuffix(fullPath, ".md") {
-		fullPath += ".md"
+	if _, err := os.Lstat(fullPath); err == nil {
+		return "", fmt.Errorf("failed to create file: %s already exists", relativePath)
 	}
 
 	dir := filepath.Dir(fullPath)
@@ -51,48 +53,85 @@ func (a *App) CreateFile(relativePath string) (string, error) {
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(fullPath, []byte(""), 0644); err != nil {
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	a.watcher.ignoreSelf(fullPath)
+	if err := writeNewFile(fullPath, []byte(""), defaultFilePerm); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
+	fw.saved()
+	a.fileWritten(fullPath)
+	a.journal.record(&journalEntry{kind: OpCreateFile, path: fullPath})
 
 	return fullPath, nil
 }
 
 func (a *App) CreateFolder(relativePath string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	fullPath := filepath.Join(a.currentVault, relativePath)
-
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
 	}
 
-	return os.MkdirAll(fullPath, 0755)
+	existed := exists(fullPath)
+	a.watcher.ignoreSelf(fullPath)
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return err
+	}
+	a.folderCreated(fullPath)
+	if !existed {
+		a.journal.record(&journalEntry{kind: OpCreateFolder, path: fullPath})
+	}
+	return nil
 }
 
 // write
 
+// WriteFile saves content to a note. Concurrent saves to the same note are
+// applied in the order they were called; a save overtaken by a later one
+// is dropped.
 func (a *App) WriteFile(relativePath string, content string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	fullPath := filepath.Join(a.currentVault, relativePath)
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
 
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	if fw.stale() {
+		return nil
 	}
+	return a.writeFile(fw, content)
+}
 
+// writeFile saves content to the file fw holds. The caller holds a.mu.
+func (a *App) writeFile(fw *fileWrite, content string) error {
+	fullPath := fw.path
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+	a.snapshotBeforeWrite(fullPath, []byte(content))
+	a.watcher.ignoreSelf(fullPath)
+	if err := writeFileAtomic(fullPath, []byte(content)); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	fw.saved()
+	a.fileWritten(fullPath)
+	a.stashSaved(fullPath, content, fw.since)
+	a.announceSave(fullPath)
 
 	return nil
 }
@@ -100,53 +139,107 @@ func (a *App) WriteFile(relativePath string, content string) error {
 // delete
 
 func (a *App) DeleteFile(relativePath string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.flushSavesBelow(relativePath)
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	fullPath := filepath.Join(a.currentVault, relativePath)
-
-	if !strings.HasPrefix(fullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	fullPath, err := a.resolveEntry(relativePath)
+	if err != nil {
+		return err
 	}
 
-	err := trash.Throw(fullPath)
+	entry, err := a.deletePath(fullPath)
 	if err != nil {
-		return fmt.Errorf("failed to move to trash: %w", err)
+		return err
 	}
+	a.journal.record(entry)
 
 	return nil
 }
 
+// deletePath sends fullPath to the trash selected for the vault and returns
+// a journal entry that can bring it back.
+func (a *App) deletePath(fullPath string) (*journalEntry, error) {
+	entry := &journalEntry{kind: OpDelete, path: fullPath}
+
+	a.watcher.ignoreSelf(fullPath)
+	if a.config.TrashMode == TrashVault {
+		id, err := a.moveToVaultTrash(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move to trash: %w", err)
+		}
+		entry.trashID = id
+	} else {
+		saved, ok := captureTree(fullPath)
+		if err := trash.Throw(fullPath); err != nil {
+			return nil, fmt.Errorf("failed to move to trash: %w", err)
+		}
+		entry.saved, entry.tooLarge = saved, !ok
+	}
+	a.fileRemoved(fullPath)
+	a.writeStashes(func(p string) bool { return withinDir(fullPath, p) })
+	removeStashes(a.currentVault, a.vaultRel(fullPath))
+
+	return entry, nil
+}
+
 // rename
 
 func (a *App) RenameFile(oldPath string, newPath string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.flushSavesBelow(oldPath)
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	oldFullPath := filepath.Join(a.currentVault, oldPath)
-	newFullPath := filepath.Join(a.currentVault, newPath)
+	oldFullPath, err := a.resolveEntry(oldPath)
+	if err != nil {
+		return err
+	}
+	newFullPath, err := a.resolvePath(newPath)
+	if err != nil {
+		return err
+	}
+
+	if pathTaken(newFullPath, oldFullPath) {
+		return fmt.Errorf("failed to rename: %s already exists", newPath)
+	}
 
-	if !strings.HasPrefix(oldFullPath, a.currentVault) || !strings.HasPrefix(newFullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	a.watcher.ignoreSelf(oldFullPath, newFullPath)
+	if err := os.Rename(oldFullPath, newFullPath); err != nil {
+		return err
 	}
+	a.fileRenamed(oldFullPath, newFullPath)
+	a.journal.record(&journalEntry{kind: OpRename, path: oldFullPath, newPath: newFullPath})
 
-	return os.Rename(oldFullPath, newFullPath)
+	return nil
 }
 
 // move
 
 func (a *App) MoveFile(oldPath string, newPath string) error {
-	if a.currentVault == "" {
-		return fmt.Errorf("no vault opened")
+	if err := a.writable(); err != nil {
+		return err
 	}
+	a.flushSavesBelow(oldPath)
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	oldFullPath := filepath.Join(a.currentVault, oldPath)
-	newFullPath := filepath.Join(a.currentVault, newPath)
+	oldFullPath, err := a.resolveEntry(oldPath)
+	if err != nil {
+		return err
+	}
+	newFullPath, err := a.resolvePath(newPath)
+	if err != nil {
+		return err
+	}
 
-	if !strings.HasPrefix(oldFullPath, a.currentVault) || !strings.HasPrefix(newFullPath, a.currentVault) {
-		return fmt.Errorf("invalid path: outside vault")
+	if pathTaken(newFullPath, oldFullPath) {
+		return fmt.Errorf("failed to move: %s already exists", newPath)
 	}
 
 	newDir := filepath.Dir(newFullPath)
@@ -154,5 +247,12 @@ func (a *App) MoveFile(oldPath string, newPath string) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	return os.Rename(oldFullPath, newFullPath)
+	a.watcher.ignoreSelf(oldFullPath, newFullPath)
+	if err := os.Rename(oldFullPath, newFullPath); err != nil {
+		return err
+	}
+	a.fileRenamed(oldFullPath, newFullPath)
+	a.journal.record(&journalEntry{kind: OpMove, path: oldFullPath, newPath: newFullPath})
+
+	return nil
 }
@@ -0,0 +1,17 @@
+//go:build darwin
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileCreated returns the birth time of the file.
+func fileCreated(fullPath string, info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Birthtimespec.Sec, st.Birthtimespec.Nsec)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package internal
+
+import (
+	"os"
+	"time"
+)
+
+// fileCreated is not known on this platform.
+func fileCreated(fullPath string, info os.FileInfo) time.Time {
+	return time.Time{}
+}
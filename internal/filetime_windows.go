@@ -0,0 +1,17 @@
+//go:build windows
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileCreated returns the creation time of the file.
+func fileCreated(fullPath string, info os.FileInfo) time.Time {
+	if d, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, d.CreationTime.Nanoseconds())
+	}
+	return time.Time{}
+}
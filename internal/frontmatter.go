@@ -0,0 +1,456 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Property types, matching the property kinds the editor can display.
+const (
+	PropertyText     = "text"
+	PropertyNumber   = "number"
+	PropertyDate     = "date"
+	PropertyList     = "list"
+	PropertyCheckbox = "checkbox"
+)
+
+// NoteProperty is one frontmatter key with its typed value: a string for
+// text and date, float64 for number, bool for checkbox and []string for
+// list. Empty keys have a nil value and type text.
+type NoteProperty struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+var (
+	frontmatterKeyRe = regexp.MustCompile(`^([^\s#\-][^:]*?)\s*:(?:\s+(.*?))?\s*$`)
+	dateValueRe      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2})?)?$`)
+	// Only plain decimals are numbers: strconv.ParseFloat also takes NaN,
+	// Inf and hex floats, and NaN and Inf cannot be encoded as JSON.
+	numberValueRe = regexp.MustCompile(`^[-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?$`)
+)
+
+// frontmatterEntry is a top-level key with every line that belongs to it
+// (the key line plus indented or "- " continuation lines). Comment and
+// blank lines between keys are kept as entries without a key so they
+// survive a rewrite untouched.
+type frontmatterEntry struct {
+	key   string
+	lines []string
+}
+
+// frontmatter is a note split into its YAML block and body. Only the block
+// is ever re-serialized; the body is kept byte-for-byte.
+type frontmatter struct {
+	entries []frontmatterEntry
+	newline string
+	body    string
+}
+
+func parseFrontmatter(content string) *frontmatter {
+	fm := &frontmatter{newline: "\n", body: content}
+	if strings.HasPrefix(content, "---\r\n") {
+		fm.newline = "\r\n"
+	}
+
+	block, bodyStart := frontmatterBlock(content)
+	if bodyStart == 0 {
+		return fm
+	}
+	fm.body = content[bodyStart:]
+
+	block = strings.TrimSuffix(block, "\n")
+	if block == "" {
+		return fm
+	}
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if m := frontmatterKeyRe.FindStringSubmatch(line); m != nil {
+			fm.entries = append(fm.entries, frontmatterEntry{key: m[1], lines: []string{line}})
+			continue
+		}
+		continuation := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "- ") || line == "-"
+		if continuation && len(fm.entries) > 0 && fm.entries[len(fm.entries)-1].key != "" {
+			last := &fm.entries[len(fm.entries)-1]
+			last.lines = append(last.lines, line)
+			continue
+		}
+		fm.entries = append(fm.entries, frontmatterEntry{lines: []string{line}})
+	}
+	return fm
+}
+
+func (fm *frontmatter) String() string {
+	if len(fm.entries) == 0 {
+		return fm.body
+	}
+
+	var b strings.Builder
+	b.WriteString("---" + fm.newline)
+	for _, e := range fm.entries {
+		for _, line := range e.lines {
+			b.WriteString(line + fm.newline)
+		}
+	}
+	b.WriteString("---" + fm.newline)
+	b.WriteString(fm.body)
+	return b.String()
+}
+
+func (fm *frontmatter) find(key string) int {
+	for i, e := range fm.entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (fm *frontmatter) properties() []NoteProperty {
+	props := []NoteProperty{}
+	for _, e := range fm.entries {
+		if e.key != "" {
+			props = append(props, parseProperty(e))
+		}
+	}
+	return props
+}
+
+func (fm *frontmatter) set(key string, value interface{}) error {
+	lines, err := formatProperty(key, value)
+	if err != nil {
+		return err
+	}
+	if i := fm.find(key); i >= 0 {
+		fm.entries[i].lines = lines
+		return nil
+	}
+	fm.entries = append(fm.entries, frontmatterEntry{key: key, lines: lines})
+	return nil
+}
+
+func (fm *frontmatter) delete(key string) bool {
+	i := fm.find(key)
+	if i < 0 {
+		return false
+	}
+	fm.entries = append(fm.entries[:i], fm.entries[i+1:]...)
+	return true
+}
+
+// parseProperty reads the typed value of one entry.
+func parseProperty(e frontmatterEntry) NoteProperty {
+	prop := NoteProperty{Key: e.key, Type: PropertyText}
+	m := frontmatterKeyRe.FindStringSubmatch(e.lines[0])
+	inline := stripYAMLComment(m[2])
+	rest := e.lines[1:]
+
+	switch {
+	case inline == "" && hasListItems(rest):
+		items := []string{}
+		for _, line := range rest {
+			item := strings.TrimSpace(line)
+			if strings.HasPrefix(item, "-") {
+				items = append(items, unquoteYAML(stripYAMLComment(strings.TrimSpace(item[1:]))))
+			}
+		}
+		prop.Type, prop.Value = PropertyList, items
+	case inline == "":
+		prop.Value = nil
+	case strings.HasPrefix(inline, "|") || strings.HasPrefix(inline, ">"):
+		var text []string
+		for _, line := range rest {
+			text = append(text, strings.TrimSpace(line))
+		}
+		sep := "\n"
+		if strings.HasPrefix(inline, ">") {
+			sep = " "
+		}
+		prop.Value = strings.Join(text, sep)
+	case strings.HasPrefix(inline, "[") && strings.HasSuffix(inline, "]"):
+		items := []string{}
+		for _, item := range splitFlowList(inline[1 : len(inline)-1]) {
+			items = append(items, unquoteYAML(item))
+		}
+		prop.Type, prop.Value = PropertyList, items
+	case inline == "true" || inline == "false":
+		prop.Type, prop.Value = PropertyCheckbox, inline == "true"
+	case dateValueRe.MatchString(inline):
+		prop.Type, prop.Value = PropertyDate, inline
+	default:
+		if n, err := strconv.ParseFloat(inline, 64); err == nil && numberValueRe.MatchString(inline) {
+			prop.Type, prop.Value = PropertyNumber, n
+		} else {
+			prop.Value = unquoteYAML(inline)
+		}
+	}
+	return prop
+}
+
+func hasListItems(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// stripYAMLComment removes a trailing " # comment" outside quotes.
+func stripYAMLComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func splitFlowList(s string) []string {
+	var items []string
+	var cur strings.Builder
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			cur.WriteRune(r)
+		case r == ',':
+			items = append(items, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if last := strings.TrimSpace(cur.String()); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// formatProperty renders key: value as frontmatter lines. Values come from
+// the frontend as decoded JSON.
+func formatProperty(key string, value interface{}) ([]string, error) {
+	if strings.TrimSpace(key) == "" || strings.ContainsAny(key, ":\n\r") || strings.HasPrefix(key, "#") || strings.HasPrefix(key, "-") {
+		return nil, fmt.Errorf("invalid property name %q", key)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return []string{key + ":"}, nil
+	case bool:
+		return []string{fmt.Sprintf("%s: %t", key, v)}, nil
+	case float64:
+		return []string{key + ": " + strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case int:
+		return []string{key + ": " + strconv.Itoa(v)}, nil
+	case string:
+		return []string{key + ": " + quoteYAML(v)}, nil
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return formatProperty(key, items)
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{key + ": []"}, nil
+		}
+		lines := []string{key + ":"}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				s = fmt.Sprint(item)
+			}
+			lines = append(lines, "  - "+quoteYAML(s))
+		}
+		return lines, nil
+	}
+	return nil, fmt.Errorf("unsupported value for property %q: %T", key, value)
+}
+
+// quoteYAML quotes a string only when it would otherwise be read back as
+// another type or be invalid YAML.
+func quoteYAML(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if dateValueRe.MatchString(s) {
+		return s
+	}
+	needsQuote := strings.ContainsAny(s, "\n\r\t\"") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") || s != strings.TrimSpace(s) ||
+		strings.ContainsAny(s[:1], "!&*[]{}|>'%@`,?#-\"")
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		needsQuote = true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "null", "~", "on", "off":
+		needsQuote = true
+	}
+	if needsQuote {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// frontmatterBlock returns the YAML between the leading --- fences and the
+// byte offset where the body starts. A note without frontmatter returns
+// ("", 0).
+func frontmatterBlock(content string) (string, int) {
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return "", 0
+	}
+	start := strings.IndexByte(content, '\n') + 1
+	offset := start
+	for offset < len(content) {
+		end := strings.IndexByte(content[offset:], '\n')
+		line := content[offset:]
+		next := len(content)
+		if end >= 0 {
+			line = content[offset : offset+end]
+			next = offset + end + 1
+		}
+		if strings.TrimRight(line, " \t\r") == "---" {
+			return content[start:offset], next
+		}
+		offset = next
+	}
+	return "", 0
+}
+
+// frontmatterTags returns the values of the tags property.
+func frontmatterTags(fm *frontmatter) []string {
+	i := fm.find("tags")
+	if i < 0 {
+		return nil
+	}
+	switch v := parseProperty(fm.entries[i]).Value.(type) {
+	case []string:
+		return v
+	case string:
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+func readFrontmatter(fullPath string) (*frontmatter, error) {
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return parseFrontmatter(string(content)), nil
+}
+
+// GetNoteProperties returns the frontmatter properties of a note in the
+// order they appear.
+func (a *App) GetNoteProperties(relativePath string) ([]NoteProperty, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	fm, err := readFrontmatter(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	return fm.properties(), nil
+}
+
+// SetNoteProperties adds or replaces properties. Existing keys keep their
+// position; new keys are appended in alphabetical order. Only the
+// frontmatter block is rewritten.
+func (a *App) SetNoteProperties(relativePath string, properties map[string]interface{}) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+
+	fm, err := readFrontmatter(fullPath)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fm.set(key, properties[key]); err != nil {
+			return err
+		}
+	}
+
+	return a.writeFile(fw, fm.String())
+}
+
+// DeleteNoteProperty removes one property. The frontmatter block is
+// removed entirely once nothing is left in it.
+func (a *App) DeleteNoteProperty(relativePath string, key string) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+
+	fm, err := readFrontmatter(fullPath)
+	if err != nil {
+		return err
+	}
+	if !fm.delete(key) {
+		return nil
+	}
+	return a.writeFile(fw, fm.String())
+}
@@ -0,0 +1,382 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	historyDirName      = "history"
+	historyManifestFile = "manifest.json"
+	historyVersion      = 1
+
+	// A snapshot is taken at most once per historyInterval for a note,
+	// unless a save throws away most of the note.
+	historyInterval = 5 * time.Minute
+	// Retention: versions older than historyMaxAge are dropped, and no note
+	// keeps more than historyMaxVersions.
+	historyMaxAge      = 30 * 24 * time.Hour
+	historyMaxVersions = 100
+)
+
+// historyEntry is one snapshot of a note. The content is stored once per
+// distinct hash, gzipped, under objects/.
+type historyEntry struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+	Size int64     `json:"size"`
+}
+
+type historyManifest struct {
+	Version int                       `json:"version"`
+	Notes   map[string][]historyEntry `json:"notes"`
+}
+
+// historyStore keeps past versions of notes in .chalkmd/history/. Entries
+// are keyed by slash vault path, oldest first, and follow renames.
+type historyStore struct {
+	root string
+
+	mu    sync.Mutex
+	notes map[string][]historyEntry
+	now   func() time.Time
+}
+
+// openHistoryStore loads the history of the vault at root. Versions past
+// the retention policy are pruned if writable is set.
+func openHistoryStore(root string, writable bool) *historyStore {
+	h := &historyStore{
+		root:  root,
+		notes: make(map[string][]historyEntry),
+		now:   time.Now,
+	}
+
+	data, err := os.ReadFile(h.manifestPath())
+	if err == nil {
+		var m historyManifest
+		if json.Unmarshal(data, &m) == nil && m.Version == historyVersion && m.Notes != nil {
+			h.notes = m.Notes
+		}
+	}
+
+	if !writable {
+		return h
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pruneAll() {
+		h.saveLocked()
+	}
+	return h
+}
+
+func (h *historyStore) dir() string {
+	return filepath.Join(h.root, configDirName, historyDirName)
+}
+
+func (h *historyStore) manifestPath() string {
+	return filepath.Join(h.dir(), historyManifestFile)
+}
+
+func (h *historyStore) objectPath(hash string) string {
+	return filepath.Join(h.dir(), "objects", hash[:2], hash[2:]+".gz")
+}
+
+// beforeWrite records the current content of a note that is about to be
+// replaced with next, if the throttle allows it.
+func (h *historyStore) beforeWrite(rel string, current []byte, next []byte) {
+	if h == nil || !isNote(rel) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Losing more than half of a note in one save is what an accidental
+	// select-all-delete looks like, so it always gets a snapshot.
+	drastic := len(next) < len(current)/2
+	if entries := h.notes[rel]; len(entries) > 0 && !drastic {
+		if h.now().Sub(entries[len(entries)-1].Time) < historyInterval {
+			return
+		}
+	}
+	h.recordLocked(rel, current)
+}
+
+// record stores content as a version of rel regardless of the throttle.
+func (h *historyStore) record(rel string, content []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.recordLocked(rel, content)
+}
+
+func (h *historyStore) recordLocked(rel string, content []byte) error {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	entries := h.notes[rel]
+	if len(entries) > 0 && entries[len(entries)-1].Hash == hash {
+		return nil
+	}
+
+	if err := h.writeObject(hash, content); err != nil {
+		return err
+	}
+
+	now := h.now()
+	id := now.UnixNano()
+	if len(entries) > 0 {
+		if last, err := strconv.ParseInt(entries[len(entries)-1].ID, 10, 64); err == nil && last >= id {
+			id = last + 1
+		}
+	}
+	h.notes[rel] = append(entries, historyEntry{
+		ID:   strconv.FormatInt(id, 10),
+		Time: now,
+		Hash: hash,
+		Size: int64(len(content)),
+	})
+	h.pruneAll()
+	return h.saveLocked()
+}
+
+func (h *historyStore) writeObject(hash string, content []byte) error {
+	objPath := h.objectPath(hash)
+	if _, err := os.Stat(objPath); err == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(objPath, buf.Bytes())
+}
+
+func (h *historyStore) readObject(hash string) ([]byte, error) {
+	f, err := os.Open(h.objectPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// pruneAll applies the retention policy and deletes objects no version
+// refers to any more. It reports whether anything was dropped.
+func (h *historyStore) pruneAll() bool {
+	cutoff := h.now().Add(-historyMaxAge)
+	dropped := make(map[string]bool)
+
+	for rel, entries := range h.notes {
+		keep := 0
+		for keep < len(entries) && (entries[keep].Time.Before(cutoff) || len(entries)-keep > historyMaxVersions) {
+			dropped[entries[keep].Hash] = true
+			keep++
+		}
+		if keep == 0 {
+			continue
+		}
+		if keep == len(entries) {
+			delete(h.notes, rel)
+		} else {
+			h.notes[rel] = entries[keep:]
+		}
+	}
+	if len(dropped) == 0 {
+		return false
+	}
+
+	for _, entries := range h.notes {
+		for _, e := range entries {
+			delete(dropped, e.Hash)
+		}
+	}
+	for hash := range dropped {
+		os.Remove(h.objectPath(hash))
+	}
+	return true
+}
+
+func (h *historyStore) saveLocked() error {
+	data, err := json.Marshal(historyManifest{Version: historyVersion, Notes: h.notes})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.dir(), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(h.manifestPath(), data)
+}
+
+// rename moves the history of oldRel (a note or a folder of notes) along
+// with it.
+func (h *historyStore) rename(oldRel, newRel string) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changed := false
+	for rel, entries := range h.notes {
+		var dest string
+		switch {
+		case rel == oldRel:
+			dest = newRel
+		case strings.HasPrefix(rel, oldRel+"/"):
+			dest = newRel + "/" + strings.TrimPrefix(rel, oldRel+"/")
+		default:
+			continue
+		}
+		delete(h.notes, rel)
+		merged := append(h.notes[dest], entries...)
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+		h.notes[dest] = merged
+		changed = true
+	}
+	if changed {
+		h.saveLocked()
+	}
+}
+
+func (h *historyStore) entry(rel, id string) (historyEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range h.notes[rel] {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return historyEntry{}, false
+}
+
+// snapshotBeforeWrite hands the note's current content to the history store
+// before a save replaces it.
+func (a *App) snapshotBeforeWrite(fullPath string, next []byte) {
+	if a.history == nil {
+		return
+	}
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		return
+	}
+	a.history.beforeWrite(a.vaultRel(fullPath), current, next)
+}
+
+func (a *App) historyQuery(relativePath string) (*historyStore, string, error) {
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return nil, "", err
+	}
+	h := a.history
+	if h == nil {
+		h = openHistoryStore(a.currentVault, a.writable() == nil)
+	}
+	return h, a.vaultRel(fullPath), nil
+}
+
+// ListFileVersions returns the saved versions of a note, newest first.
+func (a *App) ListFileVersions(relativePath string) ([]FileVersion, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	h, rel, err := a.historyQuery(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := h.notes[rel]
+	versions := make([]FileVersion, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		versions = append(versions, FileVersion{
+			ID:        entries[i].ID,
+			Timestamp: entries[i].Time.Format(time.RFC3339),
+			Size:      entries[i].Size,
+		})
+	}
+	return versions, nil
+}
+
+// ReadFileVersion returns the content of a note as it was at versionID.
+func (a *App) ReadFileVersion(relativePath string, versionID string) (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.readFileVersion(relativePath, versionID)
+}
+
+func (a *App) readFileVersion(relativePath string, versionID string) (string, error) {
+	h, rel, err := a.historyQuery(relativePath)
+	if err != nil {
+		return "", err
+	}
+
+	e, ok := h.entry(rel, versionID)
+	if !ok {
+		return "", fmt.Errorf("version not found: %s", versionID)
+	}
+	content, err := h.readObject(e.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read version: %w", err)
+	}
+	return string(content), nil
+}
+
+// RestoreFileVersion writes an old version back to the note. The content
+// being replaced is recorded first, so a restore can itself be undone.
+func (a *App) RestoreFileVersion(relativePath string, versionID string) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	content, err := a.readFileVersion(relativePath, versionID)
+	if err != nil {
+		return err
+	}
+
+	fullPath, _ := a.resolvePath(relativePath)
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	if current, err := os.ReadFile(fullPath); err == nil {
+		if err := a.history.record(a.vaultRel(fullPath), current); err != nil {
+			return fmt.Errorf("failed to save current version: %w", err)
+		}
+	}
+
+	return a.writeFile(fw, content)
+}
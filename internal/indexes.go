@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// The vault indexes are kept current through these hooks, called after the
+// app changes a file and when the watcher reports an external change. They
+// also tell the watcher what the app left on disk, so it does not report
+// the change back.
+
+// buildIndexes builds the indexes of the open vault side by side. The
+// caller holds a.mu exclusively.
+func (a *App) buildIndexes() {
+	root := a.currentVault
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		a.links, _ = buildLinkIndex(root)
+	}()
+	go func() {
+		defer wg.Done()
+		a.search, _ = openSearchIndex(root, a.writable() == nil)
+	}()
+	// File metadata is checked as it is read; a resync keeps what it knows.
+	if a.meta == nil || a.meta.root != root {
+		a.meta = openFileMetaIndex(root)
+	}
+	a.scanVaultTree()
+	wg.Wait()
+}
+
+// saveIndexes persists the indexes that are kept on disk.
+func (a *App) saveIndexes() {
+	a.search.save()
+	a.meta.save()
+}
+
+func (a *App) fileWritten(fullPath string) {
+	a.watcher.selfChanged(fullPath)
+	rel := a.vaultRel(fullPath)
+	a.links.update(rel)
+	a.search.update(rel)
+	a.meta.update(rel)
+	a.tree.touch(rel)
+}
+
+func (a *App) fileRemoved(fullPath string) {
+	a.watcher.selfChanged(fullPath)
+	rel := a.vaultRel(fullPath)
+	a.links.remove(rel)
+	a.search.remove(rel)
+	a.meta.remove(rel)
+	a.tree.touch(rel)
+}
+
+func (a *App) folderCreated(fullPath string) {
+	a.watcher.selfChanged(fullPath)
+	a.tree.touch(a.vaultRel(fullPath))
+}
+
+func (a *App) fileRenamed(oldFullPath, newFullPath string) {
+	a.watcher.selfChanged(oldFullPath, newFullPath)
+	oldRel, newRel := a.vaultRel(oldFullPath), a.vaultRel(newFullPath)
+	a.links.rename(oldRel, newRel)
+	a.search.rename(oldRel, newRel)
+	a.meta.rename(oldRel, newRel)
+	a.tree.touch(oldRel)
+	a.tree.touch(newRel)
+	a.history.rename(oldRel, newRel)
+	a.writeStashes(func(p string) bool { return withinDir(oldFullPath, p) })
+	renameStashes(a.currentVault, oldRel, newRel)
+}
+
+// watchedChange applies a change reported by watcher w, unless the vault
+// was closed or reopened since. It reports whether it did.
+func (a *App) watchedChange(w *vaultWatcher, name string, event VaultEvent) bool {
+	if name == EventVaultResync {
+		// rebuilding replaces the indexes
+		a.mu.Lock()
+		defer a.mu.Unlock()
+	} else {
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+	}
+	if a.watcher != w {
+		return false
+	}
+	a.applyVaultEvent(name, event)
+	return true
+}
+
+// applyVaultEvent updates the indexes for a change made outside the app.
+// The caller holds a.mu, exclusively for EventVaultResync.
+func (a *App) applyVaultEvent(name string, event VaultEvent) {
+	join := func(rel string) string {
+		return filepath.Join(a.currentVault, rel)
+	}
+
+	switch name {
+	case EventFileCreated, EventFileChanged:
+		if event.IsDir {
+			a.folderCreated(join(event.Path))
+		} else {
+			a.fileWritten(join(event.Path))
+		}
+	case EventFileDeleted:
+		a.fileRemoved(join(event.Path))
+	case EventFileRenamed:
+		a.fileRenamed(join(event.OldPath), join(event.Path))
+	case EventVaultResync:
+		a.buildIndexes()
+	}
+}
@@ -0,0 +1,372 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Instances of the app find each other through Unix sockets in a per-user
+// directory, one socket per instance. A request is one JSON line, answered
+// by one JSON line.
+
+const (
+	// EventFileSaved is emitted with a VaultEvent when another instance
+	// saved a note in the open vault.
+	EventFileSaved = "instance:file-saved"
+
+	instanceSocketExt   = ".sock"
+	instanceDialTimeout = 500 * time.Millisecond
+	instanceIOTimeout   = 2 * time.Second
+	// A handed-over CLI write may rewrite links across the vault.
+	instanceWriteTimeout = time.Minute
+)
+
+// Kinds of instance requests.
+const (
+	ipcStatus = "status" // reply with the open vault
+	ipcOpen   = "open"   // open Launch and come to the front
+	ipcSaved  = "saved"  // Path in Vault was saved
+	ipcWrite  = "write"  // run a CLI write command in Vault
+)
+
+type ipcRequest struct {
+	Type   string         `json:"type"`
+	Vault  string         `json:"vault,omitempty"`
+	Path   string         `json:"path,omitempty"`
+	Launch *LaunchRequest `json:"launch,omitempty"`
+	Write  *cliWrite      `json:"write,omitempty"`
+}
+
+type ipcReply struct {
+	PID      int             `json:"pid"`
+	Vault    string          `json:"vault"`
+	Accepted bool            `json:"accepted"`
+	Write    *cliWriteResult `json:"write,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// instanceServer answers other instances' requests for one App.
+type instanceServer struct {
+	app      *App
+	path     string
+	listener net.Listener
+
+	mu    sync.Mutex
+	vault string
+}
+
+// instancesDir returns the directory holding the instance sockets:
+// chalkmd/ under $XDG_RUNTIME_DIR, or a per-user folder in the temp dir.
+func instancesDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, appConfigDirName)
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appConfigDirName, uid))
+	}
+	return filepath.Join(os.TempDir(), appConfigDirName)
+}
+
+// ensureInstancesDir creates the socket directory, private to the user.
+func ensureInstancesDir() (string, error) {
+	dir := instancesDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a folder: %s", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// ListenForInstances lets other instances of the app reach this one, to
+// hand over launch requests, ask which vault it has open and announce
+// saves. It does nothing if the app already listens.
+func (a *App) ListenForInstances() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.instances != nil {
+		return nil
+	}
+	dir, err := ensureInstancesDir()
+	if err != nil {
+		return fmt.Errorf("failed to listen for other instances: %w", err)
+	}
+
+	// Socket paths are limited to about 100 bytes, so the name is short.
+	name := fmt.Sprintf("%d-%s%s", os.Getpid(), strconv.FormatInt(time.Now().UnixNano(), 36), instanceSocketExt)
+	path := filepath.Join(dir, name)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen for other instances: %w", err)
+	}
+
+	s := &instanceServer{app: a, path: path, listener: listener}
+	if a.currentVault != "" {
+		s.setVault(a.currentVault)
+	}
+	a.instances = s
+	go s.serve()
+	return nil
+}
+
+// Shutdown is called by Wails when the app quits. Queued saves are written
+// first.
+func (a *App) Shutdown(ctx context.Context) {
+	a.FlushSaves()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.instances.close()
+	a.instances = nil
+	a.closeVault()
+}
+
+func (s *instanceServer) close() {
+	if s == nil {
+		return
+	}
+	s.listener.Close()
+	os.Remove(s.path)
+}
+
+func (s *instanceServer) setVault(vault string) {
+	if s == nil {
+		return
+	}
+	if abs, err := filepath.Abs(vault); err == nil {
+		vault = abs
+	}
+	s.mu.Lock()
+	s.vault = vault
+	s.mu.Unlock()
+}
+
+func (s *instanceServer) openVault() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.vault
+}
+
+func (s *instanceServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *instanceServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(instanceIOTimeout))
+
+	var req ipcRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	vault := s.openVault()
+	reply := ipcReply{PID: os.Getpid(), Vault: vault}
+	switch req.Type {
+	case ipcOpen:
+		if req.Launch != nil {
+			s.app.queueLaunch(req.Launch)
+			s.app.showWindow()
+			reply.Accepted = true
+		}
+	case ipcSaved:
+		if vault != "" && samePath(req.Vault, vault) && filepath.IsLocal(filepath.FromSlash(req.Path)) {
+			event := VaultEvent{Path: req.Path}
+			s.app.mu.RLock()
+			s.app.applyVaultEvent(EventFileChanged, event)
+			s.app.mu.RUnlock()
+			s.app.emit(EventFileSaved, event)
+			reply.Accepted = true
+		}
+	case ipcWrite:
+		if vault != "" && samePath(req.Vault, vault) && req.Write != nil {
+			conn.SetDeadline(time.Now().Add(instanceWriteTimeout))
+			// The editor's pending saves go first, so the command sees
+			// them and the editor then reloads what it wrote.
+			s.app.FlushSaves()
+			result, err := s.app.runCLIWrite(*req.Write)
+			if err != nil {
+				reply.Error = err.Error()
+			} else {
+				reply.Write = &result
+			}
+			reply.Accepted = true
+		}
+	}
+	json.NewEncoder(conn).Encode(reply)
+}
+
+// showWindow brings the window to the front.
+func (a *App) showWindow() {
+	if a.ctx == nil {
+		return
+	}
+	runtime.WindowUnminimise(a.ctx)
+	runtime.WindowShow(a.ctx)
+}
+
+// instanceSockets returns the sockets of all instances except self.
+func instanceSockets(self string) []string {
+	matches, _ := filepath.Glob(filepath.Join(instancesDir(), "*"+instanceSocketExt))
+	sockets := matches[:0]
+	for _, m := range matches {
+		if m != self {
+			sockets = append(sockets, m)
+		}
+	}
+	return sockets
+}
+
+// sendInstance sends req to the instance listening on socket. A socket
+// nobody listens on is left over from a crash and is removed.
+func sendInstance(socket string, req ipcRequest) (ipcReply, error) {
+	conn, err := net.DialTimeout("unix", socket, instanceDialTimeout)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(socket)
+		}
+		return ipcReply{}, err
+	}
+	defer conn.Close()
+	timeout := instanceIOTimeout
+	if req.Type == ipcWrite {
+		timeout = instanceWriteTimeout
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return ipcReply{}, err
+	}
+	var reply ipcReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return ipcReply{}, err
+	}
+	return reply, nil
+}
+
+// sendAll sends req to every instance except self, concurrently, and
+// returns the replies of those that answered, keyed by socket.
+func sendAll(self string, req ipcRequest) map[string]ipcReply {
+	sockets := instanceSockets(self)
+	replies := make(map[string]ipcReply, len(sockets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, socket := range sockets {
+		wg.Add(1)
+		go func(socket string) {
+			defer wg.Done()
+			if reply, err := sendInstance(socket, req); err == nil {
+				mu.Lock()
+				replies[socket] = reply
+				mu.Unlock()
+			}
+		}(socket)
+	}
+	wg.Wait()
+	return replies
+}
+
+func (s *instanceServer) socketPath() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// ListInstances returns the other running instances of the app and the
+// vault each has open ("" for none).
+func (a *App) ListInstances() ([]InstanceInfo, error) {
+	a.mu.RLock()
+	self := a.instances.socketPath()
+	a.mu.RUnlock()
+
+	instances := []InstanceInfo{}
+	for _, reply := range sendAll(self, ipcRequest{Type: ipcStatus}) {
+		instances = append(instances, InstanceInfo{PID: reply.PID, Vault: reply.Vault})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].PID < instances[j].PID })
+	return instances, nil
+}
+
+// HandOffLaunch gives req to another instance that has its vault open,
+// which then opens the note and comes to the front. It reports whether one
+// took it; if not, the caller should open a window itself.
+func HandOffLaunch(req *LaunchRequest) bool {
+	return handOffLaunch("", req)
+}
+
+func handOffLaunch(self string, req *LaunchRequest) bool {
+	if req == nil {
+		return false
+	}
+	for _, socket := range instanceSockets(self) {
+		reply, err := sendInstance(socket, ipcRequest{Type: ipcStatus})
+		if err != nil || reply.Vault == "" || !samePath(reply.Vault, req.Vault) {
+			continue
+		}
+		if reply, err := sendInstance(socket, ipcRequest{Type: ipcOpen, Launch: req}); err == nil && reply.Accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultInstance returns the socket of another instance that has vault
+// open, or "" if there is none.
+func vaultInstance(self, vault string) string {
+	for socket, reply := range sendAll(self, ipcRequest{Type: ipcStatus}) {
+		if reply.Vault != "" && samePath(reply.Vault, vault) {
+			return socket
+		}
+	}
+	return ""
+}
+
+// announceSave tells the other instances that a note in the open vault was
+// saved, so those with the same vault reload it instead of overwriting it.
+// The caller holds a.mu.
+func (a *App) announceSave(fullPath string) {
+	s := a.instances
+	if s == nil {
+		return
+	}
+	req := ipcRequest{Type: ipcSaved, Vault: s.openVault(), Path: a.vaultRel(fullPath)}
+	if req.Vault == "" || strings.HasPrefix(req.Path, "../") {
+		return
+	}
+	go sendAll(s.path, req)
+}
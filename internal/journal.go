@@ -0,0 +1,588 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of file operations recorded in the journal.
+const (
+	OpCreateFile   = "create-file"
+	OpCreateFolder = "create-folder"
+	OpRename       = "rename"
+	OpMove         = "move"
+	OpDelete       = "delete"
+	OpReplace      = "replace"
+	OpRenameTag    = "rename-tag"
+)
+
+const (
+	journalMaxEntries = 100
+	// Content of items deleted to the OS trash is kept in memory so the
+	// delete can be undone; larger deletes are recorded but not undoable.
+	journalMaxCapture = 32 << 20
+	// The content all entries keep, deleted items and rewritten notes, is
+	// limited too; the oldest entries give theirs up first.
+	journalMaxHeld = 64 << 20
+)
+
+var (
+	ErrNothingToUndo     = errors.New("nothing to undo")
+	ErrNothingToRedo     = errors.New("nothing to redo")
+	ErrOperationConflict = errors.New("files changed since the operation")
+)
+
+// noteRewrite is a note whose links were rewritten by a rename or move, or
+// whose text was changed by a replace or tag rename, with its content
+// before and after.
+type noteRewrite struct {
+	fullPath      string
+	before, after []byte
+}
+
+// savedEntry is one file, folder or symlink captured before it was sent to
+// the OS trash. rel is relative to the deleted path ("." for itself).
+type savedEntry struct {
+	rel  string
+	mode os.FileMode
+	data []byte
+	link string
+}
+
+// journalEntry records one operation and what is needed to revert it.
+type journalEntry struct {
+	id      int
+	kind    string
+	time    time.Time
+	path    string // created, deleted or moved-from full path; "" for replaces and tag renames
+	newPath string // moved-to full path for renames and moves
+
+	// fingerprint describes what the operation left on disk, so undo and
+	// redo can refuse to run over later changes.
+	fingerprint string
+
+	rewrites []noteRewrite
+	trashID  string
+	saved    []savedEntry
+	tooLarge bool
+}
+
+// journal is the undo and redo history of file operations in the open
+// vault. It lives for as long as the vault is open.
+type journal struct {
+	mu     sync.Mutex
+	nextID int
+	undo   []*journalEntry
+	redo   []*journalEntry
+}
+
+func newJournal() *journal {
+	return &journal{nextID: 1}
+}
+
+// record adds a completed operation. Any undone operations can no longer be
+// redone after a new one.
+func (j *journal) record(e *journalEntry) {
+	if j == nil {
+		return
+	}
+	e.time = time.Now()
+	if e.kind != OpDelete && e.path != "" {
+		e.fingerprint = pathFingerprint(e.location())
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e.id = j.nextID
+	j.nextID++
+	j.undo = append(j.undo, e)
+	if len(j.undo) > journalMaxEntries {
+		j.undo = j.undo[len(j.undo)-journalMaxEntries:]
+	}
+	j.redo = nil
+	j.trim()
+}
+
+// held is the size of the content e keeps in memory.
+func (e *journalEntry) held() int {
+	n := 0
+	for _, s := range e.saved {
+		n += len(s.data)
+	}
+	for _, r := range e.rewrites {
+		n += len(r.before) + len(r.after)
+	}
+	return n
+}
+
+// trim keeps the content held by all entries under journalMaxHeld. Content
+// is let go from the oldest end of the undo stack only, so undo never
+// steps over a missing entry: those entries are dropped, except deletes,
+// which stay listed but can no longer be undone. The newest entry is kept.
+// The caller holds j.mu.
+func (j *journal) trim() {
+	total := 0
+	for _, e := range j.undo {
+		total += e.held()
+	}
+	for _, e := range j.redo {
+		total += e.held()
+	}
+	cut := 0
+	for ; cut < len(j.undo)-1 && total > journalMaxHeld; cut++ {
+		total -= j.undo[cut].held()
+	}
+	kept := j.undo[:0]
+	for i, e := range j.undo {
+		if i < cut {
+			if e.kind != OpDelete {
+				continue
+			}
+			e.saved, e.tooLarge = nil, true
+		}
+		kept = append(kept, e)
+	}
+	j.undo = kept
+}
+
+func (j *journal) last(redo bool) *journalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stack := j.undo
+	if redo {
+		stack = j.redo
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[len(stack)-1]
+}
+
+// shift moves e from the top of one stack to the other after it was undone
+// (or redone).
+func (j *journal) shift(e *journalEntry, undone bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	from, to := &j.undo, &j.redo
+	if !undone {
+		from, to = to, from
+	}
+	if n := len(*from); n > 0 && (*from)[n-1] == e {
+		*from = (*from)[:n-1]
+	}
+	*to = append(*to, e)
+	// redoing a delete captures its content again
+	j.trim()
+}
+
+// location is where the operation's result lives after it ran.
+func (e *journalEntry) location() string {
+	if e.newPath != "" {
+		return e.newPath
+	}
+	return e.path
+}
+
+// pathFingerprint summarizes a file or folder tree by name, size and
+// modification time. It is "" if the path does not exist.
+func pathFingerprint(fullPath string) string {
+	h := sha256.New()
+	err := filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(fullPath, p)
+		if info.IsDir() {
+			fmt.Fprintf(h, "%s/\n", filepath.ToSlash(rel))
+		} else {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func exists(fullPath string) bool {
+	_, err := os.Lstat(fullPath)
+	return err == nil
+}
+
+// captureTree reads everything below fullPath so it can be recreated. It
+// reports false if the content exceeds journalMaxCapture.
+func captureTree(fullPath string) ([]savedEntry, bool) {
+	var saved []savedEntry
+	total := 0
+	err := filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(fullPath, p)
+		entry := savedEntry{rel: rel, mode: info.Mode()}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.link, err = os.Readlink(p)
+		case info.Mode().IsRegular():
+			total += int(info.Size())
+			if total > journalMaxCapture {
+				return errors.New("too large")
+			}
+			entry.data, err = os.ReadFile(p)
+		}
+		saved = append(saved, entry)
+		return err
+	})
+	if err != nil {
+		return nil, false
+	}
+	return saved, true
+}
+
+func restoreTree(fullPath string, saved []savedEntry) error {
+	for _, s := range saved {
+		target := filepath.Join(fullPath, s.rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case s.mode.IsDir():
+			err = os.MkdirAll(target, s.mode.Perm())
+		case s.mode&os.ModeSymlink != 0:
+			err = os.Symlink(s.link, target)
+		default:
+			err = writeNewFile(target, s.data, s.mode.Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relocated maps fullPath from below one folder to below another.
+func relocated(fullPath, from, to string) string {
+	if fullPath == from {
+		return to
+	}
+	if rest, ok := strings.CutPrefix(fullPath, from+string(filepath.Separator)); ok {
+		return filepath.Join(to, rest)
+	}
+	return fullPath
+}
+
+func (a *App) operationInfo(e *journalEntry) FileOperation {
+	op := FileOperation{
+		ID:       e.id,
+		Kind:     e.kind,
+		Path:     filepath.FromSlash(a.vaultRel(e.path)),
+		Time:     e.time.Format(time.RFC3339),
+		Undoable: !e.tooLarge,
+	}
+	if e.newPath != "" {
+		op.NewPath = filepath.FromSlash(a.vaultRel(e.newPath))
+	}
+	return op
+}
+
+func (a *App) conflict(action string, e *journalEntry) error {
+	if e.path == "" {
+		return fmt.Errorf("cannot %s %s in %d notes: %w", action, e.kind, len(e.rewrites), ErrOperationConflict)
+	}
+	return fmt.Errorf("cannot %s %s of %s: %w", action, e.kind, a.vaultRel(e.path), ErrOperationConflict)
+}
+
+// checkRewrites verifies every rewritten note (at the location it has when
+// the move is in effect, or undone) still holds the expected content.
+func checkRewrites(e *journalEntry, undone bool) bool {
+	for _, r := range e.rewrites {
+		p, want := r.fullPath, r.after
+		if undone {
+			want = r.before
+			if e.newPath != "" {
+				p = relocated(p, e.newPath, e.path)
+			}
+		}
+		current, err := os.ReadFile(p)
+		if err != nil || !bytes.Equal(current, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteNotes writes the rewritten text to the notes, or with undo the
+// text from before. If a write fails, the notes already written are put
+// back, and any failure to do so is returned along with the first one.
+// Saves still queued for the notes are dropped, and the UI is told the
+// notes changed so open editors reload them rather than saving over them.
+// The caller holds a.mu exclusively.
+func (a *App) rewriteNotes(rewrites []noteRewrite, undo bool) error {
+	text := func(r noteRewrite, undo bool) []byte {
+		if undo {
+			return r.before
+		}
+		return r.after
+	}
+
+	paths := make([]string, len(rewrites))
+	for i, r := range rewrites {
+		paths[i] = r.fullPath
+	}
+	a.dropSaves(paths)
+
+	for i, r := range rewrites {
+		if err := a.writeNote(r.fullPath, text(r, undo)); err != nil {
+			errs := []error{fmt.Errorf("failed to update %s: %w", a.vaultRel(r.fullPath), err)}
+			for _, done := range rewrites[:i] {
+				if err := a.writeNote(done.fullPath, text(done, !undo)); err != nil {
+					errs = append(errs, fmt.Errorf("failed to restore %s: %w", a.vaultRel(done.fullPath), err))
+				}
+			}
+			a.notesChanged(rewrites[:i])
+			return errors.Join(errs...)
+		}
+	}
+	a.notesChanged(rewrites)
+	return nil
+}
+
+func (a *App) writeNote(fullPath string, content []byte) error {
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	return a.writeFile(fw, string(content))
+}
+
+// notesChanged reports notes the app rewrote behind the editor's back.
+func (a *App) notesChanged(rewrites []noteRewrite) {
+	for _, r := range rewrites {
+		a.emit(EventFileChanged, VaultEvent{Path: filepath.FromSlash(a.vaultRel(r.fullPath))})
+	}
+}
+
+func (a *App) undoEntry(e *journalEntry) error {
+	switch e.kind {
+	case OpCreateFile, OpCreateFolder:
+		if pathFingerprint(e.path) != e.fingerprint {
+			return a.conflict("undo", e)
+		}
+		a.watcher.ignoreSelf(e.path)
+		if err := os.Remove(e.path); err != nil {
+			return fmt.Errorf("%w: %v", a.conflict("undo", e), err)
+		}
+		a.fileRemoved(e.path)
+
+	case OpRename, OpMove:
+		if pathFingerprint(e.newPath) != e.fingerprint || pathTaken(e.path, e.newPath) || !checkRewrites(e, false) {
+			return a.conflict("undo", e)
+		}
+		// Put link text back first, while the notes are where it was
+		// written.
+		if err := a.rewriteNotes(e.rewrites, true); err != nil {
+			return fmt.Errorf("failed to update links: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		a.watcher.ignoreSelf(e.newPath, e.path)
+		if err := os.Rename(e.newPath, e.path); err != nil {
+			return err
+		}
+		a.fileRenamed(e.newPath, e.path)
+		for _, r := range e.rewrites {
+			a.fileWritten(relocated(r.fullPath, e.newPath, e.path))
+		}
+		e.fingerprint = pathFingerprint(e.path)
+
+	case OpReplace, OpRenameTag:
+		if !checkRewrites(e, false) {
+			return a.conflict("undo", e)
+		}
+		if err := a.rewriteNotes(e.rewrites, true); err != nil {
+			return err
+		}
+
+	case OpDelete:
+		if e.tooLarge {
+			return fmt.Errorf("cannot undo delete of %s: it was too large to keep", a.vaultRel(e.path))
+		}
+		if exists(e.path) {
+			return a.conflict("undo", e)
+		}
+		if err := a.stillInVault(e.path); err != nil {
+			return err
+		}
+		a.watcher.ignoreSelf(e.path)
+		if e.trashID != "" {
+			meta, err := a.readTrashMeta(e.trashID)
+			if err != nil {
+				return fmt.Errorf("%w: %v", a.conflict("undo", e), err)
+			}
+			if err := a.restoreTrashItem(e.trashID, meta, e.path); err != nil {
+				return err
+			}
+		} else {
+			if err := restoreTree(e.path, e.saved); err != nil {
+				return fmt.Errorf("failed to restore: %w", err)
+			}
+			a.treeWritten(e.path)
+		}
+		e.fingerprint = pathFingerprint(e.path)
+	}
+	return nil
+}
+
+func (a *App) redoEntry(e *journalEntry) error {
+	switch e.kind {
+	case OpCreateFile:
+		if exists(e.path) {
+			return a.conflict("redo", e)
+		}
+		if err := a.stillInVault(e.path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		a.watcher.ignoreSelf(e.path)
+		if err := writeNewFile(e.path, []byte(""), defaultFilePerm); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		a.fileWritten(e.path)
+		e.fingerprint = pathFingerprint(e.path)
+
+	case OpCreateFolder:
+		if exists(e.path) {
+			return a.conflict("redo", e)
+		}
+		if err := a.stillInVault(e.path); err != nil {
+			return err
+		}
+		a.watcher.ignoreSelf(e.path)
+		if err := os.MkdirAll(e.path, 0755); err != nil {
+			return err
+		}
+		a.folderCreated(e.path)
+		e.fingerprint = pathFingerprint(e.path)
+
+	case OpRename, OpMove:
+		if pathFingerprint(e.path) != e.fingerprint || pathTaken(e.newPath, e.path) || !checkRewrites(e, true) {
+			return a.conflict("redo", e)
+		}
+		if err := os.MkdirAll(filepath.Dir(e.newPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		a.watcher.ignoreSelf(e.path, e.newPath)
+		if err := os.Rename(e.path, e.newPath); err != nil {
+			return err
+		}
+		a.fileRenamed(e.path, e.newPath)
+		if err := a.rewriteNotes(e.rewrites, false); err != nil {
+			return err
+		}
+		e.fingerprint = pathFingerprint(e.newPath)
+
+	case OpReplace, OpRenameTag:
+		if !checkRewrites(e, true) {
+			return a.conflict("redo", e)
+		}
+		if err := a.rewriteNotes(e.rewrites, false); err != nil {
+			return err
+		}
+
+	case OpDelete:
+		if pathFingerprint(e.path) != e.fingerprint {
+			return a.conflict("redo", e)
+		}
+		deleted, err := a.deletePath(e.path)
+		if err != nil {
+			return err
+		}
+		e.trashID, e.saved, e.tooLarge = deleted.trashID, deleted.saved, deleted.tooLarge
+	}
+	return nil
+}
+
+// UndoLastFileOperation reverts the most recent create, rename, move,
+// delete, replace or tag rename. It refuses, leaving everything in place,
+// if the files involved changed since.
+func (a *App) UndoLastFileOperation() (FileOperation, error) {
+	if err := a.writable(); err != nil {
+		return FileOperation{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return FileOperation{}, ErrNoVault
+	}
+	e := a.journal.last(false)
+	if e == nil {
+		return FileOperation{}, ErrNothingToUndo
+	}
+	if err := a.undoEntry(e); err != nil {
+		return FileOperation{}, err
+	}
+	a.journal.shift(e, true)
+	return a.operationInfo(e), nil
+}
+
+// RedoFileOperation applies the most recently undone operation again.
+func (a *App) RedoFileOperation() (FileOperation, error) {
+	if err := a.writable(); err != nil {
+		return FileOperation{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return FileOperation{}, ErrNoVault
+	}
+	e := a.journal.last(true)
+	if e == nil {
+		return FileOperation{}, ErrNothingToRedo
+	}
+	if err := a.redoEntry(e); err != nil {
+		return FileOperation{}, err
+	}
+	a.journal.shift(e, false)
+	return a.operationInfo(e), nil
+}
+
+// ListRecentOperations returns the operations that can be undone, most
+// recent first.
+func (a *App) ListRecentOperations() ([]FileOperation, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+
+	ops := []FileOperation{}
+	if a.journal == nil {
+		return ops, nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	for i := len(a.journal.undo) - 1; i >= 0; i-- {
+		ops = append(ops, a.operationInfo(a.journal.undo[i]))
+	}
+	return ops, nil
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// URIScheme is the scheme of chalkmd:// links, as in
+	// chalkmd://open?vault=/path/to/vault&file=Notes/Idea.md#Heading.
+	URIScheme = "chalkmd"
+
+	// EventLaunchRequest tells the frontend that a vault or note should be
+	// opened. It carries no data; GetLaunchRequest returns the request.
+	EventLaunchRequest = "app:launch-request"
+)
+
+var ErrInvalidLaunch = errors.New("invalid launch arguments")
+
+// ParseLaunchArgs parses the arguments the GUI was started with (without
+// the program name): either --vault DIR [FILE[#HEADING]] or a single
+// chalkmd:// URI. It returns nil when there is nothing to open. FILE is
+// relative to the vault, or an absolute path inside it; a missing
+// extension means a .md note.
+func ParseLaunchArgs(args []string) (*LaunchRequest, error) {
+	// macOS adds a process serial number when launched from the Finder.
+	kept := args[:0:0]
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-psn_") {
+			kept = append(kept, arg)
+		}
+	}
+	args = kept
+
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if len(args) == 1 && strings.HasPrefix(strings.ToLower(args[0]), URIScheme+"://") {
+		return parseLaunchURI(args[0])
+	}
+
+	fs := flag.NewFlagSet("chalkmd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	vault := fs.String("vault", "", "vault folder")
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLaunch, err)
+	}
+	if *vault == "" {
+		return nil, fmt.Errorf("%w: --vault is required", ErrInvalidLaunch)
+	}
+	if fs.NArg() > 1 {
+		return nil, fmt.Errorf("%w: expected at most one file", ErrInvalidLaunch)
+	}
+	file, heading, _ := strings.Cut(fs.Arg(0), "#")
+	return newLaunchRequest(*vault, file, heading)
+}
+
+// parseLaunchURI parses chalkmd://open?vault=...&file=...[&heading=...].
+// The heading may also be given as the fragment, or after a # in file.
+func parseLaunchURI(uri string) (*LaunchRequest, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLaunch, err)
+	}
+	if u.Host != "open" {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidLaunch, u.Host)
+	}
+
+	q := u.Query()
+	file, heading, _ := strings.Cut(q.Get("file"), "#")
+	if h := q.Get("heading"); h != "" {
+		heading = h
+	} else if u.Fragment != "" {
+		heading = u.Fragment
+	}
+	vault := q.Get("vault")
+	if vault == "" {
+		return nil, fmt.Errorf("%w: missing vault", ErrInvalidLaunch)
+	}
+	if !filepath.IsAbs(vault) {
+		return nil, fmt.Errorf("%w: vault must be an absolute path", ErrInvalidLaunch)
+	}
+	return newLaunchRequest(vault, file, heading)
+}
+
+func newLaunchRequest(vault, file, heading string) (*LaunchRequest, error) {
+	vault, err := filepath.Abs(vault)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir(vault) {
+		return nil, fmt.Errorf("%w: vault is not a folder: %s", ErrInvalidLaunch, vault)
+	}
+
+	req := &LaunchRequest{Vault: vault, Heading: strings.TrimSpace(heading)}
+	if file == "" {
+		req.Heading = ""
+		return req, nil
+	}
+
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(vault, file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: file is outside the vault: %s", ErrInvalidLaunch, file)
+		}
+		file = rel
+	}
+	file = filepath.Clean(filepath.FromSlash(file))
+	if !filepath.IsLocal(file) {
+		return nil, fmt.Errorf("%w: file is outside the vault: %s", ErrInvalidLaunch, file)
+	}
+	if filepath.Ext(file) == "" {
+		file += ".md"
+	}
+	req.File = filepath.ToSlash(file)
+	return req, nil
+}
+
+// args returns the command-line arguments that launch r.
+func (r *LaunchRequest) args() []string {
+	args := []string{"--vault", r.Vault}
+	if r.File != "" {
+		file := r.File
+		if r.Heading != "" {
+			file += "#" + r.Heading
+		}
+		args = append(args, file)
+	}
+	return args
+}
+
+// NewAppWithLaunch returns an app that opens req once the frontend asks for
+// it with GetLaunchRequest. req may be nil.
+func NewAppWithLaunch(req *LaunchRequest) *App {
+	a := NewApp()
+	a.launch = req
+	return a
+}
+
+// DomReady is called by Wails once the frontend has loaded. It tells the
+// frontend about a pending launch request.
+func (a *App) DomReady(ctx context.Context) {
+	a.launchMu.Lock()
+	pending := a.launch != nil
+	a.launchMu.Unlock()
+	if pending {
+		a.emit(EventLaunchRequest)
+	}
+}
+
+// HandleLaunchURI opens a chalkmd:// URI received while the app is
+// running, as on macOS, where links are not passed as arguments.
+func (a *App) HandleLaunchURI(uri string) error {
+	req, err := parseLaunchURI(uri)
+	if err != nil {
+		return err
+	}
+	a.queueLaunch(req)
+	return nil
+}
+
+// queueLaunch replaces the pending launch request and tells the frontend.
+func (a *App) queueLaunch(req *LaunchRequest) {
+	a.launchMu.Lock()
+	a.launch = req
+	a.launchMu.Unlock()
+	a.emit(EventLaunchRequest)
+}
+
+// GetLaunchRequest returns the vault and note the app was asked to open,
+// and forgets it so that reloading the frontend does not open it again. It
+// returns nil if there is none.
+func (a *App) GetLaunchRequest() *LaunchRequest {
+	a.launchMu.Lock()
+	defer a.launchMu.Unlock()
+	req := a.launch
+	a.launch = nil
+	return req
+}
+
+// startInstance starts another chalkmd window with args.
+func startInstance(args ...string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = os.Environ()
+
+	return cmd.Start()
+}
@@ -0,0 +1,319 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const snippetWidth = 120
+
+// indexedLink is a parsed link with its position, kept per source note.
+type indexedLink struct {
+	wikiLink
+	line    int
+	context string
+}
+
+// linkIndex keeps the outgoing links of every note in the vault. Links are
+// stored unresolved and resolved at query time, so adding or removing a
+// file correctly changes what existing links point at.
+type linkIndex struct {
+	root string
+
+	mu       sync.RWMutex
+	files    []string // slash paths of all vault files, in listing order
+	outgoing map[string][]indexedLink
+}
+
+func buildLinkIndex(root string) (*linkIndex, error) {
+	files, err := vaultFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &linkIndex{
+		root:     root,
+		files:    files,
+		outgoing: make(map[string][]indexedLink),
+	}
+	var notes []string
+	for _, f := range files {
+		if isNote(f) {
+			notes = append(notes, f)
+		}
+	}
+	links := make([][]indexedLink, len(notes))
+	inParallel(len(notes), func(i int) {
+		links[i] = idx.readLinks(notes[i])
+	})
+	for i, f := range notes {
+		idx.outgoing[f] = links[i]
+	}
+	return idx, nil
+}
+
+func (idx *linkIndex) readLinks(rel string) []indexedLink {
+	content, err := os.ReadFile(filepath.Join(idx.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	return extractLinks(string(content))
+}
+
+func extractLinks(content string) []indexedLink {
+	var links []indexedLink
+	for _, l := range parseWikiLinks(content) {
+		line, text := lineAt(content, l.start)
+		col := l.start - (strings.LastIndexByte(content[:l.start], '\n') + 1)
+		links = append(links, indexedLink{
+			wikiLink: l,
+			line:     line,
+			context:  snippet(text, col, col+(l.end-l.start), snippetWidth),
+		})
+	}
+	return links
+}
+
+// update re-reads rel after it was created or written.
+func (idx *linkIndex) update(rel string) {
+	if idx == nil || isHiddenPath(rel) {
+		return
+	}
+
+	var links []indexedLink
+	if isNote(rel) {
+		links = idx.readLinks(rel)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if i, found := idx.find(rel); !found {
+		idx.files = append(idx.files, "")
+		copy(idx.files[i+1:], idx.files[i:])
+		idx.files[i] = rel
+	}
+	if isNote(rel) {
+		idx.outgoing[rel] = links
+	}
+}
+
+// remove drops rel, or everything below it if it was a folder.
+func (idx *linkIndex) remove(rel string) {
+	if idx == nil {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	kept := idx.files[:0]
+	for _, f := range idx.files {
+		if f == rel || strings.HasPrefix(f, rel+"/") {
+			delete(idx.outgoing, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	idx.files = kept
+}
+
+// rename moves oldRel (a file or folder) to newRel, keeping parsed links.
+func (idx *linkIndex) rename(oldRel, newRel string) {
+	if idx == nil {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i, f := range idx.files {
+		var dest string
+		switch {
+		case f == oldRel:
+			dest = newRel
+		case strings.HasPrefix(f, oldRel+"/"):
+			dest = newRel + "/" + strings.TrimPrefix(f, oldRel+"/")
+		default:
+			continue
+		}
+		idx.files[i] = dest
+		if links, ok := idx.outgoing[f]; ok {
+			delete(idx.outgoing, f)
+			idx.outgoing[dest] = links
+		}
+	}
+	sort.Slice(idx.files, func(i, j int) bool { return walkLess(idx.files[i], idx.files[j]) })
+}
+
+func (idx *linkIndex) find(rel string) (int, bool) {
+	i := sort.Search(len(idx.files), func(i int) bool { return !walkLess(idx.files[i], rel) })
+	return i, i < len(idx.files) && idx.files[i] == rel
+}
+
+// walkLess orders slash paths the way filepath.Walk visits them: component
+// by component, so "a/b" comes before "a-b".
+func walkLess(a, b string) bool {
+	ap, bp := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if ap[i] != bp[i] {
+			return ap[i] < bp[i]
+		}
+	}
+	return len(ap) < len(bp)
+}
+
+func (idx *linkIndex) reference(source string, l indexedLink) LinkReference {
+	return LinkReference{
+		SourcePath: filepath.FromSlash(source),
+		TargetPath: filepath.FromSlash(resolveLink(l.target, idx.files)),
+		Target:     l.target,
+		Heading:    l.heading,
+		Alias:      l.alias,
+		Embed:      l.embed,
+		Line:       l.line,
+		Context:    l.context,
+	}
+}
+
+func (idx *linkIndex) backlinks(rel string) []LinkReference {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Every resolution tier matches on the file name, so links with another
+	// name can be skipped without resolving them.
+	name := trimNoteExt(path.Base(rel))
+
+	refs := []LinkReference{}
+	for _, source := range idx.files {
+		for _, l := range idx.outgoing[source] {
+			if trimNoteExt(path.Base(l.target)) != name {
+				continue
+			}
+			if resolveLink(l.target, idx.files) == rel {
+				refs = append(refs, idx.reference(source, l))
+			}
+		}
+	}
+	return refs
+}
+
+func (idx *linkIndex) outgoingLinks(rel string) []LinkReference {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	refs := []LinkReference{}
+	for _, l := range idx.outgoing[rel] {
+		refs = append(refs, idx.reference(rel, l))
+	}
+	return refs
+}
+
+// unlinkedMentions finds plain-text occurrences of the note's name in other
+// notes, outside links and code.
+func (idx *linkIndex) unlinkedMentions(rel string) []LinkReference {
+	idx.mu.RLock()
+	sources := make([]string, 0, len(idx.outgoing))
+	for _, f := range idx.files {
+		if _, ok := idx.outgoing[f]; ok && f != rel {
+			sources = append(sources, f)
+		}
+	}
+	idx.mu.RUnlock()
+
+	name := trimNoteExt(path.Base(rel))
+	refs := []LinkReference{}
+	if strings.TrimSpace(name) == "" {
+		return refs
+	}
+	mention := regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}_])(` + regexp.QuoteMeta(name) + `)($|[^\p{L}\p{N}_])`)
+
+	for _, source := range sources {
+		raw, err := os.ReadFile(filepath.Join(idx.root, filepath.FromSlash(source)))
+		if err != nil {
+			continue
+		}
+		content := string(raw)
+
+		skip := codeRanges(content)
+		for _, l := range parseWikiLinks(content) {
+			skip = append(skip, textRange{l.start, l.end})
+		}
+		sort.Slice(skip, func(i, j int) bool { return skip[i].start < skip[j].start })
+
+		for _, m := range mention.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[4], m[5]
+			if inRanges(skip, start) {
+				continue
+			}
+			line, text := lineAt(content, start)
+			col := start - (strings.LastIndexByte(content[:start], '\n') + 1)
+			refs = append(refs, LinkReference{
+				SourcePath: filepath.FromSlash(source),
+				TargetPath: filepath.FromSlash(rel),
+				Target:     content[start:end],
+				Line:       line,
+				Context:    snippet(text, col, col+(end-start), snippetWidth),
+			})
+		}
+	}
+	return refs
+}
+
+// GetBacklinks returns every link in the vault that resolves to the note.
+func (a *App) GetBacklinks(relativePath string) ([]LinkReference, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	idx, rel, err := a.linkQuery(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return idx.backlinks(rel), nil
+}
+
+// GetOutgoingLinks returns the links written in the note, with the file
+// each one resolves to (empty TargetPath if unresolved).
+func (a *App) GetOutgoingLinks(relativePath string) ([]LinkReference, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	idx, rel, err := a.linkQuery(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return idx.outgoingLinks(rel), nil
+}
+
+// GetUnlinkedMentions returns places where other notes mention the note's
+// name without linking to it.
+func (a *App) GetUnlinkedMentions(relativePath string) ([]LinkReference, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	idx, rel, err := a.linkQuery(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return idx.unlinkedMentions(rel), nil
+}
+
+func (a *App) linkQuery(relativePath string) (*linkIndex, string, error) {
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return nil, "", err
+	}
+	idx := a.links
+	if idx == nil {
+		if idx, err = buildLinkIndex(a.currentVault); err != nil {
+			return nil, "", fmt.Errorf("failed to index links: %w", err)
+		}
+	}
+	return idx, a.vaultRel(fullPath), nil
+}
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	vaultLockFile = "lock"
+	// Changes to the lock file are made one at a time under a lock on
+	// this file; see updateLock.
+	vaultLockUpdateFile = "lock.update"
+
+	// The lock holder refreshes its heartbeat this often. A lock whose
+	// heartbeat is older than lockStaleAfter is left over from a crash.
+	lockHeartbeat  = 30 * time.Second
+	lockStaleAfter = 2 * time.Minute
+
+	// EventVaultLockLost is emitted when another process took over the
+	// vault. The app continues read-only.
+	EventVaultLockLost = "vault:lock-lost"
+)
+
+var (
+	// ErrVaultInUse is wrapped by *VaultInUseError; test with errors.Is.
+	ErrVaultInUse = errors.New("vault is in use by another process")
+	ErrReadOnly   = errors.New("vault is open read-only")
+
+	// errLockHeld and errLockUnsupported are returned by lockFile.
+	errLockHeld        = errors.New("file is locked")
+	errLockUnsupported = errors.New("file locking is not supported")
+)
+
+// VaultInUseError is returned by OpenVault when another process holds the
+// vault's lock. The vault can still be opened with OpenVaultReadOnly or
+// TakeOverVault.
+type VaultInUseError struct {
+	Path string
+	Lock VaultLockInfo
+}
+
+func (e *VaultInUseError) Error() string {
+	return fmt.Sprintf("%v: pid %d on %s since %s", ErrVaultInUse, e.Lock.PID, e.Lock.Hostname, e.Lock.Since)
+}
+
+func (e *VaultInUseError) Unwrap() error {
+	return ErrVaultInUse
+}
+
+// lockMode is how a vault is opened.
+type lockMode int
+
+const (
+	lockExclusive lockMode = iota // fail if another process holds the lock
+	lockTakeOver                  // take the lock even if it is held
+	lockNone                      // read-only, without a lock
+)
+
+// lockInfo is the content of .chalkmd/lock. Token identifies one holder,
+// so a holder notices when another process took over. Unlocked is set
+// while a holder that took over does not have the OS lock yet.
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	Started   time.Time `json:"started"`
+	Heartbeat time.Time `json:"heartbeat"`
+	Token     string    `json:"token"`
+	Unlocked  bool      `json:"unlocked,omitempty"`
+}
+
+func (l lockInfo) public() VaultLockInfo {
+	return VaultLockInfo{
+		PID:       l.PID,
+		Hostname:  l.Hostname,
+		Since:     l.Started.Format(time.RFC3339),
+		Heartbeat: l.Heartbeat.Format(time.RFC3339),
+	}
+}
+
+// vaultLock is a held vault lock. The lock file stays open, locked with
+// flock where the OS and file system support it, and its heartbeat is
+// refreshed until release.
+type vaultLock struct {
+	path string
+	file *os.File
+	lost func()
+
+	mu   sync.Mutex
+	info lockInfo
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func vaultLockPath(root string) string {
+	return filepath.Join(root, configDirName, vaultLockFile)
+}
+
+func readLockInfo(path string) (lockInfo, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockInfo{}, false
+	}
+	var info lockInfo
+	if json.Unmarshal(data, &info) != nil || info.PID == 0 {
+		return lockInfo{}, false
+	}
+	return info, true
+}
+
+// lockIsLive reports whether the holder recorded in info may still be
+// running. A holder on another host is trusted while its heartbeat is
+// fresh; on this host, its process must also exist.
+func lockIsLive(info lockInfo, now time.Time) bool {
+	if now.Sub(info.Heartbeat) > lockStaleAfter {
+		return false
+	}
+	host, _ := os.Hostname()
+	if info.Hostname == host {
+		return processExists(info.PID)
+	}
+	return true
+}
+
+// acquireVaultLock locks the vault at root. With lockTakeOver it succeeds
+// even if the vault is in use; the previous holder finds out on its next
+// heartbeat. lost is called, once, if the lock is taken over later.
+func acquireVaultLock(root string, mode lockMode, lost func()) (*vaultLock, error) {
+	path := vaultLockPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to lock vault: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, defaultFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock vault: %w", err)
+	}
+
+	now := time.Now()
+	held, hasHolder := readLockInfo(path)
+	inUse, locked := false, false
+	switch err := lockFile(f); {
+	case err == nil:
+		// Nobody on this machine holds it. flock is not always shared
+		// between hosts, and a holder that took the vault over may not
+		// have it yet, so a fresh lock from elsewhere or from such a
+		// holder still counts.
+		host, _ := os.Hostname()
+		locked = true
+		inUse = hasHolder && (held.Hostname != host || held.Unlocked) && lockIsLive(held, now)
+	case errors.Is(err, errLockHeld):
+		inUse = true
+		if !hasHolder {
+			held = lockInfo{Started: now, Heartbeat: now}
+		}
+	case errors.Is(err, errLockUnsupported):
+		inUse = hasHolder && lockIsLive(held, now)
+	default:
+		f.Close()
+		return nil, fmt.Errorf("failed to lock vault: %w", err)
+	}
+
+	if inUse && mode != lockTakeOver {
+		f.Close()
+		return nil, &VaultInUseError{Path: root, Lock: held.public()}
+	}
+
+	host, _ := os.Hostname()
+	l := &vaultLock{
+		path: path,
+		file: f,
+		info: lockInfo{
+			PID:       os.Getpid(),
+			Hostname:  host,
+			Started:   now,
+			Heartbeat: now,
+			Token:     strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(now.UnixNano(), 36),
+			Unlocked:  !locked,
+		},
+		lost: lost,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := l.update(l.write); err != nil {
+		l.file.Close()
+		return nil, fmt.Errorf("failed to lock vault: %w", err)
+	}
+	go l.beat()
+	return l, nil
+}
+
+func (l *vaultLock) current() lockInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info
+}
+
+// write stores the lock info in place; replacing the file would drop the
+// flock, which is tied to the open file.
+func (l *vaultLock) write() error {
+	data, err := json.MarshalIndent(l.current(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// update runs fn, which reads or writes the lock file, while no other
+// process changes it: a heartbeat checks that the lock is still its own
+// and writes in one step, so a take-over cannot land in between. The lock
+// file's own OS lock cannot serve, as it marks the holder.
+func (l *vaultLock) update(fn func() error) error {
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(l.path), vaultLockUpdateFile), os.O_RDWR|os.O_CREATE, defaultFilePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch err := waitLockFile(f); {
+	case err == nil:
+		defer unlockFile(f)
+	case !errors.Is(err, errLockUnsupported):
+		return err
+	}
+	return fn()
+}
+
+// owned reports whether the lock file still names this holder.
+func (l *vaultLock) owned() bool {
+	info, ok := readLockInfo(l.path)
+	return ok && info.Token == l.current().Token
+}
+
+func (l *vaultLock) beat() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			owned := true
+			l.update(func() error {
+				if owned = l.owned(); !owned {
+					return nil
+				}
+				l.mu.Lock()
+				if l.info.Unlocked {
+					// After a take-over, the OS lock is free once the
+					// previous holder closed the vault.
+					l.info.Unlocked = lockFile(l.file) != nil
+				}
+				l.info.Heartbeat = time.Now()
+				l.mu.Unlock()
+				return l.write()
+			})
+			if !owned {
+				if l.lost != nil {
+					l.lost()
+				}
+				return
+			}
+		}
+	}
+}
+
+// release stops the heartbeat and, if the lock is still ours, removes it.
+func (l *vaultLock) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+		l.update(func() error {
+			if l.owned() {
+				os.Remove(l.path)
+			}
+			return nil
+		})
+		l.file.Close()
+	})
+}
+
+// writable returns ErrReadOnly if the open vault must not be changed.
+func (a *App) writable() error {
+	if a.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// OpenVaultReadOnly opens a vault without locking it, for when another
+// process has it open. Changes to the vault are refused with ErrReadOnly.
+func (a *App) OpenVaultReadOnly(path string) error {
+	return a.openVault(path, lockNone)
+}
+
+// TakeOverVault opens a vault even if another process holds its lock. That
+// process continues read-only once it notices.
+func (a *App) TakeOverVault(path string) error {
+	return a.openVault(path, lockTakeOver)
+}
+
+// IsVaultReadOnly reports whether the open vault refuses changes, because
+// it was opened read-only or another process took it over.
+func (a *App) IsVaultReadOnly() bool {
+	return a.readOnly.Load()
+}
+
+// GetVaultLock returns who holds the lock of the vault at path, or nil if
+// it is free. It lets the UI explain a "vault in use" error.
+func (a *App) GetVaultLock(path string) (*VaultLockInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.RLock()
+	own := a.lock
+	a.mu.RUnlock()
+	if own != nil && own.path == vaultLockPath(abs) {
+		info := own.current().public()
+		return &info, nil
+	}
+	info, ok := readLockInfo(vaultLockPath(abs))
+	if !ok || !lockIsLive(info, time.Now()) {
+		return nil, nil
+	}
+	public := info.public()
+	return &public, nil
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// textRange is a half-open byte range [start, end) within a note.
+type textRange struct {
+	start, end int
+}
+
+// codeRanges returns the byte ranges of fenced code blocks and inline code
+// spans in content. Links and tags inside them are not real links or tags.
+func codeRanges(content string) []textRange {
+	var ranges []textRange
+
+	fence := ""
+	fenceStart := 0
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence == "" {
+			if marker := fenceMarker(trimmed); marker != "" {
+				fence = marker
+				fenceStart = offset
+			} else {
+				ranges = append(ranges, inlineCodeRanges(line, offset)...)
+			}
+		} else if strings.HasPrefix(strings.TrimRight(trimmed, " \t\r\n"), fence) &&
+			strings.Trim(strings.TrimSpace(trimmed), fence[:1]) == "" {
+			ranges = append(ranges, textRange{fenceStart, offset + len(line)})
+			fence = ""
+		}
+		offset += len(line)
+	}
+	if fence != "" {
+		ranges = append(ranges, textRange{fenceStart, len(content)})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	return ranges
+}
+
+// fenceMarker returns the run of backticks or tildes opening a code fence.
+func fenceMarker(line string) string {
+	for _, ch := range []string{"`", "~"} {
+		n := 0
+		for n < len(line) && line[n] == ch[0] {
+			n++
+		}
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+func inlineCodeRanges(line string, offset int) []textRange {
+	var ranges []textRange
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := 0
+		for i+n < len(line) && line[i+n] == '`' {
+			n++
+		}
+		closing := strings.Index(line[i+n:], strings.Repeat("`", n))
+		if closing < 0 {
+			break
+		}
+		end := i + n + closing + n
+		ranges = append(ranges, textRange{offset + i, offset + end})
+		i = end
+	}
+	return ranges
+}
+
+// inRanges reports whether pos falls inside any of the sorted ranges.
+func inRanges(ranges []textRange, pos int) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].end > pos })
+	return i < len(ranges) && ranges[i].start <= pos
+}
+
+// lineAt returns the 1-based line number of byte offset pos and the text of
+// that line without its line ending.
+func lineAt(content string, pos int) (int, string) {
+	line := strings.Count(content[:pos], "\n") + 1
+	start := strings.LastIndexByte(content[:pos], '\n') + 1
+	end := strings.IndexByte(content[pos:], '\n')
+	if end < 0 {
+		end = len(content)
+	} else {
+		end += pos
+	}
+	return line, strings.TrimRight(content[start:end], "\r")
+}
+
+// snippet trims a line to at most width bytes around [start, end), adding
+// ellipses where text was cut.
+func snippet(line string, start, end, width int) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	start -= len(line) - len(trimmed)
+	end -= len(line) - len(trimmed)
+	line = strings.TrimRight(trimmed, " \t")
+	if len(line) <= width {
+		return line
+	}
+	from := max(0, start-(width-(end-start))/2)
+	to := from + width
+	if to > len(line) {
+		to = len(line)
+		from = max(0, to-width)
+	}
+	for from > 0 && !utf8.RuneStart(line[from]) {
+		from--
+	}
+	for to < len(line) && !utf8.RuneStart(line[to]) {
+		to++
+	}
+	out := line[from:to]
+	if from > 0 {
+		out = "…" + out
+	}
+	if to < len(line) {
+		out += "…"
+	}
+	return out
+}
+
+// isNote reports whether a vault path is a markdown note.
+func isNote(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".md")
+}
+
+var headingRe = regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*\r?$`)
+
+// firstHeading returns the text of the first ATX heading outside code.
+func firstHeading(content string) string {
+	code := codeRanges(content)
+	for _, m := range headingRe.FindAllStringSubmatchIndex(content, -1) {
+		if !inRanges(code, m[0]) {
+			return content[m[2]:m[3]]
+		}
+	}
+	return ""
+}
+
+// inlineTagRe matches #tag and #nested/tag. A tag needs at least one
+// non-digit so "#123" (an issue number) is not a tag.
+var inlineTagRe = regexp.MustCompile(`(?:^|[\s(,;])#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// extractTags returns the distinct lowercase tags of a note, from inline
+// #tags outside code and the frontmatter tags list.
+func extractTags(content string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	add := func(tag string) {
+		tag = strings.Trim(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#")), "/")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	fm := parseFrontmatter(content)
+	for _, tag := range frontmatterTags(fm) {
+		add(tag)
+	}
+	bodyStart := len(content) - len(fm.body)
+
+	code := codeRanges(content)
+	for _, m := range inlineTagRe.FindAllStringSubmatchIndex(content, -1) {
+		if m[2] < bodyStart || inRanges(code, m[2]) {
+			continue
+		}
+		add(content[m[2]:m[3]])
+	}
+	return tags
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Built-in vault templates, one folder each. Empty folders hold a .keep
+// file, which is not copied.
+//
+//go:embed all:templates
+var vaultTemplates embed.FS
+
+const (
+	templatesDir     = "templates"
+	templateKeepFile = ".keep"
+	// TemplateEmpty creates a vault with nothing but its config.
+	TemplateEmpty = "empty"
+)
+
+var (
+	ErrInvalidVaultName = errors.New("invalid vault name")
+	ErrVaultNotEmpty    = errors.New("folder already exists and is not empty")
+	ErrUnknownTemplate  = errors.New("unknown vault template")
+)
+
+// ListVaultTemplates returns the names of the built-in vault templates.
+func (a *App) ListVaultTemplates() []string {
+	names := []string{TemplateEmpty}
+	entries, _ := vaultTemplates.ReadDir(templatesDir)
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// validateVaultName checks that name can be used as a folder name on every
+// platform chalkmd runs on.
+func validateVaultName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %q %s", ErrInvalidVaultName, name, reason)
+	}
+
+	switch {
+	case strings.TrimSpace(name) == "":
+		return invalid("is empty")
+	case name == "." || name == "..":
+		return invalid("is not a folder name")
+	case len(name) > 255:
+		return invalid("is too long")
+	case strings.ContainsAny(name, `/\<>:"|?*`):
+		return invalid(`cannot contain / \ < > : " | ? *`)
+	case strings.HasSuffix(name, "."):
+		return invalid("cannot end with a dot")
+	case strings.HasPrefix(name, "."):
+		return invalid("cannot be hidden")
+	case isReservedName(name):
+		return invalid("is reserved on Windows")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return invalid("contains control characters")
+		}
+	}
+	return nil
+}
+
+// CreateVault creates the folder name under parentPath as a new vault,
+// seeds it from template and opens it. template is "" or "empty", the name
+// of a built-in template, or the absolute path of an existing vault whose
+// notes, folders and config are copied. An existing folder is only used if
+// it is empty. It returns the new vault's path.
+func (a *App) CreateVault(parentPath string, name string, template string) (string, error) {
+	name = strings.TrimSpace(name)
+	if err := validateVaultName(name); err != nil {
+		return "", err
+	}
+	if !isDir(parentPath) {
+		return "", fmt.Errorf("vault location is not a folder: %s", parentPath)
+	}
+
+	seed, err := vaultSeed(template)
+	if err != nil {
+		return "", err
+	}
+
+	vaultPath := filepath.Join(parentPath, name)
+	if filepath.IsAbs(template) && withinDir(template, vaultPath) {
+		return "", fmt.Errorf("cannot create a vault inside its template: %s", template)
+	}
+
+	created := false
+	entries, err := os.ReadDir(vaultPath)
+	switch {
+	case os.IsNotExist(err):
+		if err := os.Mkdir(vaultPath, 0755); err != nil {
+			return "", fmt.Errorf("failed to create vault: %w", err)
+		}
+		created = true
+	case err != nil:
+		return "", fmt.Errorf("failed to create vault: %w", err)
+	case len(entries) > 0:
+		return "", fmt.Errorf("%w: %s", ErrVaultNotEmpty, vaultPath)
+	}
+
+	if err := seed(vaultPath); err != nil {
+		if created {
+			os.RemoveAll(vaultPath)
+		}
+		return "", fmt.Errorf("failed to create vault: %w", err)
+	}
+
+	if err := a.OpenVault(vaultPath); err != nil {
+		return "", err
+	}
+	return vaultPath, nil
+}
+
+// vaultSeed returns a function that fills a new, empty vault folder.
+func vaultSeed(template string) (func(vaultPath string) error, error) {
+	switch {
+	case template == "" || template == TemplateEmpty:
+		return func(vaultPath string) error {
+			return saveVaultConfig(vaultPath, defaultVaultConfig())
+		}, nil
+
+	case filepath.IsAbs(template):
+		if !isDir(template) {
+			return nil, fmt.Errorf("%w: %s is not a folder", ErrUnknownTemplate, template)
+		}
+		return func(vaultPath string) error {
+			if err := copyVaultContents(template, vaultPath); err != nil {
+				return err
+			}
+			return saveVaultConfig(vaultPath, loadVaultConfig(template, false))
+		}, nil
+	}
+
+	if strings.ContainsAny(template, `/\.`) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTemplate, template)
+	}
+	root := path.Join(templatesDir, template)
+	if _, err := fs.Stat(vaultTemplates, root); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTemplate, template)
+	}
+	return func(vaultPath string) error {
+		if err := copyTemplate(root, vaultPath); err != nil {
+			return err
+		}
+		return saveVaultConfig(vaultPath, defaultVaultConfig())
+	}, nil
+}
+
+func copyTemplate(root, vaultPath string) error {
+	return fs.WalkDir(vaultTemplates, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		target := filepath.Join(vaultPath, filepath.FromSlash(rel))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if d.Name() == templateKeepFile {
+			return nil
+		}
+		data, err := vaultTemplates.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, defaultFilePerm)
+	})
+}
+
+// copyVaultContents copies the notes and folders of another vault. Hidden
+// entries, including its .chalkmd data and trash, are left out, and so are
+// symlinks.
+func copyVaultContents(source, vaultPath string) error {
+	return filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(source, p)
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(vaultPath, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(source, target string, perm os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Errors returned when a vault-relative path is rejected. They are wrapped
+// in a *PathError; use errors.Is to test for them.
+var (
+	ErrNoVault         = errors.New("no vault opened")
+	ErrOutsideVault    = errors.New("invalid path: outside vault")
+	ErrSymlinkEscape   = errors.New("invalid path: symlink points outside vault")
+	ErrSymlinkDenied   = errors.New("invalid path: symlinks are not allowed")
+	ErrSymlinkDangling = errors.New("invalid path: symlink points to nothing")
+	ErrReservedName    = errors.New("invalid path: reserved name")
+	ErrVaultRoot       = errors.New("invalid path: vault root")
+	ErrInvalidPath     = errors.New("invalid path")
+	errUnknownSymlink  = errors.New("unknown symlink policy")
+)
+
+// configDirName is the hidden per-vault directory owned by chalkmd.
+const configDirName = ".chalkmd"
+
+// SymlinkPolicy controls how symlinks inside the vault are followed.
+type SymlinkPolicy string
+
+const (
+	// SymlinkWithinVault follows symlinks only if they resolve inside the
+	// vault. This is the default.
+	SymlinkWithinVault SymlinkPolicy = "within-vault"
+	// SymlinkDeny rejects any path that goes through a symlink.
+	SymlinkDeny SymlinkPolicy = "deny"
+	// SymlinkAllow follows symlinks wherever they point.
+	SymlinkAllow SymlinkPolicy = "allow"
+)
+
+// PathError reports a vault-relative path that was rejected and why.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%v: %q", e.Err, e.Path)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// windowsReserved are device names that cannot be used as file names on
+// Windows, with or without an extension. They are rejected everywhere so a
+// vault stays portable.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func parseSymlinkPolicy(policy string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(policy); p {
+	case SymlinkWithinVault, SymlinkDeny, SymlinkAllow:
+		return p, nil
+	case "":
+		return SymlinkWithinVault, nil
+	}
+	return "", fmt.Errorf("%w: %q", errUnknownSymlink, policy)
+}
+
+// SetSymlinkPolicy sets how paths through symlinks are treated:
+// "within-vault" (default), "deny" or "allow".
+func (a *App) SetSymlinkPolicy(policy string) error {
+	p, err := parseSymlinkPolicy(policy)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.symlinkPolicy = p
+	a.mu.Unlock()
+	return nil
+}
+
+// resolvePath maps a vault-relative path to an absolute path inside the open
+// vault. The vault root itself is rejected so callers cannot delete or
+// rename it by passing an empty path.
+func (a *App) resolvePath(relativePath string) (string, error) {
+	if a.currentVault == "" {
+		return "", ErrNoVault
+	}
+	return resolveInVault(a.currentVault, relativePath, a.symlinkPolicy, false, false)
+}
+
+// resolveEntry is resolvePath for operations that act on a directory entry
+// itself rather than on what a symlink points to, such as rename and
+// delete. A dangling symlink is accepted as the final component.
+func (a *App) resolveEntry(relativePath string) (string, error) {
+	if a.currentVault == "" {
+		return "", ErrNoVault
+	}
+	return resolveInVault(a.currentVault, relativePath, a.symlinkPolicy, false, true)
+}
+
+// resolveDir is resolvePath for operations that may target the vault root,
+// such as listing it.
+func (a *App) resolveDir(relativePath string) (string, error) {
+	if a.currentVault == "" {
+		return "", ErrNoVault
+	}
+	return resolveInVault(a.currentVault, relativePath, a.symlinkPolicy, true, false)
+}
+
+// stillInVault checks a full path recorded earlier, such as by the journal,
+// against the symlinks in the vault now, before the app writes to it.
+func (a *App) stillInVault(fullPath string) error {
+	rel, err := filepath.Rel(a.currentVault, fullPath)
+	if err != nil {
+		return &PathError{Path: fullPath, Err: ErrOutsideVault}
+	}
+	_, err = resolveInVault(a.currentVault, rel, a.symlinkPolicy, false, false)
+	return err
+}
+
+func resolveInVault(root, relativePath string, policy SymlinkPolicy, allowRoot, entry bool) (string, error) {
+	reject := func(err error) (string, error) {
+		return "", &PathError{Path: relativePath, Err: err}
+	}
+
+	if strings.ContainsRune(relativePath, 0) || filepath.VolumeName(relativePath) != "" {
+		return reject(ErrInvalidPath)
+	}
+
+	root = filepath.Clean(root)
+	fullPath := filepath.Join(root, relativePath)
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return reject(ErrOutsideVault)
+	}
+	if rel == "." {
+		if allowRoot {
+			return fullPath, nil
+		}
+		return reject(ErrVaultRoot)
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	if strings.EqualFold(parts[0], configDirName) {
+		return reject(ErrReservedName)
+	}
+	for _, part := range parts {
+		if isReservedName(part) {
+			return reject(ErrReservedName)
+		}
+	}
+
+	if err := checkSymlinks(root, rel, policy, entry); err != nil {
+		return reject(err)
+	}
+
+	return fullPath, nil
+}
+
+func isReservedName(name string) bool {
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	return windowsReserved[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// checkSymlinks applies policy to the existing part of root/rel. Components
+// that do not exist yet cannot be symlinks, so only the deepest existing
+// ancestor is resolved. A dangling symlink is rejected: writing through it
+// would create its target wherever it points. As the final component it is
+// accepted only if entry is set, for operations on the link itself.
+func checkSymlinks(root, rel string, policy SymlinkPolicy, entry bool) error {
+	if policy == SymlinkAllow {
+		return nil
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	existing := rel
+	var real string
+	for {
+		candidate := filepath.Join(root, existing)
+		if resolved, err := filepath.EvalSymlinks(candidate); err == nil {
+			real = resolved
+			break
+		}
+		if info, err := os.Lstat(candidate); err == nil && info.Mode()&os.ModeSymlink != 0 && !(entry && existing == rel) {
+			return ErrSymlinkDangling
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing || existing == "." {
+			return nil
+		}
+		existing = parent
+	}
+
+	expected := filepath.Join(realRoot, existing)
+	if real == expected {
+		return nil
+	}
+
+	if policy == SymlinkDeny {
+		return ErrSymlinkDenied
+	}
+
+	if !withinDir(realRoot, real) {
+		return ErrSymlinkEscape
+	}
+	return nil
+}
+
+// withinDir reports whether path is dir or lies below it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// pathTaken reports whether target exists as an entry other than self. On
+// case-insensitive file systems the target of a case-only rename, such as
+// note.md to Note.md, is the entry being renamed.
+func pathTaken(target, self string) bool {
+	info, err := os.Lstat(target)
+	if err != nil {
+		return false
+	}
+	selfInfo, err := os.Lstat(self)
+	return err != nil || !strings.EqualFold(target, self) || !os.SameFile(info, selfInfo)
+}
+
+// vaultRel returns fullPath relative to the vault root, slash-separated, as
+// used for link resolution.
+func (a *App) vaultRel(fullPath string) string {
+	rel, err := filepath.Rel(a.currentVault, fullPath)
+	if err != nil {
+		return filepath.ToSlash(fullPath)
+	}
+	return filepath.ToSlash(rel)
+}
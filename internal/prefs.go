@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	appConfigDirName       = "chalkmd"
+	appPrefsFile           = "preferences.json"
+	appPrefsVersion        = 1
+	defaultMaxRecentVaults = 20
+)
+
+var errNoAppConfig = errors.New("app preferences are not available")
+
+// appState is the app-level preferences file, shared by every instance. It
+// is re-read before each change so instances do not undo each other's.
+type appState struct {
+	Version      int            `json:"version"`
+	RecentVaults []recentVault  `json:"recentVaults"`
+	Preferences  AppPreferences `json:"preferences"`
+}
+
+type recentVault struct {
+	Path       string    `json:"path"`
+	LastOpened time.Time `json:"lastOpened"`
+	Pinned     bool      `json:"pinned,omitempty"`
+}
+
+func defaultAppPreferences() AppPreferences {
+	return AppPreferences{
+		OpenLastVault:   true,
+		SymlinkPolicy:   string(SymlinkWithinVault),
+		MaxRecentVaults: defaultMaxRecentVaults,
+	}
+}
+
+// appPrefsPath returns where app preferences are stored: chalkmd/ under the
+// user config directory ($XDG_CONFIG_HOME on Linux).
+func appPrefsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appConfigDirName, appPrefsFile), nil
+}
+
+func (a *App) loadAppState() (appState, error) {
+	state := appState{Version: appPrefsVersion, Preferences: defaultAppPreferences()}
+	if a.prefsPath == "" {
+		return state, errNoAppConfig
+	}
+
+	data, err := os.ReadFile(a.prefsPath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read preferences: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		// A broken file should not keep the app from starting; it is
+		// replaced on the next change.
+		return appState{Version: appPrefsVersion, Preferences: defaultAppPreferences()}, nil
+	}
+	if validateAppPreferences(&state.Preferences) != nil {
+		state.Preferences = defaultAppPreferences()
+	}
+	return state, nil
+}
+
+func (a *App) saveAppState(state appState) error {
+	state.Version = appPrefsVersion
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.prefsPath), 0755); err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	if err := writeFileAtomic(a.prefsPath, data); err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return nil
+}
+
+// updateAppState applies change to the stored preferences and saves them.
+func (a *App) updateAppState(change func(state *appState) error) error {
+	a.prefsMu.Lock()
+	defer a.prefsMu.Unlock()
+
+	state, err := a.loadAppState()
+	if err != nil {
+		return err
+	}
+	if err := change(&state); err != nil {
+		return err
+	}
+	return a.saveAppState(state)
+}
+
+func validateAppPreferences(prefs *AppPreferences) error {
+	policy, err := parseSymlinkPolicy(prefs.SymlinkPolicy)
+	if err != nil {
+		return err
+	}
+	prefs.SymlinkPolicy = string(policy)
+
+	if prefs.MaxRecentVaults == 0 {
+		prefs.MaxRecentVaults = defaultMaxRecentVaults
+	}
+	if prefs.MaxRecentVaults < 1 || prefs.MaxRecentVaults > 100 {
+		return fmt.Errorf("invalid number of recent vaults: %d", prefs.MaxRecentVaults)
+	}
+
+	if prefs.DefaultVault != "" {
+		if !filepath.IsAbs(prefs.DefaultVault) {
+			return fmt.Errorf("default vault must be an absolute path: %s", prefs.DefaultVault)
+		}
+		prefs.DefaultVault = filepath.Clean(prefs.DefaultVault)
+	}
+	return nil
+}
+
+// samePath compares two cleaned absolute paths the way the OS does.
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// sortRecentVaults puts pinned vaults first, then the most recently opened.
+func sortRecentVaults(vaults []recentVault) {
+	sort.SliceStable(vaults, func(i, j int) bool {
+		if vaults[i].Pinned != vaults[j].Pinned {
+			return vaults[i].Pinned
+		}
+		return vaults[i].LastOpened.After(vaults[j].LastOpened)
+	})
+}
+
+// rememberVault moves path to the top of the recent vaults. Unpinned
+// entries beyond the configured maximum are dropped.
+func (a *App) rememberVault(path string) error {
+	if a.prefsPath == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	return a.updateAppState(func(state *appState) error {
+		entry := recentVault{Path: abs}
+		kept := state.RecentVaults[:0]
+		for _, v := range state.RecentVaults {
+			if samePath(v.Path, abs) {
+				entry.Pinned = v.Pinned
+				continue
+			}
+			kept = append(kept, v)
+		}
+		entry.LastOpened = time.Now()
+		state.RecentVaults = append(kept, entry)
+		sortRecentVaults(state.RecentVaults)
+
+		limit := state.Preferences.MaxRecentVaults
+		trimmed := state.RecentVaults[:0]
+		unpinned := 0
+		for _, v := range state.RecentVaults {
+			if !v.Pinned {
+				if unpinned >= limit {
+					continue
+				}
+				unpinned++
+			}
+			trimmed = append(trimmed, v)
+		}
+		state.RecentVaults = trimmed
+		return nil
+	})
+}
+
+// ListRecentVaults returns recently opened vaults, pinned ones first, then
+// the most recent. Exists reports whether the folder is still there.
+func (a *App) ListRecentVaults() ([]RecentVault, error) {
+	state, err := a.loadAppState()
+	if err != nil {
+		return nil, err
+	}
+
+	sortRecentVaults(state.RecentVaults)
+	vaults := []RecentVault{}
+	for _, v := range state.RecentVaults {
+		vaults = append(vaults, RecentVault{
+			Path:       v.Path,
+			Name:       filepath.Base(v.Path),
+			LastOpened: v.LastOpened.Format(time.RFC3339),
+			Pinned:     v.Pinned,
+			Exists:     isDir(v.Path),
+			IsDefault:  state.Preferences.DefaultVault != "" && samePath(v.Path, state.Preferences.DefaultVault),
+		})
+	}
+	return vaults, nil
+}
+
+// ForgetVault removes a vault from the recent list. It is no longer the
+// default vault either. The folder itself is not touched.
+func (a *App) ForgetVault(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	return a.updateAppState(func(state *appState) error {
+		kept := state.RecentVaults[:0]
+		for _, v := range state.RecentVaults {
+			if !samePath(v.Path, abs) {
+				kept = append(kept, v)
+			}
+		}
+		state.RecentVaults = kept
+		if state.Preferences.DefaultVault != "" && samePath(state.Preferences.DefaultVault, abs) {
+			state.Preferences.DefaultVault = ""
+		}
+		return nil
+	})
+}
+
+// SetVaultPinned pins or unpins a vault in the recent list. Pinned vaults
+// are listed first and never dropped for being old.
+func (a *App) SetVaultPinned(path string, pinned bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	return a.updateAppState(func(state *appState) error {
+		for i, v := range state.RecentVaults {
+			if samePath(v.Path, abs) {
+				state.RecentVaults[i].Pinned = pinned
+				return nil
+			}
+		}
+		return fmt.Errorf("not a recent vault: %s", path)
+	})
+}
+
+// GetAppPreferences returns the preferences shared by all vaults.
+func (a *App) GetAppPreferences() (AppPreferences, error) {
+	state, err := a.loadAppState()
+	if err != nil {
+		return AppPreferences{}, err
+	}
+	return state.Preferences, nil
+}
+
+// SetAppPreferences validates and stores the preferences shared by all
+// vaults. The symlink policy applies immediately.
+func (a *App) SetAppPreferences(prefs AppPreferences) error {
+	if err := validateAppPreferences(&prefs); err != nil {
+		return err
+	}
+
+	err := a.updateAppState(func(state *appState) error {
+		state.Preferences = prefs
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.SetSymlinkPolicy(prefs.SymlinkPolicy)
+}
+
+// GetStartupVault returns the vault to open when the app starts: the
+// default vault, or else the last opened one if OpenLastVault is set. It is
+// "" if there is none, or it no longer exists.
+func (a *App) GetStartupVault() (string, error) {
+	state, err := a.loadAppState()
+	if err != nil {
+		return "", err
+	}
+
+	if d := state.Preferences.DefaultVault; d != "" && isDir(d) {
+		return d, nil
+	}
+	if !state.Preferences.OpenLastVault {
+		return "", nil
+	}
+
+	var last *recentVault
+	for i, v := range state.RecentVaults {
+		if isDir(v.Path) && (last == nil || v.LastOpened.After(last.LastOpened)) {
+			last = &state.RecentVaults[i]
+		}
+	}
+	if last == nil {
+		return "", nil
+	}
+	return last.Path, nil
+}
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The recovery journal keeps the editor's latest text for notes that have
+// not been saved yet, in .chalkmd/recovery/, one file per note. A stash is
+// removed once the note is saved with the same text; one that is still
+// there when the vault is opened again, and newer than the note, holds
+// edits that were lost. Those are found when the vault opens, before
+// anything is saved.
+
+const recoveryDirName = "recovery"
+
+const (
+	// StashBuffer calls for a note are combined until the editor pauses
+	// this long, or at most stashMaxDelay after the first.
+	stashDelay    = 250 * time.Millisecond
+	stashMaxDelay = time.Second
+)
+
+// stashQueue holds the text given to StashBuffer until it is written.
+// Writes are serialized, so an older stash never replaces a newer one.
+type stashQueue struct {
+	mu      sync.Mutex
+	pending map[string]*queuedStash // by full path
+	writeMu sync.Mutex
+}
+
+type queuedStash struct {
+	root  string
+	stash recoveryStash
+	first time.Time
+	timer *time.Timer
+	// earlier holds the hashes of the text stash replaced, so a save of
+	// any of it is known to be older.
+	earlier map[[sha256.Size]byte]bool
+}
+
+// ErrNoRecovery is returned when a note has no stashed text.
+var ErrNoRecovery = errors.New("no unsaved text to recover")
+
+type recoveryStash struct {
+	Path    string    `json:"path"`
+	Stashed time.Time `json:"stashed"`
+	Content string    `json:"content"`
+}
+
+func recoveryDir(root string) string {
+	return filepath.Join(root, configDirName, recoveryDirName)
+}
+
+// recoveryPath returns the stash file for the slash path rel. Names are
+// hashed so any note path fits.
+func recoveryPath(root, rel string) string {
+	sum := sha256.Sum256([]byte(rel))
+	return filepath.Join(recoveryDir(root), hex.EncodeToString(sum[:16])+".json")
+}
+
+func readStash(path string) (recoveryStash, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return recoveryStash{}, false
+	}
+	var s recoveryStash
+	if json.Unmarshal(data, &s) != nil || s.Path == "" {
+		return recoveryStash{}, false
+	}
+	return s, true
+}
+
+func writeStash(root string, s recoveryStash) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(recoveryDir(root), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(recoveryPath(root, s.Path), data)
+}
+
+// stashes returns every readable stash in the vault at root, keyed by the
+// stash file.
+func stashes(root string) map[string]recoveryStash {
+	entries, _ := os.ReadDir(recoveryDir(root))
+	found := make(map[string]recoveryStash, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		file := filepath.Join(recoveryDir(root), e.Name())
+		if s, ok := readStash(file); ok {
+			found[file] = s
+		}
+	}
+	return found
+}
+
+// StashBuffer records the editor's unsaved text for a note, so it can be
+// recovered if the app quits before the note is saved. Call it on every
+// change: calls are combined and written once the editor pauses. Saving
+// the same text clears it.
+func (a *App) StashBuffer(relativePath string, content string) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
+
+	q := &a.stashing
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string]*queuedStash)
+	}
+	s := q.pending[fullPath]
+	now := time.Now()
+	if s == nil {
+		s = &queuedStash{root: a.currentVault, first: now, earlier: make(map[[sha256.Size]byte]bool)}
+		s.timer = time.AfterFunc(stashDelay, func() { a.stashDue(fullPath) })
+		q.pending[fullPath] = s
+	} else {
+		if s.stash.Content != content {
+			s.earlier[sha256.Sum256([]byte(s.stash.Content))] = true
+		}
+		if now.Sub(s.first) < stashMaxDelay {
+			s.timer.Reset(stashDelay)
+		}
+	}
+	s.stash = recoveryStash{Path: a.vaultRel(fullPath), Stashed: now, Content: content}
+	return nil
+}
+
+func (a *App) stashDue(fullPath string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	a.writeStashes(func(p string) bool { return p == fullPath })
+}
+
+// writeStashes writes the queued stashes whose full paths match now. A
+// stash matching its note is not written: nothing is unsaved, and a stash
+// left by a crash stays until handled. The caller holds a.mu.
+func (a *App) writeStashes(match func(fullPath string) bool) {
+	q := &a.stashing
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+
+	q.mu.Lock()
+	var due []*queuedStash
+	for p, s := range q.pending {
+		if match(p) {
+			s.timer.Stop()
+			delete(q.pending, p)
+			due = append(due, s)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, s := range due {
+		if s.root != a.currentVault || a.writable() != nil {
+			continue
+		}
+		fullPath := filepath.Join(s.root, filepath.FromSlash(s.stash.Path))
+		if current, err := os.ReadFile(fullPath); err == nil && string(current) == s.stash.Content {
+			continue
+		}
+		writeStash(s.root, s.stash)
+	}
+}
+
+// stashSaved removes the stash of fullPath if it holds content, which was
+// just saved. A queued stash is written now if its text is newer than
+// content: content was stashed before it, or it was stashed after content
+// was handed to the app at since. Otherwise it is dropped, so the saved
+// note is not followed by an outdated stash. The caller holds a.mu.
+func (a *App) stashSaved(fullPath string, content string, since time.Time) {
+	q := &a.stashing
+	q.mu.Lock()
+	if s := q.pending[fullPath]; s != nil {
+		newer := s.stash.Content != content &&
+			(s.earlier[sha256.Sum256([]byte(content))] || s.stash.Stashed.After(since))
+		if !newer {
+			s.timer.Stop()
+			delete(q.pending, fullPath)
+		}
+	}
+	q.mu.Unlock()
+	a.writeStashes(func(p string) bool { return p == fullPath })
+
+	path := recoveryPath(a.currentVault, a.vaultRel(fullPath))
+	if s, ok := readStash(path); ok && s.Content == content {
+		os.Remove(path)
+	}
+}
+
+// findRecoverable returns the stashes in the vault at root that are newer
+// than their note on disk: edits that were never saved. Outdated stashes,
+// and those matching their note, are removed if clean is set.
+func findRecoverable(root string, policy SymlinkPolicy, clean bool) []RecoverableBuffer {
+	buffers := []RecoverableBuffer{}
+	for file, s := range stashes(root) {
+		fullPath, err := resolveInVault(root, filepath.FromSlash(s.Path), policy, false, false)
+		if err != nil {
+			continue
+		}
+		buffer := RecoverableBuffer{
+			Path:    filepath.FromSlash(s.Path),
+			Stashed: s.Stashed.Format(time.RFC3339Nano),
+			Size:    len(s.Content),
+		}
+		if info, err := os.Stat(fullPath); err == nil {
+			current, _ := os.ReadFile(fullPath)
+			if !info.ModTime().Before(s.Stashed) || string(current) == s.Content {
+				if clean {
+					os.Remove(file)
+				}
+				continue
+			}
+			buffer.Modified = info.ModTime().Format(time.RFC3339)
+		}
+		buffers = append(buffers, buffer)
+	}
+
+	sort.Slice(buffers, func(i, j int) bool { return buffers[i].Path < buffers[j].Path })
+	return buffers
+}
+
+// ListRecoverableBuffers returns the notes that had unsaved text when the
+// vault was opened and that were neither recovered nor discarded since.
+func (a *App) ListRecoverableBuffers() ([]RecoverableBuffer, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+
+	// Stashes are matched by time, as their note may have been renamed.
+	found := make(map[string]RecoverableBuffer, len(a.recoverable))
+	for _, b := range a.recoverable {
+		found[b.Stashed] = b
+	}
+	buffers := []RecoverableBuffer{}
+	for _, s := range stashes(a.currentVault) {
+		if b, ok := found[s.Stashed.Format(time.RFC3339Nano)]; ok {
+			b.Path = filepath.FromSlash(s.Path)
+			buffers = append(buffers, b)
+		}
+	}
+	sort.Slice(buffers, func(i, j int) bool { return buffers[i].Path < buffers[j].Path })
+	return buffers, nil
+}
+
+// RecoverBuffer writes a note's stashed text to the note and returns it.
+// The text it replaces is kept in the note's version history.
+func (a *App) RecoverBuffer(relativePath string) (string, error) {
+	if err := a.writable(); err != nil {
+		return "", err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return "", err
+	}
+	s, ok := readStash(recoveryPath(a.currentVault, a.vaultRel(fullPath)))
+	if !ok {
+		return "", ErrNoRecovery
+	}
+
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	if current, err := os.ReadFile(fullPath); err == nil {
+		if err := a.history.record(a.vaultRel(fullPath), current); err != nil {
+			return "", fmt.Errorf("failed to save current version: %w", err)
+		}
+	}
+	if err := a.writeFile(fw, s.Content); err != nil {
+		return "", err
+	}
+	return s.Content, nil
+}
+
+// DiscardRecovery deletes a note's stashed text.
+func (a *App) DiscardRecovery(relativePath string) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(recoveryPath(a.currentVault, a.vaultRel(fullPath)))
+	if os.IsNotExist(err) {
+		return ErrNoRecovery
+	}
+	return err
+}
+
+// renameStashes moves the stashes of oldRel, or of the notes below it, to
+// newRel.
+func renameStashes(root, oldRel, newRel string) {
+	for file, s := range stashes(root) {
+		rest, ok := strings.CutPrefix(s.Path, oldRel)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		s.Path = newRel + rest
+		if writeStash(root, s) == nil {
+			os.Remove(file)
+		}
+	}
+}
+
+// removeStashes deletes the stashes of rel, or of the notes below it.
+func removeStashes(root, rel string) {
+	for file, s := range stashes(root) {
+		if s.Path == rel || strings.HasPrefix(s.Path, rel+"/") {
+			os.Remove(file)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenameFileWithLinks renames a file or folder like RenameFile and rewrites
+// every [[wikilink]] and ![[embed]] in the vault that pointed at it.
+func (a *App) RenameFileWithLinks(oldPath string, newPath string) (LinkUpdateReport, error) {
+	return a.relocateWithLinks(oldPath, newPath, false)
+}
+
+// MoveFileWithLinks moves a file or folder like MoveFile and rewrites every
+// [[wikilink]] and ![[embed]] in the vault that pointed at it.
+func (a *App) MoveFileWithLinks(oldPath string, newPath string) (LinkUpdateReport, error) {
+	return a.relocateWithLinks(oldPath, newPath, true)
+}
+
+func (a *App) relocateWithLinks(oldPath, newPath string, createDirs bool) (LinkUpdateReport, error) {
+	if err := a.writable(); err != nil {
+		return LinkUpdateReport{}, err
+	}
+	// Every queued save is written, not only the moved notes': a note
+	// whose links are rewritten drops the saves still queued for it.
+	a.FlushSaves()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oldFullPath, err := a.resolveEntry(oldPath)
+	if err != nil {
+		return LinkUpdateReport{}, err
+	}
+	newFullPath, err := a.resolvePath(newPath)
+	if err != nil {
+		return LinkUpdateReport{}, err
+	}
+
+	info, err := os.Stat(oldFullPath)
+	if err != nil {
+		return LinkUpdateReport{}, fmt.Errorf("failed to rename: %w", err)
+	}
+	if pathTaken(newFullPath, oldFullPath) {
+		return LinkUpdateReport{}, fmt.Errorf("failed to rename: %s already exists", newPath)
+	}
+
+	before, err := vaultFiles(a.currentVault)
+	if err != nil {
+		return LinkUpdateReport{}, fmt.Errorf("failed to list vault: %w", err)
+	}
+	moved := movedFiles(before, a.vaultRel(oldFullPath), a.vaultRel(newFullPath), info.IsDir())
+
+	if createDirs {
+		if err := os.MkdirAll(filepath.Dir(newFullPath), 0755); err != nil {
+			return LinkUpdateReport{}, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	a.watcher.ignoreSelf(oldFullPath, newFullPath)
+	if err := os.Rename(oldFullPath, newFullPath); err != nil {
+		return LinkUpdateReport{}, err
+	}
+	a.fileRenamed(oldFullPath, newFullPath)
+
+	after := make([]string, len(before))
+	for i, f := range before {
+		if dest, ok := moved[f]; ok {
+			f = dest
+		}
+		after[i] = f
+	}
+
+	report, rewrites, err := a.vaultLinkRewrites(moved, before, after)
+	if err == nil {
+		err = a.rewriteNotes(rewrites, false)
+	}
+	if err != nil {
+		// Leave the vault as it was: links untouched, file at its old path.
+		a.watcher.ignoreSelf(newFullPath, oldFullPath)
+		if moveErr := os.Rename(newFullPath, oldFullPath); moveErr != nil {
+			return LinkUpdateReport{}, errors.Join(
+				fmt.Errorf("failed to update links: %w", err),
+				fmt.Errorf("failed to move %s back: %w", newPath, moveErr),
+			)
+		}
+		a.fileRenamed(newFullPath, oldFullPath)
+		return LinkUpdateReport{}, fmt.Errorf("failed to update links: %w", err)
+	}
+
+	kind := OpRename
+	if createDirs {
+		kind = OpMove
+	}
+	a.journal.record(&journalEntry{
+		kind:     kind,
+		path:     oldFullPath,
+		newPath:  newFullPath,
+		rewrites: rewrites,
+	})
+
+	return report, nil
+}
+
+// movedFiles maps every vault file affected by moving oldRel to newRel to
+// its new path. For a folder that is every file below it.
+func movedFiles(files []string, oldRel, newRel string, isDir bool) map[string]string {
+	moved := make(map[string]string)
+	if !isDir {
+		moved[oldRel] = newRel
+		return moved
+	}
+
+	prefix := oldRel + "/"
+	for _, f := range files {
+		if strings.HasPrefix(f, prefix) {
+			moved[f] = newRel + "/" + strings.TrimPrefix(f, prefix)
+		}
+	}
+	return moved
+}
+
+// vaultLinkRewrites computes the rewrites that update links in every note
+// (at its post-move path) so they follow the moved files. Nothing is
+// written; the caller passes the rewrites to rewriteNotes.
+func (a *App) vaultLinkRewrites(moved map[string]string, before, after []string) (LinkUpdateReport, []noteRewrite, error) {
+	report := LinkUpdateReport{UpdatedFiles: []LinkUpdate{}}
+	var rewrites []noteRewrite
+	for _, f := range after {
+		if !isNote(f) {
+			continue
+		}
+		fullPath := filepath.Join(a.currentVault, filepath.FromSlash(f))
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return LinkUpdateReport{}, nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		updated, n := rewriteLinks(string(content), moved, before, after)
+		if n > 0 {
+			rewrites = append(rewrites, noteRewrite{fullPath, content, []byte(updated)})
+			report.UpdatedFiles = append(report.UpdatedFiles, LinkUpdate{Path: filepath.FromSlash(f), Links: n})
+			report.LinksUpdated += n
+		}
+	}
+	return report, rewrites, nil
+}
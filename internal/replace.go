@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Find and replace across the vault takes two steps. PreviewReplace finds
+// every match and keeps the notes as they were read; ApplyReplace writes
+// the chosen edits, provided none of those notes changed in between, and
+// records them as one operation that can be undone.
+
+// A preview holds at most this many edits.
+const replaceMaxEdits = 10000
+
+var (
+	ErrInvalidPattern  = errors.New("invalid pattern")
+	ErrPreviewExpired  = errors.New("replace preview is no longer available")
+	ErrPreviewOutdated = errors.New("files changed since the preview")
+)
+
+// replacePreviews keeps the latest preview until it is applied or replaced
+// by another one.
+type replacePreviews struct {
+	mu     sync.Mutex
+	latest *replacePreview
+}
+
+type replacePreview struct {
+	id    string
+	root  string
+	edits []ReplaceEdit     // ID is the index
+	notes map[string][]byte // content at preview time, by slash path
+}
+
+// PreviewReplace finds every match of pattern in the vault's notes and
+// returns the edits replacing them would make. With options.Regex the
+// pattern is a Go regular expression and the replacement may refer to
+// groups as $1 or ${name}; otherwise both are literal text.
+func (a *App) PreviewReplace(pattern string, replacement string, options ReplaceOptions) (ReplacePreview, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return ReplacePreview{}, ErrNoVault
+	}
+	re, err := replacePattern(pattern, options)
+	if err != nil {
+		return ReplacePreview{}, err
+	}
+	var glob *regexp.Regexp
+	if options.PathGlob != "" {
+		if glob, err = globRegexp(options.PathGlob); err != nil {
+			return ReplacePreview{}, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+		}
+	}
+	files, err := vaultFiles(a.currentVault)
+	if err != nil {
+		return ReplacePreview{}, fmt.Errorf("failed to list vault: %w", err)
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	preview := ReplacePreview{ID: hex.EncodeToString(id), Edits: []ReplaceEdit{}}
+	kept := &replacePreview{id: preview.ID, root: a.currentVault, notes: make(map[string][]byte)}
+	for _, f := range files {
+		if !isNote(f) || (glob != nil && !glob.MatchString(f)) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(a.currentVault, filepath.FromSlash(f)))
+		if err != nil {
+			continue
+		}
+		edits := findReplacements(string(content), re, replacement, options)
+		if room := replaceMaxEdits - len(preview.Edits); len(edits) > room {
+			edits, preview.Truncated = edits[:room], true
+		}
+		if len(edits) > 0 {
+			for i := range edits {
+				edits[i].ID = len(preview.Edits) + i
+				edits[i].Path = filepath.FromSlash(f)
+			}
+			preview.Edits = append(preview.Edits, edits...)
+			preview.Files++
+			kept.notes[f] = content
+		}
+		if preview.Truncated {
+			break
+		}
+	}
+	kept.edits = preview.Edits
+
+	a.replaces.mu.Lock()
+	a.replaces.latest = kept
+	a.replaces.mu.Unlock()
+	return preview, nil
+}
+
+func replacePattern(pattern string, options ReplaceOptions) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+	expr := pattern
+	if !options.Regex {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if !options.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return re, nil
+}
+
+// globRegexp compiles a path glob as described for ReplaceOptions. Paths
+// are matched without regard to case.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "/")
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// findReplacements returns the edits replacing every match of re in
+// content would make, skipping matches options rule out.
+func findReplacements(content string, re *regexp.Regexp, replacement string, options ReplaceOptions) []ReplaceEdit {
+	bodyStart := len(content) - len(parseFrontmatter(content).body)
+	var code []textRange
+	if options.SkipCode {
+		code = codeRanges(content)
+	}
+
+	var edits []ReplaceEdit
+	for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+		start, end := m[0], m[1]
+		if start == end ||
+			(options.SkipFrontmatter && start < bodyStart) ||
+			(options.SkipCode && (inRanges(code, start) || inRanges(code, end-1))) ||
+			(options.WholeWord && !wholeWord(content, start, end)) {
+			continue
+		}
+		repl := replacement
+		if options.Regex {
+			repl = string(re.ExpandString(nil, replacement, content, m))
+		}
+
+		line, text := lineAt(content, start)
+		lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+		lineEnd := len(content)
+		if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+			lineEnd = end + i
+		}
+		after := strings.TrimRight(content[lineStart:start]+repl+content[end:lineEnd], "\r")
+		col := start - lineStart
+		edits = append(edits, ReplaceEdit{
+			Line:        line,
+			Start:       start,
+			End:         end,
+			Match:       content[start:end],
+			Replacement: repl,
+			Before:      snippet(text, col, min(col+end-start, len(text)), snippetWidth),
+			After:       snippet(after, col, min(col+len(repl), len(after)), snippetWidth),
+		})
+	}
+	return edits
+}
+
+// wholeWord reports whether content[start:end] is not part of a longer
+// word.
+func wholeWord(content string, start, end int) bool {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+	if r, _ := utf8.DecodeLastRuneInString(content[:start]); start > 0 && isWord(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(content[end:]); end < len(content) && isWord(r) {
+		return false
+	}
+	return true
+}
+
+// ApplyReplace writes the edits of a preview returned by PreviewReplace,
+// or only those whose IDs are in selectedEdits if it is not empty. It
+// refuses, writing nothing, if any of the notes changed since the preview,
+// counting saves still queued for them. All notes are written or none are,
+// and the whole replace is undone with one UndoLastFileOperation.
+func (a *App) ApplyReplace(previewID string, selectedEdits []int) (ReplaceReport, error) {
+	if err := a.writable(); err != nil {
+		return ReplaceReport{}, err
+	}
+	a.FlushSaves()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return ReplaceReport{}, ErrNoVault
+	}
+	a.replaces.mu.Lock()
+	p := a.replaces.latest
+	a.replaces.mu.Unlock()
+	if p == nil || p.id != previewID || p.root != a.currentVault {
+		return ReplaceReport{}, ErrPreviewExpired
+	}
+
+	chosen := p.edits
+	if len(selectedEdits) > 0 {
+		chosen = nil
+		seen := make(map[int]bool)
+		for _, id := range selectedEdits {
+			if id < 0 || id >= len(p.edits) {
+				return ReplaceReport{}, fmt.Errorf("no edit %d in the preview", id)
+			}
+			if !seen[id] {
+				seen[id] = true
+				chosen = append(chosen, p.edits[id])
+			}
+		}
+		sort.Slice(chosen, func(i, j int) bool { return chosen[i].ID < chosen[j].ID })
+	}
+
+	// Edits are in file order, and by offset within a file.
+	var order []string
+	byFile := make(map[string][]ReplaceEdit)
+	for _, e := range chosen {
+		rel := filepath.ToSlash(e.Path)
+		if _, ok := byFile[rel]; !ok {
+			order = append(order, rel)
+		}
+		byFile[rel] = append(byFile[rel], e)
+	}
+
+	report := ReplaceReport{UpdatedFiles: []ReplaceUpdate{}}
+	var rewrites []noteRewrite
+	var changed []string
+	for _, rel := range order {
+		fullPath := filepath.Join(a.currentVault, filepath.FromSlash(rel))
+		current, err := os.ReadFile(fullPath)
+		if err != nil || !bytes.Equal(current, p.notes[rel]) {
+			changed = append(changed, rel)
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, e := range byFile[rel] {
+			b.Write(current[last:e.Start])
+			b.WriteString(e.Replacement)
+			last = e.End
+		}
+		b.Write(current[last:])
+		rewrites = append(rewrites, noteRewrite{fullPath, current, []byte(b.String())})
+		report.UpdatedFiles = append(report.UpdatedFiles, ReplaceUpdate{Path: filepath.FromSlash(rel), Replacements: len(byFile[rel])})
+		report.Replacements += len(byFile[rel])
+	}
+	if len(changed) > 0 {
+		return ReplaceReport{}, fmt.Errorf("%w: %s", ErrPreviewOutdated, strings.Join(changed, ", "))
+	}
+
+	if err := a.rewriteNotes(rewrites, false); err != nil {
+		return ReplaceReport{}, fmt.Errorf("failed to replace: %w", err)
+	}
+	if len(rewrites) > 0 {
+		a.journal.record(&journalEntry{kind: OpReplace, rewrites: rewrites})
+	}
+
+	a.replaces.mu.Lock()
+	if a.replaces.latest == p {
+		a.replaces.latest = nil
+	}
+	a.replaces.mu.Unlock()
+	return report, nil
+}
@@ -0,0 +1,296 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EventSaveStatus is emitted with a SaveStatus whenever a queued save
+	// changes state.
+	EventSaveStatus = "file:save-status"
+
+	SaveStatusPending = "pending"
+	SaveStatusSaved   = "saved"
+	SaveStatusFailed  = "failed"
+
+	// A note edited without pause is still saved this often.
+	saveQueueMaxDelay = 2 * time.Second
+)
+
+var (
+	// ErrFileGone is reported for a queued save whose note was deleted,
+	// renamed or moved before the save was written.
+	ErrFileGone = errors.New("file no longer exists")
+	// ErrSaveDropped is reported for a queued save that was discarded
+	// because the app rewrote the note, as a replace or tag rename does;
+	// the editor is told to reload it.
+	ErrSaveDropped = errors.New("save dropped: the note was rewritten")
+)
+
+// saveQueue holds saves queued with QueueSave until they are written. Saves
+// to one file are coalesced: only the newest content is written, once the
+// file has not been saved to for the vault's autosave interval.
+type saveQueue struct {
+	mu      sync.Mutex
+	files   map[string]*queuedSave // by full path
+	writing sync.Cond              // signalled when a write finishes
+}
+
+type queuedSave struct {
+	rel     string
+	existed bool // the file existed when the save was queued
+	content string
+	pending bool      // content has not been written yet
+	first   time.Time // when the oldest unwritten content was queued
+	last    time.Time // when content was queued
+	timer   *time.Timer
+	writing bool
+	dropped bool // discarded by dropSaves while being written
+}
+
+// QueueSave saves content to a note in the background. Saves queued in
+// quick succession are combined, and EventSaveStatus reports progress.
+// Queued saves are written at the latest when the vault is closed or the
+// app quits; FlushSaves writes them right away.
+func (a *App) QueueSave(relativePath string, content string) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	fullPath, err := a.resolvePath(relativePath)
+	delay := time.Duration(a.config.AutoSaveInterval) * time.Millisecond
+	a.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	q := &a.saves
+	q.mu.Lock()
+	q.init()
+	f := q.files[fullPath]
+	if f == nil {
+		f = &queuedSave{rel: relativePath, existed: exists(fullPath)}
+		q.files[fullPath] = f
+	}
+	now := time.Now()
+	if !f.pending {
+		f.first = now
+	}
+	f.content, f.pending, f.last = content, true, now
+	if remaining := saveQueueMaxDelay - now.Sub(f.first); delay > remaining {
+		delay = remaining
+	}
+	if f.timer == nil {
+		f.timer = time.AfterFunc(delay, func() { a.writeQueued(fullPath) })
+	} else {
+		f.timer.Reset(delay)
+	}
+	q.mu.Unlock()
+
+	a.emit(EventSaveStatus, SaveStatus{Path: relativePath, Status: SaveStatusPending})
+	return nil
+}
+
+// writeQueued writes the queued content for fullPath, and anything queued
+// for it while that write was running. It does nothing if another call is
+// already writing the file.
+func (a *App) writeQueued(fullPath string) error {
+	q := &a.saves
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f := q.files[fullPath]
+	if f == nil || f.writing {
+		return nil
+	}
+	f.writing = true
+	var err error
+	for f.pending {
+		content, queued := f.content, f.last
+		f.pending = false
+		f.timer.Stop()
+		q.mu.Unlock()
+
+		err = a.saveQueued(fullPath, content, queued, f)
+		status := SaveStatus{Path: f.rel, Status: SaveStatusSaved}
+		if err != nil {
+			status.Status, status.Error = SaveStatusFailed, err.Error()
+		}
+		a.emit(EventSaveStatus, status)
+
+		q.mu.Lock()
+	}
+	f.writing = false
+	if q.files[fullPath] == f {
+		delete(q.files, fullPath)
+	}
+	q.writing.Broadcast()
+	return err
+}
+
+// saveQueued writes a queued save, provided the vault it was queued for is
+// still open, the save was not dropped and, if the file existed when the
+// save was queued, it still does: a save must not bring back a note that
+// was deleted or moved away. queued is when content was queued.
+func (a *App) saveQueued(fullPath string, content string, queued time.Time, f *queuedSave) error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	a.saves.mu.Lock()
+	dropped := f.dropped
+	a.saves.mu.Unlock()
+	if dropped {
+		return ErrSaveDropped
+	}
+
+	if a.currentVault == "" || !withinDir(a.currentVault, fullPath) {
+		return fmt.Errorf("failed to write file: %w", ErrNoVault)
+	}
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+	if fw.stale() {
+		return nil
+	}
+	if f.existed && !exists(fullPath) {
+		return fmt.Errorf("failed to write file: %w", ErrFileGone)
+	}
+	fw.since = queued
+	return a.writeFile(fw, content)
+}
+
+// flushSavesBelow writes the queued saves for relativePath and everything
+// below it, and waits for those already being written. Deletes, renames
+// and moves call it first, so no save lands on the old path afterwards.
+// The caller must not hold a.mu.
+func (a *App) flushSavesBelow(relativePath string) {
+	a.mu.RLock()
+	fullPath, err := a.resolveEntry(relativePath)
+	a.mu.RUnlock()
+	if err != nil {
+		return
+	}
+
+	q := &a.saves
+	below := func(p string) bool {
+		return p == fullPath || strings.HasPrefix(p, fullPath+string(filepath.Separator))
+	}
+	q.mu.Lock()
+	var paths []string
+	for p := range q.files {
+		if below(p) {
+			paths = append(paths, p)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, p := range paths {
+		a.writeQueued(p)
+	}
+
+	q.mu.Lock()
+	q.init()
+	for busy := true; busy; {
+		busy = false
+		for p, f := range q.files {
+			if f.writing && below(p) {
+				busy = true
+				q.writing.Wait()
+				break
+			}
+		}
+	}
+	q.mu.Unlock()
+}
+
+// dropSaves discards the saves queued for notes the app just rewrote: they
+// hold text from before the rewrite and would undo it. The caller holds
+// a.mu exclusively, so a save being written waits and then finds it was
+// dropped.
+func (a *App) dropSaves(fullPaths []string) {
+	q := &a.saves
+	var dropped []string
+	q.mu.Lock()
+	for _, p := range fullPaths {
+		f := q.files[p]
+		if f == nil {
+			continue
+		}
+		delete(q.files, p)
+		if f.writing {
+			f.dropped, f.pending = true, false
+			continue
+		}
+		f.timer.Stop()
+		if f.pending {
+			dropped = append(dropped, f.rel)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, rel := range dropped {
+		a.emit(EventSaveStatus, SaveStatus{Path: rel, Status: SaveStatusFailed, Error: ErrSaveDropped.Error()})
+	}
+}
+
+// FlushSaves writes all queued saves now and waits until they are written.
+// It returns the first error, if any.
+func (a *App) FlushSaves() error {
+	q := &a.saves
+	q.mu.Lock()
+	paths := make([]string, 0, len(q.files))
+	for p := range q.files {
+		paths = append(paths, p)
+	}
+	q.mu.Unlock()
+
+	var first error
+	for _, p := range paths {
+		if err := a.writeQueued(p); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	// Writes started by a timer are still running.
+	q.mu.Lock()
+	q.init()
+	for q.busy() {
+		q.writing.Wait()
+	}
+	q.mu.Unlock()
+	return first
+}
+
+// init prepares a zero saveQueue. The caller holds q.mu.
+func (q *saveQueue) init() {
+	if q.files == nil {
+		q.files = make(map[string]*queuedSave)
+		q.writing.L = &q.mu
+	}
+}
+
+// busy reports whether any queued save is being written. The caller holds
+// q.mu.
+func (q *saveQueue) busy() bool {
+	for _, f := range q.files {
+		if f.writing {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeClose is called by Wails when the window is about to close. It
+// writes the queued saves and never prevents closing.
+func (a *App) BeforeClose(ctx context.Context) bool {
+	a.FlushSaves()
+	return false
+}
@@ -0,0 +1,727 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	searchIndexFile    = "search-index.gob"
+	searchIndexVersion = 1
+
+	defaultSearchLimit  = 100
+	maxMatchesPerResult = 20
+)
+
+// SearchOptions tune SearchVault. The zero value is a case- and
+// diacritic-insensitive search returning up to 100 results.
+type SearchOptions struct {
+	CaseSensitive   bool `json:"caseSensitive"`
+	MatchDiacritics bool `json:"matchDiacritics"`
+	Limit           int  `json:"limit"`
+}
+
+// SearchResult is one matching note. Matches are byte offsets into the
+// file content, with the line they are on and a short snippet.
+type SearchResult struct {
+	Path    string        `json:"path"`
+	Score   float64       `json:"score"`
+	Matches []SearchMatch `json:"matches"`
+}
+
+type SearchMatch struct {
+	Line    int    `json:"line"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Snippet string `json:"snippet"`
+}
+
+// searchOccurrence is one token of a document: its position in the token
+// stream (for phrase matching) and its byte range in the file.
+type searchOccurrence struct {
+	Pos, Start, End int32
+}
+
+type searchDoc struct {
+	ModTime int64
+	Size    int64
+	Tokens  int
+	Tags    []string
+	Terms   map[string][]searchOccurrence
+}
+
+// persistedSearchIndex is the on-disk form under .chalkmd/.
+type persistedSearchIndex struct {
+	Version int
+	Docs    map[string]*searchDoc
+}
+
+// searchIndex is an inverted index over the notes of a vault. Terms are
+// case- and diacritic-folded; exact matching is verified against the file
+// content at query time.
+type searchIndex struct {
+	root string
+
+	mu    sync.RWMutex
+	docs  map[string]*searchDoc
+	terms map[string]map[string]struct{} // term -> set of note paths
+	dirty bool
+}
+
+// openSearchIndex loads the persisted index, if any, and brings it up to
+// date with the notes on disk. Unchanged notes (same mtime and size) are
+// not re-read; the others are read in parallel. The updated index is saved
+// if writable is set.
+func openSearchIndex(root string, writable bool) (*searchIndex, error) {
+	idx := &searchIndex{
+		root:  root,
+		docs:  make(map[string]*searchDoc),
+		terms: make(map[string]map[string]struct{}),
+	}
+	idx.load()
+
+	files, err := vaultFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool)
+	var notes []string
+	for _, f := range files {
+		if isNote(f) {
+			present[f] = true
+			notes = append(notes, f)
+		}
+	}
+
+	type result struct {
+		stale bool
+		doc   *searchDoc
+	}
+	results := make([]result, len(notes))
+	inParallel(len(notes), func(i int) {
+		fullPath := filepath.Join(root, filepath.FromSlash(notes[i]))
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return
+		}
+		if doc, ok := idx.docs[notes[i]]; ok && doc.ModTime == info.ModTime().UnixNano() && doc.Size == info.Size() {
+			return
+		}
+		doc, _ := readSearchDoc(fullPath)
+		results[i] = result{true, doc}
+	})
+	for i, r := range results {
+		if r.stale {
+			idx.put(notes[i], r.doc)
+		}
+	}
+	for p := range idx.docs {
+		if !present[p] {
+			idx.drop(p)
+		}
+	}
+
+	if idx.dirty && writable {
+		idx.save()
+	}
+	return idx, nil
+}
+
+func (idx *searchIndex) indexPath() string {
+	return filepath.Join(idx.root, configDirName, searchIndexFile)
+}
+
+func (idx *searchIndex) load() {
+	data, err := os.ReadFile(idx.indexPath())
+	if err != nil {
+		return
+	}
+
+	var persisted persistedSearchIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&persisted); err != nil || persisted.Version != searchIndexVersion {
+		return
+	}
+
+	for p, doc := range persisted.Docs {
+		idx.docs[p] = doc
+		for term := range doc.Terms {
+			idx.addTerm(term, p)
+		}
+	}
+}
+
+// save writes the index to .chalkmd/ if it changed since the last save.
+func (idx *searchIndex) save() error {
+	if idx == nil {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(persistedSearchIndex{Version: searchIndexVersion, Docs: idx.docs}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.indexPath()), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(idx.indexPath(), buf.Bytes()); err != nil {
+		return err
+	}
+
+	idx.dirty = false
+	return nil
+}
+
+func (idx *searchIndex) addTerm(term, p string) {
+	set, ok := idx.terms[term]
+	if !ok {
+		set = make(map[string]struct{})
+		idx.terms[term] = set
+	}
+	set[p] = struct{}{}
+}
+
+// index reads and (re)indexes one note. The caller holds mu or has
+// exclusive access.
+func (idx *searchIndex) index(p string) {
+	doc, _ := readSearchDoc(filepath.Join(idx.root, filepath.FromSlash(p)))
+	idx.put(p, doc)
+}
+
+// put replaces the entry of note p with doc, or drops it if doc is nil.
+func (idx *searchIndex) put(p string, doc *searchDoc) {
+	idx.drop(p)
+	if doc == nil {
+		return
+	}
+	idx.docs[p] = doc
+	for term := range doc.Terms {
+		idx.addTerm(term, p)
+	}
+	idx.dirty = true
+}
+
+// readSearchDoc reads and tokenizes the note at fullPath.
+func readSearchDoc(fullPath string) (*searchDoc, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &searchDoc{
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		Tags:    extractTags(string(content)),
+		Terms:   make(map[string][]searchOccurrence),
+	}
+	for i, tok := range tokenize(string(content)) {
+		term := foldTerm(tok.text, false, false)
+		doc.Terms[term] = append(doc.Terms[term], searchOccurrence{Pos: int32(i), Start: int32(tok.start), End: int32(tok.end)})
+		doc.Tokens++
+	}
+	return doc, nil
+}
+
+func (idx *searchIndex) drop(p string) {
+	doc, ok := idx.docs[p]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		if set := idx.terms[term]; set != nil {
+			delete(set, p)
+			if len(set) == 0 {
+				delete(idx.terms, term)
+			}
+		}
+	}
+	delete(idx.docs, p)
+	idx.dirty = true
+}
+
+func (idx *searchIndex) update(rel string) {
+	if idx == nil || !isNote(rel) || isHiddenPath(rel) {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.index(rel)
+}
+
+func (idx *searchIndex) remove(rel string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for p := range idx.docs {
+		if p == rel || strings.HasPrefix(p, rel+"/") {
+			idx.drop(p)
+		}
+	}
+}
+
+func (idx *searchIndex) rename(oldRel, newRel string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for p, doc := range idx.docs {
+		var dest string
+		switch {
+		case p == oldRel:
+			dest = newRel
+		case strings.HasPrefix(p, oldRel+"/"):
+			dest = newRel + "/" + strings.TrimPrefix(p, oldRel+"/")
+		default:
+			continue
+		}
+		idx.drop(p)
+		if !isNote(dest) {
+			continue
+		}
+		idx.docs[dest] = doc
+		for term := range doc.Terms {
+			idx.addTerm(term, dest)
+		}
+	}
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits content into runs of letters and digits.
+func tokenize(content string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range content {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			tokens = append(tokens, token{content[start:i], start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{content[start:], start, len(content)})
+	}
+	return tokens
+}
+
+// foldTerm normalizes a token for comparison, removing case and/or
+// diacritics unless asked to keep them.
+func foldTerm(s string, keepCase, keepDiacritics bool) string {
+	if !keepDiacritics {
+		var b strings.Builder
+		for _, r := range norm.NFD.String(s) {
+			if !unicode.Is(unicode.Mn, r) {
+				b.WriteRune(r)
+			}
+		}
+		s = b.String()
+	} else {
+		s = norm.NFC.String(s)
+	}
+	if !keepCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// searchClause is one ANDed part of a query: a word, a prefix or a phrase.
+type searchClause struct {
+	words  []string // original query words
+	prefix bool     // last word matches as a prefix
+}
+
+type searchQuery struct {
+	clauses []searchClause
+	paths   []string
+	tags    []string
+}
+
+// parseSearchQuery splits a query into clauses and path:/tag: filters.
+// "quoted text" is a phrase and a trailing * makes a word a prefix.
+func parseSearchQuery(query string) searchQuery {
+	var q searchQuery
+	for _, part := range splitQuery(query) {
+		quoted := strings.HasPrefix(part, `"`)
+		text := strings.Trim(part, `"`)
+
+		if !quoted {
+			if v, ok := cutFilter(text, "path:"); ok {
+				q.paths = append(q.paths, foldTerm(filepath.ToSlash(v), false, false))
+				continue
+			}
+			if v, ok := cutFilter(text, "tag:"); ok {
+				q.tags = append(q.tags, strings.ToLower(strings.TrimPrefix(v, "#")))
+				continue
+			}
+		}
+
+		prefix := !quoted && strings.HasSuffix(text, "*")
+		var words []string
+		for _, tok := range tokenize(strings.TrimSuffix(text, "*")) {
+			words = append(words, tok.text)
+		}
+		if len(words) > 0 {
+			q.clauses = append(q.clauses, searchClause{words: words, prefix: prefix})
+		}
+	}
+	return q
+}
+
+func cutFilter(part, name string) (string, bool) {
+	if len(part) > len(name) && strings.EqualFold(part[:len(name)], name) {
+		return strings.Trim(part[len(name):], `"`), true
+	}
+	return "", false
+}
+
+// splitQuery splits on whitespace, keeping "quoted phrases" (including
+// path:"with spaces") together.
+func splitQuery(query string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				parts = append(parts, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+	return parts
+}
+
+// termsFor returns the folded index terms a query word can match.
+func (idx *searchIndex) termsFor(word string, prefix bool) []string {
+	folded := foldTerm(word, false, false)
+	if !prefix {
+		if _, ok := idx.terms[folded]; ok {
+			return []string{folded}
+		}
+		return nil
+	}
+	var terms []string
+	for term := range idx.terms {
+		if strings.HasPrefix(term, folded) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// clausePlan is a clause with its words expanded to index terms, done once
+// per query.
+type clausePlan struct {
+	searchClause
+	terms [][]string // per word
+	df    int        // notes containing the first word
+}
+
+func (idx *searchIndex) planClause(c searchClause) clausePlan {
+	plan := clausePlan{searchClause: c, terms: make([][]string, len(c.words))}
+	last := len(c.words) - 1
+	for i, w := range c.words {
+		plan.terms[i] = idx.termsFor(w, c.prefix && i == last)
+	}
+	for _, term := range plan.terms[0] {
+		plan.df += len(idx.terms[term])
+	}
+	return plan
+}
+
+// candidates returns the notes containing every word of the clause, from
+// the posting sets of its terms.
+func (idx *searchIndex) candidates(plan clausePlan) map[string]struct{} {
+	var docs map[string]struct{}
+	for _, terms := range plan.terms {
+		has := make(map[string]struct{})
+		for _, term := range terms {
+			for p := range idx.terms[term] {
+				if _, ok := docs[p]; docs == nil || ok {
+					has[p] = struct{}{}
+				}
+			}
+		}
+		docs = has
+		if len(docs) == 0 {
+			break
+		}
+	}
+	return docs
+}
+
+// clauseMatches returns the byte ranges in doc where the clause matches,
+// before exact case/diacritic verification.
+func (idx *searchIndex) clauseMatches(doc *searchDoc, c clausePlan) []searchOccurrence {
+	last := len(c.words) - 1
+
+	positions := make([]map[int32]searchOccurrence, len(c.words))
+	for i := range c.words {
+		positions[i] = make(map[int32]searchOccurrence)
+		for _, term := range c.terms[i] {
+			for _, occ := range doc.Terms[term] {
+				positions[i][occ.Pos] = occ
+			}
+		}
+	}
+
+	var matches []searchOccurrence
+	for pos, first := range positions[0] {
+		end := first.End
+		ok := true
+		for i := 1; i <= last; i++ {
+			occ, found := positions[i][pos+int32(i)]
+			if !found {
+				ok = false
+				break
+			}
+			end = occ.End
+		}
+		if ok {
+			matches = append(matches, searchOccurrence{Pos: pos, Start: first.Start, End: end})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// exactMatch checks a candidate range against the clause when the search
+// is case- or diacritic-sensitive.
+func exactMatch(text string, c searchClause, opts SearchOptions) bool {
+	got := tokenize(text)
+	if len(got) != len(c.words) {
+		return false
+	}
+	for i, w := range c.words {
+		want := foldTerm(w, opts.CaseSensitive, opts.MatchDiacritics)
+		have := foldTerm(got[i].text, opts.CaseSensitive, opts.MatchDiacritics)
+		if c.prefix && i == len(c.words)-1 {
+			if !strings.HasPrefix(have, want) {
+				return false
+			}
+		} else if have != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *searchIndex) filtered(p string, doc *searchDoc, q searchQuery) bool {
+	folded := foldTerm(p, false, false)
+	for _, want := range q.paths {
+		if !strings.Contains(folded, want) {
+			return false
+		}
+	}
+	for _, want := range q.tags {
+		found := false
+		for _, tag := range doc.Tags {
+			if tag == want || strings.HasPrefix(tag, want+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *searchIndex) search(query string, opts SearchOptions) []SearchResult {
+	q := parseSearchQuery(query)
+	results := []SearchResult{}
+	if len(q.clauses) == 0 && len(q.paths) == 0 && len(q.tags) == 0 {
+		return results
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	exact := opts.CaseSensitive || opts.MatchDiacritics
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	avgTokens := 1.0
+	if len(idx.docs) > 0 {
+		total := 0
+		for _, doc := range idx.docs {
+			total += doc.Tokens
+		}
+		avgTokens = math.Max(1, float64(total)/float64(len(idx.docs)))
+	}
+
+	// Only notes containing every clause's words are looked at, and only
+	// those are read for exact matching.
+	plans := make([]clausePlan, len(q.clauses))
+	candidates := idx.docs
+	for i, c := range q.clauses {
+		plans[i] = idx.planClause(c)
+		docs := idx.candidates(plans[i])
+		next := make(map[string]*searchDoc, len(docs))
+		for p := range docs {
+			if doc, ok := candidates[p]; ok {
+				next[p] = doc
+			}
+		}
+		candidates = next
+	}
+
+	for p, doc := range candidates {
+		if !idx.filtered(p, doc, q) {
+			continue
+		}
+
+		var content string
+		if exact {
+			raw, err := os.ReadFile(filepath.Join(idx.root, filepath.FromSlash(p)))
+			if err != nil {
+				continue
+			}
+			content = string(raw)
+		}
+
+		var ranges []searchOccurrence
+		score := 0.0
+		matched := true
+		for _, c := range plans {
+			found := idx.clauseMatches(doc, c)
+			if exact {
+				kept := found[:0]
+				for _, m := range found {
+					if int(m.End) <= len(content) && exactMatch(content[m.Start:m.End], c.searchClause, opts) {
+						kept = append(kept, m)
+					}
+				}
+				found = kept
+			}
+			if len(found) == 0 {
+				matched = false
+				break
+			}
+
+			idf := math.Log(1 + float64(len(idx.docs))/float64(max(1, c.df)))
+			score += (1 + math.Log(float64(len(found)))) * idf
+
+			name := foldTerm(trimNoteExt(path.Base(p)), false, false)
+			if strings.Contains(name, foldTerm(strings.Join(c.words, " "), false, false)) {
+				score += 2 * idf
+			}
+			ranges = append(ranges, found...)
+		}
+		if !matched {
+			continue
+		}
+
+		score /= 1 + math.Log(1+float64(doc.Tokens)/avgTokens)
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+		if len(ranges) > maxMatchesPerResult {
+			ranges = ranges[:maxMatchesPerResult]
+		}
+
+		result := SearchResult{Path: filepath.FromSlash(p), Score: score, Matches: []SearchMatch{}}
+		for _, r := range ranges {
+			result.Matches = append(result.Matches, SearchMatch{Start: int(r.Start), End: int(r.End)})
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// addSnippets fills in line numbers and snippets for the returned results,
+// reading only those files.
+func (idx *searchIndex) addSnippets(results []SearchResult) {
+	for i := range results {
+		raw, err := os.ReadFile(filepath.Join(idx.root, results[i].Path))
+		if err != nil {
+			continue
+		}
+		content := string(raw)
+		for j := range results[i].Matches {
+			m := &results[i].Matches[j]
+			if m.End > len(content) || !utf8.ValidString(content[m.Start:m.End]) {
+				continue
+			}
+			line, text := lineAt(content, m.Start)
+			col := m.Start - (strings.LastIndexByte(content[:m.Start], '\n') + 1)
+			m.Line = line
+			m.Snippet = snippet(text, col, min(col+(m.End-m.Start), len(text)), snippetWidth)
+		}
+	}
+}
+
+// SearchVault runs a full-text query over the notes of the vault. Words
+// are ANDed; "quoted text" is a phrase, word* a prefix, and path:folder or
+// tag:name restrict the notes searched.
+func (a *App) SearchVault(query string, options SearchOptions) ([]SearchResult, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+	idx := a.search
+	if idx == nil {
+		var err error
+		if idx, err = openSearchIndex(a.currentVault, a.writable() == nil); err != nil {
+			return nil, fmt.Errorf("failed to index vault: %w", err)
+		}
+	}
+
+	results := idx.search(query, options)
+	idx.addSnippets(results)
+	return results, nil
+}
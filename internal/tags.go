@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tags are read from the search index, which extracts them from every note
+// (inline #tags outside code and the frontmatter tags list) and keeps them
+// current as notes change. A nested tag such as project/alpha also counts
+// towards project.
+
+// ErrInvalidTag is returned for a tag name that could not appear as #tag.
+var ErrInvalidTag = errors.New("invalid tag")
+
+var tagNameRe = regexp.MustCompile(`^[\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*$`)
+
+// normalizeTag returns tag as it is indexed: lowercase, without "#" and
+// surrounding slashes.
+func normalizeTag(tag string) string {
+	return strings.Trim(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#")), "/")
+}
+
+func validTag(tag string) bool {
+	return tag != "" && tagNameRe.MatchString(tag) && !strings.Contains(tag, "//")
+}
+
+// tagWithin reports whether tag is parent or nested below it.
+func tagWithin(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+"/")
+}
+
+// noteTags returns the tags of every tagged note, by slash path. The caller
+// holds a.mu.
+func (a *App) noteTags() (map[string][]string, error) {
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+	idx := a.search
+	if idx == nil {
+		var err error
+		if idx, err = openSearchIndex(a.currentVault, a.writable() == nil); err != nil {
+			return nil, fmt.Errorf("failed to index vault: %w", err)
+		}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	notes := make(map[string][]string)
+	for p, doc := range idx.docs {
+		if len(doc.Tags) > 0 {
+			notes[p] = doc.Tags
+		}
+	}
+	return notes, nil
+}
+
+// ListTags returns the tags used in the vault as a tree, sorted by name.
+// Parents of nested tags are listed even if no note uses them directly.
+func (a *App) ListTags() ([]TagInfo, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	notes, err := a.noteTags()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, tags := range notes {
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			for t := tag; !seen[t]; {
+				seen[t] = true
+				counts[t]++
+				i := strings.LastIndexByte(t, '/')
+				if i < 0 {
+					break
+				}
+				t = t[:i]
+			}
+		}
+	}
+
+	children := make(map[string][]string)
+	for tag := range counts {
+		parent := ""
+		if i := strings.LastIndexByte(tag, '/'); i >= 0 {
+			parent = tag[:i]
+		}
+		children[parent] = append(children[parent], tag)
+	}
+	return tagTree(children, counts, ""), nil
+}
+
+func tagTree(children map[string][]string, counts map[string]int, parent string) []TagInfo {
+	tags := children[parent]
+	sort.Strings(tags)
+	tree := make([]TagInfo, 0, len(tags))
+	for _, tag := range tags {
+		tree = append(tree, TagInfo{
+			Tag:      tag,
+			Name:     tag[strings.LastIndexByte(tag, '/')+1:],
+			Count:    counts[tag],
+			Children: tagTree(children, counts, tag),
+		})
+	}
+	return tree
+}
+
+// GetNotesWithTag returns the notes tagged with tag, sorted by path. With
+// includeChildren, notes with a tag nested below it are included too. Tags
+// are matched without regard to case.
+func (a *App) GetNotesWithTag(tag string, includeChildren bool) ([]string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	notes, err := a.noteTags()
+	if err != nil {
+		return nil, err
+	}
+	want := normalizeTag(tag)
+
+	paths := []string{}
+	for p, tags := range notes {
+		for _, t := range tags {
+			if t == want || (includeChildren && tagWithin(t, want)) {
+				paths = append(paths, filepath.FromSlash(p))
+				break
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RenameTag renames a tag, and the tags nested below it, in every note:
+// inline #tags outside code and entries of the frontmatter tags list. The
+// old tag is matched without regard to case. Queued saves are written
+// first so the rename sees them. All new contents are computed before any
+// write; if a write fails, the notes already written are restored. The
+// rename is recorded in the journal and can be undone.
+func (a *App) RenameTag(oldTag string, newTag string) (TagRenameReport, error) {
+	if err := a.writable(); err != nil {
+		return TagRenameReport{}, err
+	}
+	a.FlushSaves()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	old := normalizeTag(oldTag)
+	if !validTag(old) {
+		return TagRenameReport{}, fmt.Errorf("%w: %q", ErrInvalidTag, oldTag)
+	}
+	renamed := strings.Trim(strings.TrimPrefix(strings.TrimSpace(newTag), "#"), "/")
+	if !validTag(renamed) {
+		return TagRenameReport{}, fmt.Errorf("%w: %q", ErrInvalidTag, newTag)
+	}
+
+	notes, err := a.noteTags()
+	if err != nil {
+		return TagRenameReport{}, err
+	}
+	var paths []string
+	for p, tags := range notes {
+		for _, t := range tags {
+			if tagWithin(t, old) {
+				paths = append(paths, p)
+				break
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	report := TagRenameReport{UpdatedFiles: []TagUpdate{}}
+	var rewrites []noteRewrite
+	for _, p := range paths {
+		fullPath := filepath.Join(a.currentVault, filepath.FromSlash(p))
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return TagRenameReport{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		updated, n := renameTags(string(content), old, renamed)
+		if n > 0 {
+			rewrites = append(rewrites, noteRewrite{fullPath, content, []byte(updated)})
+			report.UpdatedFiles = append(report.UpdatedFiles, TagUpdate{Path: filepath.FromSlash(p), Tags: n})
+			report.TagsUpdated += n
+		}
+	}
+
+	if err := a.rewriteNotes(rewrites, false); err != nil {
+		return TagRenameReport{}, fmt.Errorf("failed to rename tag: %w", err)
+	}
+	if len(rewrites) > 0 {
+		a.journal.record(&journalEntry{kind: OpRenameTag, rewrites: rewrites})
+	}
+	return report, nil
+}
+
+// renameTags rewrites the tags of content that are old, or nested below it,
+// to renamed. It returns the new content and the number of tags rewritten.
+// The frontmatter tags list is rewritten as a list, without duplicates.
+func renameTags(content, old, renamed string) (string, int) {
+	n := 0
+	fm := parseFrontmatter(content)
+	bodyStart := len(content) - len(fm.body)
+	head := content[:bodyStart]
+
+	if fm.find("tags") >= 0 {
+		seen := make(map[string]bool)
+		var tags []string
+		changed := false
+		for _, item := range frontmatterTags(fm) {
+			item = strings.TrimSpace(item)
+			hash := ""
+			if strings.HasPrefix(item, "#") {
+				hash, item = "#", item[1:]
+			}
+			if r, ok := renameTag(item, old, renamed); ok {
+				item = r
+				n++
+				changed = true
+			}
+			if key := normalizeTag(item); key != "" && !seen[key] {
+				seen[key] = true
+				tags = append(tags, hash+item)
+			}
+		}
+		if changed && fm.set("tags", tags) == nil {
+			s := fm.String()
+			head = s[:len(s)-len(fm.body)]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(head)
+	last := bodyStart
+	code := codeRanges(content)
+	for _, m := range inlineTagRe.FindAllStringSubmatchIndex(content, -1) {
+		if m[2] < bodyStart || inRanges(code, m[2]) {
+			continue
+		}
+		r, ok := renameTag(content[m[2]:m[3]], old, renamed)
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:m[2]])
+		b.WriteString(r)
+		last = m[3]
+		n++
+	}
+	b.WriteString(content[last:])
+	return b.String(), n
+}
+
+// renameTag replaces the leading segments of tag that match old (lowercase)
+// with renamed, keeping the nested rest and any surrounding slashes.
+func renameTag(tag, old, renamed string) (string, bool) {
+	lead := len(tag) - len(strings.TrimLeft(tag, "/"))
+	trail := len(tag) - len(strings.TrimRight(tag, "/"))
+	if lead == len(tag) {
+		return "", false
+	}
+	segs := strings.Split(tag[lead:len(tag)-trail], "/")
+	oldSegs := strings.Split(old, "/")
+	if len(segs) < len(oldSegs) {
+		return "", false
+	}
+	for i, s := range oldSegs {
+		if strings.ToLower(segs[i]) != s {
+			return "", false
+		}
+	}
+	segs = append([]string{renamed}, segs[len(oldSegs):]...)
+	return tag[:lead] + strings.Join(segs, "/") + tag[len(tag)-trail:], true
+}
@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashMode selects where DeleteFile puts deleted items.
+type TrashMode string
+
+const (
+	// TrashSystem moves items to the operating system's trash. This is the
+	// default.
+	TrashSystem TrashMode = "system"
+	// TrashVault moves items to a .trash folder inside the vault, where they
+	// can be listed and restored from chalkmd.
+	TrashVault TrashMode = "vault"
+)
+
+const (
+	trashDirName              = ".trash"
+	trashMetaExt              = ".json"
+	defaultTrashRetentionDays = 30
+)
+
+var errUnknownTrashMode = errors.New("unknown trash mode")
+
+// Each trashed item lives in .trash/<id>/<original name>, described by
+// .trash/<id>.json.
+type trashMeta struct {
+	OriginalPath string    `json:"originalPath"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	IsDir        bool      `json:"isDir"`
+}
+
+func parseTrashMode(mode string) (TrashMode, error) {
+	switch m := TrashMode(mode); m {
+	case TrashSystem, TrashVault:
+		return m, nil
+	case "":
+		return TrashSystem, nil
+	}
+	return "", fmt.Errorf("%w: %q", errUnknownTrashMode, mode)
+}
+
+// GetTrashSettings returns the current vault's trash mode and how many days
+// items stay in the vault trash (0 keeps them until emptied).
+func (a *App) GetTrashSettings() (TrashSettings, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return TrashSettings{}, ErrNoVault
+	}
+	return TrashSettings{
+		Mode:          string(a.config.TrashMode),
+		RetentionDays: a.config.TrashRetentionDays,
+	}, nil
+}
+
+// SetTrashSettings changes the trash mode and retention for the current
+// vault and stores them in the vault config.
+func (a *App) SetTrashSettings(settings TrashSettings) error {
+	_, err := a.UpdateVaultConfig(map[string]interface{}{
+		"trashMode":          settings.Mode,
+		"trashRetentionDays": settings.RetentionDays,
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.purgeTrash()
+	return nil
+}
+
+func (a *App) trashDir() string {
+	return filepath.Join(a.currentVault, trashDirName)
+}
+
+// moveToVaultTrash moves fullPath into the vault trash and returns the id
+// of the new trash item.
+func (a *App) moveToVaultTrash(fullPath string) (string, error) {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	id := strconv.FormatInt(now.UnixNano(), 10)
+	for {
+		if _, err := os.Lstat(filepath.Join(a.trashDir(), id)); os.IsNotExist(err) {
+			break
+		}
+		n, _ := strconv.ParseInt(id, 10, 64)
+		id = strconv.FormatInt(n+1, 10)
+	}
+
+	itemDir := filepath.Join(a.trashDir(), id)
+	if err := os.MkdirAll(itemDir, 0755); err != nil {
+		return "", err
+	}
+
+	meta, err := json.Marshal(trashMeta{
+		OriginalPath: a.vaultRel(fullPath),
+		DeletedAt:    now,
+		IsDir:        info.IsDir(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(a.trashDir(), id+trashMetaExt), meta); err != nil {
+		os.Remove(itemDir)
+		return "", err
+	}
+
+	if err := os.Rename(fullPath, filepath.Join(itemDir, info.Name())); err != nil {
+		os.Remove(itemDir)
+		os.Remove(filepath.Join(a.trashDir(), id+trashMetaExt))
+		return "", err
+	}
+	return id, nil
+}
+
+func (a *App) readTrashMeta(id string) (trashMeta, error) {
+	var meta trashMeta
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return meta, fmt.Errorf("trash item not found: %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(a.trashDir(), id+trashMetaExt))
+	if err != nil {
+		return meta, fmt.Errorf("trash item not found: %s", id)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to read trash item: %w", err)
+	}
+	return meta, nil
+}
+
+func (a *App) removeTrashItem(id string) error {
+	if err := os.RemoveAll(filepath.Join(a.trashDir(), id)); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(a.trashDir(), id+trashMetaExt))
+}
+
+// ListTrash returns the items in the vault trash, most recently deleted
+// first.
+func (a *App) ListTrash() ([]TrashItem, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.listTrash()
+}
+
+func (a *App) listTrash() ([]TrashItem, error) {
+	if a.currentVault == "" {
+		return nil, ErrNoVault
+	}
+
+	entries, err := os.ReadDir(a.trashDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	items := []TrashItem{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), trashMetaExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		meta, err := a.readTrashMeta(id)
+		if err != nil {
+			continue
+		}
+		items = append(items, TrashItem{
+			ID:           id,
+			Name:         filepath.Base(filepath.FromSlash(meta.OriginalPath)),
+			OriginalPath: filepath.FromSlash(meta.OriginalPath),
+			DeletedAt:    meta.DeletedAt.Format(time.RFC3339),
+			IsDir:        meta.IsDir,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+	return items, nil
+}
+
+// RestoreFromTrash moves a trash item back to where it was deleted from,
+// recreating missing folders. If that path is taken, a number is added to
+// the name ("Note 1.md"). It returns the restored vault-relative path.
+func (a *App) RestoreFromTrash(id string) (string, error) {
+	if err := a.writable(); err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return "", ErrNoVault
+	}
+	meta, err := a.readTrashMeta(id)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := a.resolvePath(meta.OriginalPath)
+	if err != nil {
+		return "", err
+	}
+	target = freePath(target, meta.IsDir)
+
+	if err := a.restoreTrashItem(id, meta, target); err != nil {
+		return "", err
+	}
+
+	rel, _ := filepath.Rel(a.currentVault, target)
+	return rel, nil
+}
+
+// restoreTrashItem moves trash item id to target, which must be free.
+func (a *App) restoreTrashItem(id string, meta trashMeta, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	source := filepath.Join(a.trashDir(), id, filepath.Base(filepath.FromSlash(meta.OriginalPath)))
+	a.watcher.ignoreSelf(target)
+	if err := os.Rename(source, target); err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	a.removeTrashItem(id)
+	a.treeWritten(target)
+	return nil
+}
+
+// treeWritten runs the fileWritten hook for fullPath and, if it is a
+// folder, every file below it.
+func (a *App) treeWritten(fullPath string) {
+	if info, err := os.Lstat(fullPath); err == nil && info.IsDir() {
+		a.folderCreated(fullPath)
+	}
+	filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			a.fileWritten(p)
+		}
+		return nil
+	})
+}
+
+// freePath returns fullPath, or the first "name N.ext" next to it that does
+// not exist yet.
+func freePath(fullPath string, isDir bool) string {
+	if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
+		return fullPath
+	}
+
+	dir, base := filepath.Split(fullPath)
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(base)
+	}
+	name := strings.TrimSuffix(base, ext)
+
+	for n := 1; ; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s %d%s", name, n, ext))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// EmptyTrash permanently deletes everything in the vault trash.
+func (a *App) EmptyTrash() error {
+	if err := a.writable(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentVault == "" {
+		return ErrNoVault
+	}
+	if err := os.RemoveAll(a.trashDir()); err != nil {
+		return fmt.Errorf("failed to empty trash: %w", err)
+	}
+	return nil
+}
+
+// purgeTrash permanently deletes vault trash items older than the
+// configured retention.
+func (a *App) purgeTrash() {
+	days := a.config.TrashRetentionDays
+	if a.currentVault == "" || days <= 0 {
+		return
+	}
+
+	items, err := a.listTrash()
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	for _, item := range items {
+		meta, err := a.readTrashMeta(item.ID)
+		if err == nil && meta.DeletedAt.Before(cutoff) {
+			a.removeTrashItem(item.ID)
+		}
+	}
+}
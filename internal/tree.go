@@ -0,0 +1,353 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Large vaults are listed a folder at a time with ListDirectory. A snapshot
+// of the tree, scanned when the vault opens, records every change the app
+// makes or the watcher reports, so the UI can ask what changed since its
+// last listing instead of listing the whole vault again.
+
+// EventVaultScanProgress is emitted with a ScanProgress while the vault's
+// tree is scanned, and once more when the scan is done.
+const EventVaultScanProgress = "vault:scan-progress"
+
+const (
+	defaultDirectoryLimit = 500
+	maxDirectoryLimit     = 5000
+	scanWorkers           = 8
+	scanProgressEvery     = 1000
+	// Changes kept for GetVaultChangesSince; older tokens get a reset.
+	treeLogMax = 20000
+)
+
+type treeEntry struct {
+	isDir   bool
+	size    int64
+	modTime int64
+}
+
+func treeEntryOf(info os.FileInfo) treeEntry {
+	return treeEntry{isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime().UnixNano()}
+}
+
+type treeChange struct {
+	seq  uint64
+	path string
+}
+
+// vaultTree is the snapshot of a vault's files and folders, by slash path.
+// Tokens are the scan's generation and the number of the last change.
+type vaultTree struct {
+	root string
+	gen  string
+
+	mu      sync.Mutex
+	entries map[string]treeEntry
+	seq     uint64
+	log     []treeChange
+}
+
+// scanTree lists everything below root except hidden entries, reading
+// folders in parallel. progress, if set, is called with the number of
+// entries found so far, one call at a time and in increasing order.
+func scanTree(root string, progress func(int)) (map[string]treeEntry, error) {
+	var (
+		mu       sync.Mutex
+		found    = make(map[string]treeEntry)
+		reported int
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, scanWorkers)
+		rootErr  error
+	)
+
+	var visit func(rel string)
+	visit = func(rel string) {
+		defer wg.Done()
+		slots <- struct{}{}
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+		<-slots
+		if err != nil {
+			if rel == "" {
+				rootErr = err
+			}
+			return
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			child := path.Join(rel, e.Name())
+			mu.Lock()
+			found[child] = treeEntryOf(info)
+			// Reported under mu, so counts arrive in order.
+			if progress != nil && len(found) >= reported+scanProgressEvery {
+				reported = len(found)
+				progress(reported)
+			}
+			mu.Unlock()
+			if e.IsDir() {
+				wg.Add(1)
+				go visit(child)
+			}
+		}
+	}
+	wg.Add(1)
+	go visit("")
+	wg.Wait()
+	return found, rootErr
+}
+
+// inParallel calls fn with 0 to n-1 on up to scanWorkers goroutines and
+// waits for them.
+func inParallel(n int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(n, scanWorkers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+func openVaultTree(root string, progress func(int)) *vaultTree {
+	entries, _ := scanTree(root, progress)
+	return &vaultTree{
+		root:    root,
+		gen:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		entries: entries,
+	}
+}
+
+// tokenLocked identifies the current state of the tree. The caller holds
+// t.mu.
+func (t *vaultTree) tokenLocked() string {
+	return t.gen + "-" + strconv.FormatUint(t.seq, 10)
+}
+
+func (t *vaultTree) token() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tokenLocked()
+}
+
+// record logs a change to p. The caller holds t.mu.
+func (t *vaultTree) record(p string) {
+	t.seq++
+	t.log = append(t.log, treeChange{t.seq, p})
+	if len(t.log) > treeLogMax {
+		t.log = append([]treeChange(nil), t.log[len(t.log)-treeLogMax/2:]...)
+	}
+}
+
+// touch brings the entry of rel, everything below it and its parent
+// folders up to date with the disk, logging what changed.
+func (t *vaultTree) touch(rel string) {
+	if t == nil || rel == "" || rel == "." || isHiddenPath(rel) {
+		return
+	}
+	fullPath := filepath.Join(t.root, filepath.FromSlash(rel))
+	info, statErr := os.Lstat(fullPath)
+	var below map[string]treeEntry
+	if statErr == nil && info.IsDir() {
+		below, _ = scanTree(fullPath, nil)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if statErr != nil || !info.IsDir() {
+		for p := range t.entries {
+			if strings.HasPrefix(p, rel+"/") {
+				delete(t.entries, p)
+				t.record(p)
+			}
+		}
+	}
+	if statErr != nil {
+		if _, ok := t.entries[rel]; ok {
+			delete(t.entries, rel)
+			t.record(rel)
+		}
+		return
+	}
+
+	if old, ok := t.entries[rel]; !ok || old != treeEntryOf(info) {
+		t.entries[rel] = treeEntryOf(info)
+		t.record(rel)
+	}
+	for p, e := range below {
+		p = rel + "/" + p
+		if old, ok := t.entries[p]; !ok || old != e {
+			t.entries[p] = e
+			t.record(p)
+		}
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if _, ok := t.entries[dir]; ok {
+			break
+		}
+		if info, err := os.Lstat(filepath.Join(t.root, filepath.FromSlash(dir))); err == nil {
+			t.entries[dir] = treeEntryOf(info)
+			t.record(dir)
+		}
+	}
+}
+
+// changesSince returns the paths changed since token, sorted, and the
+// current token. It reports false if token is not from this tree or its
+// changes are no longer all logged.
+func (t *vaultTree) changesSince(token string) ([]string, string, bool) {
+	gen, n, found := strings.Cut(token, "-")
+	seq, err := strconv.ParseUint(n, 10, 64)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !found || err != nil || gen != t.gen || seq > t.seq {
+		return nil, t.tokenLocked(), false
+	}
+	if seq < t.seq && (len(t.log) == 0 || t.log[0].seq > seq+1) {
+		return nil, t.tokenLocked(), false
+	}
+	i := sort.Search(len(t.log), func(i int) bool { return t.log[i].seq > seq })
+	seen := make(map[string]bool)
+	var paths []string
+	for _, c := range t.log[i:] {
+		if !seen[c.path] {
+			seen[c.path] = true
+			paths = append(paths, c.path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, t.tokenLocked(), true
+}
+
+// scanVaultTree scans the open vault, reporting progress to the frontend.
+// The caller holds a.mu exclusively.
+func (a *App) scanVaultTree() {
+	tree := openVaultTree(a.currentVault, func(n int) {
+		a.emit(EventVaultScanProgress, ScanProgress{Scanned: n})
+	})
+	a.tree = tree
+	a.emit(EventVaultScanProgress, ScanProgress{Scanned: len(tree.entries), Done: true})
+}
+
+// directoryKey orders folders before files, then names without regard to
+// case. Cursors are the key of the last entry of a page.
+func directoryKey(e os.DirEntry) string {
+	kind := "1"
+	if e.IsDir() {
+		kind = "0"
+	}
+	return kind + strings.ToLower(e.Name()) + "\x00" + e.Name()
+}
+
+// ListDirectory lists one folder ("" for the vault root), a page of up to
+// limit entries at a time: folders first, then files, by name. Pass a
+// page's NextCursor to get the next page. Hidden entries are skipped.
+func (a *App) ListDirectory(relativePath string, cursor string, limit int) (DirectoryPage, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolveDir(relativePath)
+	if err != nil {
+		return DirectoryPage{}, err
+	}
+	if limit <= 0 {
+		limit = defaultDirectoryLimit
+	}
+	limit = min(limit, maxDirectoryLimit)
+
+	// Take the token first so changes made while listing are not missed.
+	page := DirectoryPage{Entries: []FileInfo{}, Token: a.tree.token()}
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return DirectoryPage{}, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	visible := entries[:0]
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			visible = append(visible, e)
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool { return directoryKey(visible[i]) < directoryKey(visible[j]) })
+	start := 0
+	if cursor != "" {
+		start = sort.Search(len(visible), func(i int) bool { return directoryKey(visible[i]) > cursor })
+	}
+
+	dir := a.vaultRel(fullPath)
+	var infos []os.FileInfo
+	for i := start; i < len(visible); i++ {
+		if len(infos) == limit {
+			page.NextCursor = directoryKey(visible[i-1])
+			break
+		}
+		info, err := visible[i].Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	page.Entries = make([]FileInfo, len(infos))
+	inParallel(len(infos), func(i int) {
+		page.Entries[i] = a.fileInfo(path.Join(dir, infos[i].Name()), infos[i])
+	})
+	return page, nil
+}
+
+// GetVaultChangesSince returns what changed in the vault since token, which
+// comes from ListDirectory or an earlier call. An empty or outdated token
+// gets a reset with the current token.
+func (a *App) GetVaultChangesSince(token string) (VaultChanges, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.currentVault == "" {
+		return VaultChanges{}, ErrNoVault
+	}
+	changes := VaultChanges{Updated: []FileInfo{}, Removed: []string{}}
+	if a.tree == nil {
+		changes.Reset = true
+		return changes, nil
+	}
+
+	paths, next, ok := a.tree.changesSince(token)
+	changes.Token, changes.Reset = next, !ok
+	for _, p := range paths {
+		info, err := os.Lstat(filepath.Join(a.currentVault, filepath.FromSlash(p)))
+		if err != nil {
+			changes.Removed = append(changes.Removed, filepath.FromSlash(p))
+			continue
+		}
+		changes.Updated = append(changes.Updated, a.fileInfo(p, info))
+	}
+	return changes, nil
+}
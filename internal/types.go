@@ -2,16 +2,311 @@ package internal
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 )
 
 type App struct {
-	ctx          context.Context
-	currentVault string
+	ctx      context.Context
+	listener EventListener // set by ListenForEvents
+
+	// mu guards the open vault's state below; see writes.go.
+	mu            sync.RWMutex
+	writes        fileWrites
+	saves         saveQueue
+	stashing      stashQueue
+	replaces      replacePreviews
+	currentVault  string
+	symlinkPolicy SymlinkPolicy
+	watcher       *vaultWatcher
+	links         *linkIndex
+	search        *searchIndex
+	meta          *fileMetaIndex
+	tree          *vaultTree
+	history       *historyStore
+	config        VaultConfig
+	journal       *journal
+
+	prefsPath string // app preferences file; "" when not running as the app
+	headless  bool   // run by the CLI: vaults open without building the indexes
+	prefsMu   sync.Mutex
+
+	launch   *LaunchRequest // what to open on startup, until the frontend asks
+	launchMu sync.Mutex
+
+	instances *instanceServer // nil unless ListenForInstances was called; guarded by mu
+
+	lock *vaultLock // nil when the vault is open read-only; guarded by mu
+
+	recoverable []RecoverableBuffer // unsaved text found when the vault opened; guarded by mu
+	readOnly    atomic.Bool
 }
 
-type FileInfo struct {
-	Name     string `json:"name"`
+// LaunchRequest is a vault, and optionally a note in it, that the app was
+// started to open. File is relative to the vault, with forward slashes.
+type LaunchRequest struct {
+	Vault   string `json:"vault"`
+	File    string `json:"file,omitempty"`
+	Heading string `json:"heading,omitempty"`
+}
+
+// VaultLockInfo describes the process holding a vault's lock.
+type VaultLockInfo struct {
+	PID       int    `json:"pid"`
+	Hostname  string `json:"hostname"`
+	Since     string `json:"since"`
+	Heartbeat string `json:"heartbeat"`
+}
+
+// SaveStatus is the payload of EventSaveStatus: Status is "pending",
+// "saved" or "failed", with Error set for the latter.
+type SaveStatus struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RecoverableBuffer is a note with unsaved text in the recovery journal.
+// Modified is when the note on disk last changed, "" if it is missing.
+type RecoverableBuffer struct {
 	Path     string `json:"path"`
-	IsDir    bool   `json:"isDir"`
+	Stashed  string `json:"stashed"`
 	Modified string `json:"modified"`
+	Size     int    `json:"size"`
+}
+
+// InstanceInfo is another running instance of the app.
+type InstanceInfo struct {
+	PID   int    `json:"pid"`
+	Vault string `json:"vault"`
+}
+
+// FileInfo is one file or folder of the vault. Created is "" where the
+// platform does not record it. Stats is set for notes only.
+type FileInfo struct {
+	Name      string     `json:"name"`
+	Path      string     `json:"path"`
+	IsDir     bool       `json:"isDir"`
+	Modified  string     `json:"modified"`
+	Created   string     `json:"created,omitempty"`
+	Size      int64      `json:"size"`
+	Extension string     `json:"extension,omitempty"`
+	Kind      string     `json:"kind"`
+	MimeType  string     `json:"mimeType,omitempty"`
+	Stats     *NoteStats `json:"stats,omitempty"`
+}
+
+// NoteStats are the word and character counts of a note's body (without
+// frontmatter) and the text of its first heading.
+type NoteStats struct {
+	Words      int    `json:"words"`
+	Characters int    `json:"characters"`
+	Heading    string `json:"heading,omitempty"`
+}
+
+// DirectoryPage is one page of a folder listing. NextCursor is "" on the
+// last page. Token is the vault state the page was read at, for
+// GetVaultChangesSince.
+type DirectoryPage struct {
+	Entries    []FileInfo `json:"entries"`
+	NextCursor string     `json:"nextCursor"`
+	Token      string     `json:"token"`
+}
+
+// VaultChanges are the files and folders created, changed or removed since
+// a token. With Reset the token was too old or from another scan, and the
+// listing has to be reloaded.
+type VaultChanges struct {
+	Token   string     `json:"token"`
+	Reset   bool       `json:"reset"`
+	Updated []FileInfo `json:"updated"`
+	Removed []string   `json:"removed"`
+}
+
+// ScanProgress is the payload of EventVaultScanProgress.
+type ScanProgress struct {
+	Scanned int  `json:"scanned"`
+	Done    bool `json:"done"`
+}
+
+// FileVersion is one entry in a note's local version history.
+type FileVersion struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Size      int64  `json:"size"`
+}
+
+// AppPreferences are settings shared by all vaults. DefaultVault, if set,
+// is opened on startup; otherwise the last vault is when OpenLastVault is
+// set. SymlinkPolicy is "within-vault", "deny" or "allow".
+type AppPreferences struct {
+	DefaultVault    string `json:"defaultVault"`
+	OpenLastVault   bool   `json:"openLastVault"`
+	SymlinkPolicy   string `json:"symlinkPolicy"`
+	MaxRecentVaults int    `json:"maxRecentVaults"`
+}
+
+// RecentVault is an entry in the recently opened vaults list.
+type RecentVault struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	LastOpened string `json:"lastOpened"`
+	Pinned     bool   `json:"pinned"`
+	Exists     bool   `json:"exists"`
+	IsDefault  bool   `json:"isDefault"`
+}
+
+// VaultIssue is a problem found by CheckVault. Line is 0 when the issue is
+// about the file as a whole.
+type VaultIssue struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// VaultConfig holds the settings of one vault, stored in
+// .chalkmd/config.json. ImageFolder is vault-relative with forward slashes
+// ("" is the vault root); AutoSaveInterval is in milliseconds.
+type VaultConfig struct {
+	Version            int       `json:"version"`
+	ImageFolder        string    `json:"imageFolder"`
+	AutoSaveInterval   int       `json:"autoSaveInterval"`
+	IndentSize         int       `json:"indentSize"`
+	TrashMode          TrashMode `json:"trashMode"`
+	TrashRetentionDays int       `json:"trashRetentionDays"`
+}
+
+// TrashSettings controls where deleted items go: "system" (the OS trash)
+// or "vault" (a .trash folder in the vault). RetentionDays applies to the
+// vault trash; 0 keeps items until the trash is emptied.
+type TrashSettings struct {
+	Mode          string `json:"mode"`
+	RetentionDays int    `json:"retentionDays"`
+}
+
+// TrashItem is a file or folder in the vault trash.
+type TrashItem struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	OriginalPath string `json:"originalPath"`
+	DeletedAt    string `json:"deletedAt"`
+	IsDir        bool   `json:"isDir"`
+}
+
+// FileOperation is an entry in the undo history of file operations. NewPath
+// is set for renames and moves. Undoable is false for deletes whose content
+// could not be kept.
+type FileOperation struct {
+	ID       int    `json:"id"`
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	NewPath  string `json:"newPath,omitempty"`
+	Time     string `json:"time"`
+	Undoable bool   `json:"undoable"`
+}
+
+// VersionedFile is file content paired with the version token it was read at.
+type VersionedFile struct {
+	Content string `json:"content"`
+	Version string `json:"version"`
+}
+
+// SaveResult reports the outcome of a conditional save. Exactly one of
+// Version or Conflict is set.
+type SaveResult struct {
+	Version  string         `json:"version,omitempty"`
+	Conflict *ConflictError `json:"conflict,omitempty"`
+}
+
+// LinkUpdateReport lists the notes whose wikilinks were rewritten after a
+// rename or move.
+type LinkUpdateReport struct {
+	UpdatedFiles []LinkUpdate `json:"updatedFiles"`
+	LinksUpdated int          `json:"linksUpdated"`
+}
+
+type LinkUpdate struct {
+	Path  string `json:"path"`
+	Links int    `json:"links"`
+}
+
+// ReplaceOptions tune PreviewReplace. PathGlob limits the notes searched:
+// "*" and "?" stay within a folder, "**" crosses folders, and a glob
+// without "/" is matched against the file name alone.
+type ReplaceOptions struct {
+	Regex           bool   `json:"regex"`
+	CaseSensitive   bool   `json:"caseSensitive"`
+	WholeWord       bool   `json:"wholeWord"`
+	PathGlob        string `json:"pathGlob"`
+	SkipCode        bool   `json:"skipCode"`
+	SkipFrontmatter bool   `json:"skipFrontmatter"`
+}
+
+// ReplacePreview is every edit a replace would make. Truncated is set when
+// there were more matches than a preview holds.
+type ReplacePreview struct {
+	ID        string        `json:"id"`
+	Edits     []ReplaceEdit `json:"edits"`
+	Files     int           `json:"files"`
+	Truncated bool          `json:"truncated"`
+}
+
+// ReplaceEdit is one proposed replacement. Start and End are byte offsets
+// into the file; Before and After show its line without and with the edit.
+type ReplaceEdit struct {
+	ID          int    `json:"id"`
+	Path        string `json:"path"`
+	Line        int    `json:"line"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Match       string `json:"match"`
+	Replacement string `json:"replacement"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+}
+
+// ReplaceReport lists the notes changed by ApplyReplace.
+type ReplaceReport struct {
+	UpdatedFiles []ReplaceUpdate `json:"updatedFiles"`
+	Replacements int             `json:"replacements"`
+}
+
+type ReplaceUpdate struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+}
+
+// TagInfo is a tag in the vault's tag hierarchy. Tag is the full
+// lowercase path ("project/alpha"), Name its last segment. Count is the
+// number of notes with the tag or any tag below it.
+type TagInfo struct {
+	Tag      string    `json:"tag"`
+	Name     string    `json:"name"`
+	Count    int       `json:"count"`
+	Children []TagInfo `json:"children"`
+}
+
+// TagRenameReport lists the notes whose tags were rewritten by RenameTag.
+type TagRenameReport struct {
+	UpdatedFiles []TagUpdate `json:"updatedFiles"`
+	TagsUpdated  int         `json:"tagsUpdated"`
+}
+
+type TagUpdate struct {
+	Path string `json:"path"`
+	Tags int    `json:"tags"`
+}
+
+// LinkReference is one link (or unlinked mention) between two notes.
+// TargetPath is empty when the link does not resolve to any file.
+type LinkReference struct {
+	SourcePath string `json:"sourcePath"`
+	TargetPath string `json:"targetPath"`
+	Target     string `json:"target"`
+	Heading    string `json:"heading,omitempty"`
+	Alias      string `json:"alias,omitempty"`
+	Embed      bool   `json:"embed"`
+	Line       int    `json:"line"`
+	Context    string `json:"context"`
 }
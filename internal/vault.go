@@ -6,14 +6,23 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 func (a *App) GetVaultPath() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.currentVault
 }
 
+// OpenVault opens the vault at path and locks it. If another process has
+// it open, it returns a *VaultInUseError; see OpenVaultReadOnly and
+// TakeOverVault.
 func (a *App) OpenVault(path string) error {
+	return a.openVault(path, lockExclusive)
+}
+
+func (a *App) openVault(path string, mode lockMode) error {
 	info, err := os.Stat(path)
 	if err != nil {
 		return fmt.Errorf("vault path not found: %w", err)
@@ -21,10 +30,77 @@ func (a *App) OpenVault(path string) error {
 	if !info.IsDir() {
 		return fmt.Errorf("vault path must be a directory")
 	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// Queued saves belong to the vault that is open now.
+	a.FlushSaves()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Reopening the vault must not find it locked by ourselves.
+	if a.lock != nil && a.lock.path == vaultLockPath(abs) {
+		a.closeVault()
+	}
+	var lock *vaultLock
+	if mode != lockNone {
+		lock, err = acquireVaultLock(abs, mode, a.lockLost)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.closeVault()
+	a.lock = lock
+	a.readOnly.Store(mode == lockNone)
 	a.currentVault = path
+	a.config = loadVaultConfig(path, mode != lockNone)
+	if mode != lockNone {
+		a.purgeTrash()
+	}
+	a.recoverable = findRecoverable(path, a.symlinkPolicy, mode != lockNone)
+	if !a.headless {
+		a.buildIndexes()
+	}
+	a.history = openHistoryStore(path, mode != lockNone)
+	a.journal = newJournal()
+	a.rememberVault(path)
+	a.instances.setVault(path)
+
+	// Without a Wails context or a listener there is nobody to notify about
+	// changes.
+	if a.ctx != nil || a.listener != nil {
+		a.watcher = startVaultWatcher(path, func(w *vaultWatcher, name string, event VaultEvent) {
+			if a.watchedChange(w, name, event) {
+				a.emit(name, event)
+			}
+		})
+	}
+
 	return nil
 }
 
+// closeVault stops watching the current vault, persists its indexes and
+// releases its lock. The caller holds a.mu.
+func (a *App) closeVault() {
+	a.writeStashes(func(string) bool { return true })
+	a.watcher.close()
+	a.watcher = nil
+	if !a.readOnly.Load() {
+		a.saveIndexes()
+	}
+	a.lock.release()
+	a.lock = nil
+}
+
+// lockLost is called when another process took over the vault.
+func (a *App) lockLost() {
+	a.readOnly.Store(true)
+	a.emit(EventVaultLockLost)
+}
+
 func (a *App) SelectVaultFolder() (string, error) {
 	folder, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "Select Vault Folder",
@@ -35,12 +111,21 @@ func (a *App) SelectVaultFolder() (string, error) {
 	return folder, nil
 }
 
+// ListVaultContents returns every file and folder of the vault except
+// hidden ones. Note stats and creation times come from a cache that is
+// refreshed only for files that changed since they were last listed, with
+// the changed notes read in parallel.
 func (a *App) ListVaultContents() ([]FileInfo, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.currentVault == "" {
 		return nil, fmt.Errorf("no vault opened")
 	}
 
-	var files []FileInfo
+	var rels []string
+	var infos []os.FileInfo
+	present := make(map[string]bool)
 	err := filepath.Walk(a.currentVault, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -58,13 +143,10 @@ func (a *App) ListVaultContents() ([]FileInfo, error) {
 		}
 
 		relPath, _ := filepath.Rel(a.currentVault, path)
-
-		files = append(files, FileInfo{
-			Name:     info.Name(),
-			Path:     relPath,
-			IsDir:    info.IsDir(),
-			Modified: info.ModTime().Format(time.RFC3339),
-		})
+		rel := filepath.ToSlash(relPath)
+		present[rel] = true
+		rels = append(rels, rel)
+		infos = append(infos, info)
 
 		return nil
 	})
@@ -72,6 +154,38 @@ func (a *App) ListVaultContents() ([]FileInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	files := make([]FileInfo, len(rels))
+	inParallel(len(rels), func(i int) {
+		files[i] = a.fileInfo(rels[i], infos[i])
+	})
+	a.meta.retain(present)
 
 	return files, nil
 }
+
+// vaultFiles returns the slash-separated paths of all non-hidden files in
+// the vault, in the order ListVaultContents reports them.
+func vaultFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	return files, err
+}
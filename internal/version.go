@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ConflictError is returned when a file changed on disk after the caller
+// read it. It carries the current on-disk state so the editor can offer
+// keep-mine / keep-theirs / merge.
+type ConflictError struct {
+	Path            string `json:"path"`
+	ExpectedVersion string `json:"expectedVersion"`
+	CurrentVersion  string `json:"currentVersion"`
+	CurrentContent  string `json:"currentContent"`
+	Deleted         bool   `json:"deleted"`
+}
+
+func (e *ConflictError) Error() string {
+	if e.Deleted {
+		return fmt.Sprintf("conflict: %s was deleted on disk", e.Path)
+	}
+	return fmt.Sprintf("conflict: %s was modified on disk", e.Path)
+}
+
+// fileVersion builds the version token for content with the given stat info.
+// The token is "<mtime ns>-<size>-<content hash>"; only the hash decides
+// whether two versions differ, mtime and size are there for diagnostics.
+func fileVersion(info os.FileInfo, content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%d-%d-%s", info.ModTime().UnixNano(), info.Size(), hex.EncodeToString(sum[:8]))
+}
+
+func versionHash(version string) string {
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		return version[i+1:]
+	}
+	return version
+}
+
+func sameVersion(a, b string) bool {
+	return a == b || versionHash(a) == versionHash(b)
+}
+
+// readVersioned reads fullPath and returns its content and version token.
+// The token is computed from the bytes read and the stat of the same open
+// file, so it describes the content returned even if the file is replaced
+// meanwhile.
+func readVersioned(fullPath string) ([]byte, string, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, fileVersion(info, content), nil
+}
+
+// checkVersion compares the on-disk state of fullPath against expected.
+// An empty expected version means the caller expects the file not to exist.
+func checkVersion(fullPath, relativePath, expected string) error {
+	content, current, err := readVersioned(fullPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if expected == "" {
+			return nil
+		}
+		return &ConflictError{Path: relativePath, ExpectedVersion: expected, Deleted: true}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if expected != "" && sameVersion(expected, current) {
+		return nil
+	}
+	return &ConflictError{
+		Path:            relativePath,
+		ExpectedVersion: expected,
+		CurrentVersion:  current,
+		CurrentContent:  string(content),
+	}
+}
+
+// ReadFileWithVersion is ReadFile with the version token of the content it
+// returns, for WriteFileIfUnchanged. The editor reads notes through it;
+// ReadFile keeps returning the bare text for callers with no use for a
+// token.
+func (a *App) ReadFileWithVersion(relativePath string) (VersionedFile, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return VersionedFile{}, err
+	}
+
+	content, version, err := readVersioned(fullPath)
+	if err != nil {
+		return VersionedFile{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return VersionedFile{Content: string(content), Version: version}, nil
+}
+
+// WriteFileIfUnchanged saves content only if the file on disk still matches
+// expectedVersion (as returned by ReadFileWithVersion or a previous save).
+// Pass an empty expectedVersion to create a file that must not exist yet.
+// On conflict nothing is written and the result carries the on-disk state.
+func (a *App) WriteFileIfUnchanged(relativePath string, content string, expectedVersion string) (SaveResult, error) {
+	if err := a.writable(); err != nil {
+		return SaveResult{}, err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fullPath, err := a.resolvePath(relativePath)
+	if err != nil {
+		return SaveResult{}, err
+	}
+
+	// The version check and the write happen under one ticket, so no other
+	// save can slip in between.
+	fw := a.writes.acquire(fullPath)
+	defer fw.release()
+
+	if err := checkVersion(fullPath, relativePath, expectedVersion); err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			return SaveResult{Conflict: conflict}, nil
+		}
+		return SaveResult{}, err
+	}
+
+	if err := a.writeFile(fw, content); err != nil {
+		return SaveResult{}, err
+	}
+
+	// The token is of the content written: should something else write
+	// the file right after, the next save sees a conflict.
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	return SaveResult{Version: fileVersion(info, []byte(content))}, nil
+}
@@ -0,0 +1,530 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events emitted to the frontend when the vault changes outside the app.
+const (
+	EventFileCreated = "vault:file-created"
+	EventFileChanged = "vault:file-changed"
+	EventFileDeleted = "vault:file-deleted"
+	EventFileRenamed = "vault:file-renamed"
+	// EventVaultResync tells the UI that changes were lost (e.g. the kernel
+	// queue overflowed) and it should reload the whole tree.
+	EventVaultResync = "vault:resync"
+)
+
+const (
+	watchDebounce     = 150 * time.Millisecond
+	watchMaxDelay     = time.Second
+	watchPollInterval = 2 * time.Second
+	// The app's own changes are recognized for this long after it made
+	// them.
+	selfWriteWindow = 2 * time.Second
+)
+
+// VaultEvent is the payload of the vault:file-* events. Paths are relative
+// to the vault root, like FileInfo.Path.
+type VaultEvent struct {
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+	IsDir   bool   `json:"isDir"`
+}
+
+type changeOp int
+
+const (
+	opCreate changeOp = iota + 1
+	opWrite
+	opRemove
+	opRename
+	opOverflow
+)
+
+type rawChange struct {
+	op      changeOp
+	path    string
+	oldPath string
+	isDir   bool
+}
+
+// watchBackend delivers raw changes for a vault until closed.
+type watchBackend interface {
+	close()
+}
+
+// vaultWatcher turns raw backend changes into debounced vault events,
+// dropping changes the app made itself.
+type vaultWatcher struct {
+	root    string
+	emit    func(w *vaultWatcher, name string, event VaultEvent)
+	backend watchBackend
+
+	mu      sync.Mutex
+	pending map[string]*rawChange
+	order   []string
+	first   time.Time
+	timer   *time.Timer
+	ignore  map[string]*selfChange // by path relative to root
+	closed  bool
+}
+
+// selfChange is a path the app is changing. Until the app reports what it
+// left on disk, any change to the path is taken for its own; after that,
+// only changes that leave the path in that state are, so an edit made
+// elsewhere right after an app save is still reported.
+type selfChange struct {
+	at    time.Time
+	known bool
+	state pathState
+}
+
+// pathState is what the watcher compares to recognize the app's changes.
+// Folders change their modification time with their entries, so only
+// their existence counts.
+type pathState struct {
+	exists  bool
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+func statPath(fullPath string) pathState {
+	info, err := os.Lstat(fullPath)
+	switch {
+	case err != nil:
+		return pathState{}
+	case info.IsDir():
+		return pathState{exists: true, isDir: true}
+	}
+	return pathState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// startVaultWatcher watches the vault at root. emit is called with the
+// watcher itself, as events may come before startVaultWatcher returns.
+func startVaultWatcher(root string, emit func(w *vaultWatcher, name string, event VaultEvent)) *vaultWatcher {
+	w := &vaultWatcher{
+		root:    root,
+		emit:    emit,
+		pending: make(map[string]*rawChange),
+		ignore:  make(map[string]*selfChange),
+	}
+
+	backend, err := newNativeBackend(root, w.push)
+	if err != nil {
+		backend = newPollBackend(root, w.push, watchPollInterval)
+	}
+
+	w.mu.Lock()
+	w.backend = backend
+	w.mu.Unlock()
+
+	return w
+}
+
+func (w *vaultWatcher) close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	backend := w.backend
+	w.mu.Unlock()
+
+	if backend != nil {
+		backend.close()
+	}
+}
+
+// ignoreSelf marks full paths as about to be changed by the app so the
+// watcher does not echo the change back to the UI.
+func (w *vaultWatcher) ignoreSelf(fullPaths ...string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range fullPaths {
+		if rel, err := filepath.Rel(w.root, p); err == nil {
+			w.ignore[rel] = &selfChange{at: now}
+		}
+	}
+}
+
+// selfChanged records the state the app just left full paths in. Changes
+// that leave them so are not reported.
+func (w *vaultWatcher) selfChanged(fullPaths ...string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range fullPaths {
+		if rel, err := filepath.Rel(w.root, p); err == nil {
+			w.ignore[rel] = &selfChange{at: now, known: true, state: statPath(p)}
+		}
+	}
+}
+
+// isSelf reports whether c is the app's own change. The caller holds w.mu.
+func (w *vaultWatcher) isSelf(c *rawChange) bool {
+	if c.op == opOverflow {
+		return false
+	}
+	paths := []string{c.path}
+	if c.op == opRename {
+		paths = append(paths, c.oldPath)
+	}
+	for _, p := range paths {
+		s, ok := w.ignore[p]
+		if !ok || (s.known && statPath(filepath.Join(w.root, p)) != s.state) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *vaultWatcher) push(c rawChange) {
+	if c.op != opOverflow && isHiddenPath(c.path) && (c.oldPath == "" || isHiddenPath(c.oldPath)) {
+		return
+	}
+
+	// A rename out of a hidden path (e.g. the temp file of an atomic save)
+	// is a write to the destination as far as the UI is concerned.
+	if c.op == opRename && isHiddenPath(c.oldPath) {
+		c = rawChange{op: opWrite, path: c.path, isDir: c.isDir}
+	}
+	if c.op == opRename && isHiddenPath(c.path) {
+		c = rawChange{op: opRemove, path: c.oldPath, isDir: c.isDir}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.merge(c)
+
+	now := time.Now()
+	if w.timer == nil {
+		w.first = now
+		w.timer = time.AfterFunc(watchDebounce, w.flush)
+	} else if now.Sub(w.first) < watchMaxDelay {
+		w.timer.Reset(watchDebounce)
+	}
+}
+
+// merge folds c into the pending set so a burst of raw changes to one path
+// becomes at most one event.
+func (w *vaultWatcher) merge(c rawChange) {
+	key := c.path
+	if c.op == opOverflow {
+		key = ""
+	}
+
+	prev, ok := w.pending[key]
+	if !ok {
+		if c.op == opRename {
+			if old, ok := w.pending[c.oldPath]; ok && old.op == opCreate {
+				// Created and renamed within one window: just a create.
+				w.drop(c.oldPath)
+				c = rawChange{op: opCreate, path: c.path, isDir: c.isDir}
+			}
+		}
+		w.pending[key] = &c
+		w.order = append(w.order, key)
+		return
+	}
+
+	switch {
+	case prev.op == opCreate && c.op == opWrite:
+		// still a create
+	case prev.op == opCreate && c.op == opRemove:
+		w.drop(key)
+	case prev.op == opRemove && c.op == opCreate:
+		prev.op = opWrite
+	case c.op == opRename:
+		*prev = c
+	case prev.op == opRename && c.op == opWrite:
+		// keep the rename, the content change is implied
+	default:
+		*prev = c
+	}
+}
+
+func (w *vaultWatcher) drop(key string) {
+	delete(w.pending, key)
+	for i, k := range w.order {
+		if k == key {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (w *vaultWatcher) flush() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	for p, s := range w.ignore {
+		if now.Sub(s.at) > selfWriteWindow {
+			delete(w.ignore, p)
+		}
+	}
+
+	var changes []rawChange
+	for _, key := range w.order {
+		c := w.pending[key]
+		if w.isSelf(c) {
+			continue
+		}
+		changes = append(changes, *c)
+	}
+
+	w.pending = make(map[string]*rawChange)
+	w.order = nil
+	w.timer = nil
+	w.mu.Unlock()
+
+	for _, c := range changes {
+		event := VaultEvent{Path: c.path, OldPath: c.oldPath, IsDir: c.isDir}
+		switch c.op {
+		case opCreate:
+			w.emit(w, EventFileCreated, event)
+		case opWrite:
+			w.emit(w, EventFileChanged, event)
+		case opRemove:
+			w.emit(w, EventFileDeleted, event)
+		case opRename:
+			w.emit(w, EventFileRenamed, event)
+		case opOverflow:
+			w.emit(w, EventVaultResync, VaultEvent{})
+		}
+	}
+}
+
+// isHiddenPath reports whether any component of a relative path is hidden.
+// Hidden entries are skipped by ListVaultContents, so they are not watched.
+func isHiddenPath(rel string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// pollBackend detects changes by periodically diffing a snapshot of the
+// vault tree. It is used where no native watcher is available.
+type pollBackend struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+type pollEntry struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+func newPollBackend(root string, push func(rawChange), interval time.Duration) *pollBackend {
+	b := &pollBackend{stop: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		defer close(b.done)
+
+		prev := pollSnapshot(root)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := os.Stat(root); err != nil {
+				return
+			}
+
+			next := pollSnapshot(root)
+			for _, c := range diffSnapshots(prev, next) {
+				push(c)
+			}
+			prev = next
+		}
+	}()
+
+	return b
+}
+
+func (b *pollBackend) close() {
+	close(b.stop)
+	<-b.done
+}
+
+func pollSnapshot(root string) map[string]pollEntry {
+	snapshot := make(map[string]pollEntry)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		snapshot[rel] = pollEntry{modTime: info.ModTime(), size: info.Size(), isDir: info.IsDir()}
+		return nil
+	})
+	return snapshot
+}
+
+// diffSnapshots compares two snapshots. A file that disappeared and a file
+// that appeared with identical size, mtime and kind are reported as a rename.
+func diffSnapshots(prev, next map[string]pollEntry) []rawChange {
+	var changes []rawChange
+	var removed, created []string
+
+	for p, old := range prev {
+		cur, ok := next[p]
+		if !ok {
+			removed = append(removed, p)
+		} else if !old.isDir && (!cur.modTime.Equal(old.modTime) || cur.size != old.size) {
+			changes = append(changes, rawChange{op: opWrite, path: p})
+		}
+	}
+	for p := range next {
+		if _, ok := prev[p]; !ok {
+			created = append(created, p)
+		}
+	}
+
+	sort.Strings(removed)
+	sort.Strings(created)
+
+	renamedTo := make(map[string]bool)
+	for _, oldPath := range removed {
+		old := prev[oldPath]
+		var candidates []string
+		for _, newPath := range created {
+			cur := next[newPath]
+			if renamedTo[newPath] || cur.isDir != old.isDir || cur.size != old.size || !cur.modTime.Equal(old.modTime) {
+				continue
+			}
+			candidates = append(candidates, newPath)
+		}
+		match := pickRenameCandidate(oldPath, candidates)
+		if match != "" {
+			renamedTo[match] = true
+			changes = append(changes, rawChange{op: opRename, path: match, oldPath: oldPath, isDir: old.isDir})
+		} else {
+			changes = append(changes, rawChange{op: opRemove, path: oldPath, isDir: old.isDir})
+		}
+	}
+	for _, p := range created {
+		if !renamedTo[p] {
+			changes = append(changes, rawChange{op: opCreate, path: p, isDir: next[p].isDir})
+		}
+	}
+
+	return collapseDirRenames(changes)
+}
+
+// pickRenameCandidate chooses the new path for oldPath among entries with
+// matching metadata. Timestamps are often coarse, so ties are broken by base
+// name (a move) and then by depth (a rename in place).
+func pickRenameCandidate(oldPath string, candidates []string) string {
+	filters := []func(string) bool{
+		func(p string) bool { return true },
+		func(p string) bool { return filepath.Base(p) == filepath.Base(oldPath) },
+		func(p string) bool {
+			return strings.Count(p, string(filepath.Separator)) == strings.Count(oldPath, string(filepath.Separator))
+		},
+	}
+
+	for _, keep := range filters {
+		var narrowed []string
+		for _, p := range candidates {
+			if keep(p) {
+				narrowed = append(narrowed, p)
+			}
+		}
+		if len(narrowed) == 1 {
+			return narrowed[0]
+		}
+		if len(narrowed) > 1 {
+			candidates = narrowed
+		}
+	}
+	return ""
+}
+
+// collapseDirRenames drops the per-child changes implied by a directory
+// rename, which a snapshot diff otherwise reports one by one.
+func collapseDirRenames(changes []rawChange) []rawChange {
+	var dirs []rawChange
+	for _, c := range changes {
+		if c.op == opRename && c.isDir {
+			dirs = append(dirs, c)
+		}
+	}
+	if len(dirs) == 0 {
+		return changes
+	}
+
+	within := func(p, dir string) bool {
+		return strings.HasPrefix(p, dir+string(filepath.Separator))
+	}
+
+	var kept []rawChange
+	for _, c := range changes {
+		implied := false
+		for _, d := range dirs {
+			switch c.op {
+			case opRename:
+				implied = within(c.path, d.path) && within(c.oldPath, d.oldPath)
+			case opRemove:
+				implied = within(c.path, d.oldPath)
+			case opCreate:
+				implied = within(c.path, d.path)
+			}
+			if implied {
+				break
+			}
+		}
+		if !implied {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,226 @@
+//go:build linux
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_ONLYDIR
+
+// inotifyBackend watches every non-hidden directory of the vault with one
+// inotify watch each. Directories created later are added as they appear.
+type inotifyBackend struct {
+	root string
+	push func(rawChange)
+	fd   int
+	file *os.File // wraps fd so reads go through the poller and Close unblocks them
+	done chan struct{}
+
+	mu      sync.Mutex
+	watches map[int32]string // wd -> directory relative to root ("" for root)
+}
+
+func newNativeBackend(root string, push func(rawChange)) (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &inotifyBackend{
+		root:    root,
+		push:    push,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		done:    make(chan struct{}),
+		watches: make(map[int32]string),
+	}
+
+	if err := b.addTree("", nil); err != nil {
+		b.file.Close()
+		return nil, err
+	}
+
+	go b.readLoop()
+	return b, nil
+}
+
+func (b *inotifyBackend) close() {
+	b.file.Close()
+	<-b.done
+}
+
+// addTree watches dir and all non-hidden directories below it. When found is
+// non-nil, every entry discovered is reported through it; this catches files
+// created inside a new directory before its watch was in place.
+func (b *inotifyBackend) addTree(dir string, found func(rawChange)) error {
+	return filepath.Walk(filepath.Join(b.root, dir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(b.root, path)
+		if rel == "." {
+			rel = ""
+		}
+		if rel != "" && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if found != nil && rel != dir {
+			found(rawChange{op: opCreate, path: rel, isDir: info.IsDir()})
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(b.fd, path, inotifyMask)
+		if err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.watches[int32(wd)] = rel
+		b.mu.Unlock()
+		return nil
+	})
+}
+
+func (b *inotifyBackend) readLoop() {
+	defer close(b.done)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil || n <= 0 {
+			return
+		}
+		if !b.handle(buf[:n]) {
+			return
+		}
+	}
+}
+
+// handle processes one read worth of events. Moves are paired by cookie;
+// a move whose other half is not in the same batch left or entered the
+// vault and is reported as a delete or create. It returns false once the
+// vault root itself is gone.
+func (b *inotifyBackend) handle(buf []byte) bool {
+	type move struct {
+		path  string
+		isDir bool
+	}
+	movedFrom := make(map[uint32]move)
+	var fromOrder []uint32
+
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+		offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+		name := strings.TrimRight(string(nameBytes), "\x00")
+		mask := raw.Mask
+		isDir := mask&syscall.IN_ISDIR != 0
+
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			b.push(rawChange{op: opOverflow})
+			continue
+		}
+
+		b.mu.Lock()
+		dir, ok := b.watches[raw.Wd]
+		if mask&syscall.IN_IGNORED != 0 {
+			delete(b.watches, raw.Wd)
+		}
+		b.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		if mask&syscall.IN_DELETE_SELF != 0 && dir == "" {
+			return false
+		}
+		if name == "" {
+			continue
+		}
+		rel := filepath.Join(dir, name)
+
+		switch {
+		case mask&syscall.IN_CREATE != 0:
+			if isDir && !isHiddenPath(rel) {
+				b.push(rawChange{op: opCreate, path: rel, isDir: true})
+				b.addTree(rel, b.push)
+				continue
+			}
+			b.push(rawChange{op: opCreate, path: rel, isDir: isDir})
+		case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+			b.push(rawChange{op: opWrite, path: rel})
+		case mask&syscall.IN_DELETE != 0:
+			b.push(rawChange{op: opRemove, path: rel, isDir: isDir})
+		case mask&syscall.IN_MOVED_FROM != 0:
+			movedFrom[raw.Cookie] = move{path: rel, isDir: isDir}
+			fromOrder = append(fromOrder, raw.Cookie)
+		case mask&syscall.IN_MOVED_TO != 0:
+			if from, ok := movedFrom[raw.Cookie]; ok {
+				delete(movedFrom, raw.Cookie)
+				if isDir {
+					b.renameWatches(from.path, rel)
+					if isHiddenPath(from.path) && !isHiddenPath(rel) {
+						b.addTree(rel, nil)
+					}
+				}
+				b.push(rawChange{op: opRename, path: rel, oldPath: from.path, isDir: isDir})
+				continue
+			}
+			b.push(rawChange{op: opCreate, path: rel, isDir: isDir})
+			if isDir && !isHiddenPath(rel) {
+				b.addTree(rel, b.push)
+			}
+		}
+	}
+
+	for _, cookie := range fromOrder {
+		if from, ok := movedFrom[cookie]; ok {
+			if from.isDir {
+				b.removeWatches(from.path)
+			}
+			b.push(rawChange{op: opRemove, path: from.path, isDir: from.isDir})
+		}
+	}
+
+	return true
+}
+
+func (b *inotifyBackend) renameWatches(oldDir, newDir string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prefix := oldDir + string(filepath.Separator)
+	for wd, dir := range b.watches {
+		if dir == oldDir {
+			b.watches[wd] = newDir
+		} else if strings.HasPrefix(dir, prefix) {
+			b.watches[wd] = filepath.Join(newDir, dir[len(prefix):])
+		}
+	}
+}
+
+func (b *inotifyBackend) removeWatches(oldDir string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prefix := oldDir + string(filepath.Separator)
+	for wd, dir := range b.watches {
+		if dir == oldDir || strings.HasPrefix(dir, prefix) {
+			syscall.InotifyRmWatch(b.fd, uint32(wd))
+			delete(b.watches, wd)
+		}
+	}
+}
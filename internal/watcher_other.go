@@ -0,0 +1,11 @@
+//go:build !linux
+
+package internal
+
+import "errors"
+
+// newNativeBackend has no implementation outside Linux yet; the watcher
+// falls back to polling.
+func newNativeBackend(root string, push func(rawChange)) (watchBackend, error) {
+	return nil, errors.New("native file watching not supported on this platform")
+}
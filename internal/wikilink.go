@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// wikiLinkRe matches [[target]], [[target|alias]] and the ![[...]] embed
+// form, the same syntax the WikiLink editor plugin decorates.
+var wikiLinkRe = regexp.MustCompile(`(!?)\[\[([^\]|]+)(?:\|([^\]]+))?\]\]`)
+
+// wikiLink is one link or embed occurrence in a note.
+type wikiLink struct {
+	start, end int // byte range of the whole [[...]]
+	embed      bool
+	target     string // path or name, without heading
+	heading    string // text after '#', if any
+	alias      string // text after '|', if any (width for image embeds)
+}
+
+// parseWikiLinks returns the links in content, skipping code blocks and
+// inline code.
+func parseWikiLinks(content string) []wikiLink {
+	var links []wikiLink
+	code := codeRanges(content)
+
+	for _, m := range wikiLinkRe.FindAllStringSubmatchIndex(content, -1) {
+		if inRanges(code, m[0]) {
+			continue
+		}
+		link := wikiLink{
+			start: m[0],
+			end:   m[1],
+			embed: m[3] > m[2],
+		}
+		target := strings.TrimSpace(content[m[4]:m[5]])
+		if i := strings.IndexByte(target, '#'); i >= 0 {
+			link.heading = target[i+1:]
+			target = strings.TrimSpace(target[:i])
+		}
+		link.target = target
+		if m[6] >= 0 {
+			link.alias = content[m[6]:m[7]]
+		}
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// format renders the link back to markdown with a new target.
+func (l wikiLink) format(target string) string {
+	var b strings.Builder
+	if l.embed {
+		b.WriteByte('!')
+	}
+	b.WriteString("[[")
+	b.WriteString(target)
+	if l.heading != "" {
+		b.WriteByte('#')
+		b.WriteString(l.heading)
+	}
+	if l.alias != "" {
+		b.WriteByte('|')
+		b.WriteString(l.alias)
+	}
+	b.WriteString("]]")
+	return b.String()
+}
+
+func trimNoteExt(p string) string {
+	return strings.TrimSuffix(p, ".md")
+}
+
+// resolveLink finds the file a link target points at, using the same three
+// tiers as WikiLink.js: exact path from the vault root, then a file of that
+// name in the root, then a file of that name anywhere. files are
+// slash-separated vault paths in listing order; "" means unresolved.
+func resolveLink(target string, files []string) string {
+	target = trimNoteExt(strings.TrimPrefix(path.Clean("/"+target), "/"))
+	if target == "" {
+		return ""
+	}
+
+	for _, f := range files {
+		if trimNoteExt(f) == target {
+			return f
+		}
+	}
+	for _, f := range files {
+		if !strings.Contains(f, "/") && trimNoteExt(f) == target {
+			return f
+		}
+	}
+	for _, f := range files {
+		if trimNoteExt(path.Base(f)) == target {
+			return f
+		}
+	}
+	return ""
+}
+
+// linkTargetFor picks the target text for a link to file. A link written
+// as a bare name stays a bare name when that still resolves to file;
+// otherwise the full vault path is used. The .md extension is kept only if
+// the original link had it.
+func linkTargetFor(file string, original wikiLink, files []string) string {
+	keepExt := strings.HasSuffix(original.target, ".md")
+	render := func(p string) string {
+		if keepExt {
+			return p
+		}
+		return trimNoteExt(p)
+	}
+
+	if !strings.Contains(original.target, "/") {
+		name := path.Base(file)
+		if resolveLink(name, files) == file {
+			return render(name)
+		}
+	}
+	return render(file)
+}
+
+// rewriteLinks replaces every link in content that resolves (against
+// before) to a key of moved with a link to the new location (resolved
+// against after). It returns the new content and the number of links
+// rewritten.
+func rewriteLinks(content string, moved map[string]string, before, after []string) (string, int) {
+	links := parseWikiLinks(content)
+	if len(links) == 0 {
+		return content, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	count := 0
+	for _, link := range links {
+		dest, ok := moved[resolveLink(link.target, before)]
+		if !ok {
+			continue
+		}
+		replacement := link.format(linkTargetFor(dest, link, after))
+		if replacement == content[link.start:link.end] {
+			continue
+		}
+		b.WriteString(content[last:link.start])
+		b.WriteString(replacement)
+		last = link.end
+		count++
+	}
+	if count == 0 {
+		return content, 0
+	}
+	b.WriteString(content[last:])
+	return b.String(), count
+}
@@ -1,18 +1,26 @@
 package internal
 
-import (
-	"os"
-	"os/exec"
-)
+import "strings"
 
 func (a *App) OpenNewInstance() error {
-	executable, err := os.Executable()
+	return startInstance()
+}
+
+// OpenVaultInNewInstance starts another window with vaultPath open and, if
+// file is not "", that note. file is relative to the vault and may end in
+// #heading. If another window already has the vault open, it opens the
+// note instead.
+func (a *App) OpenVaultInNewInstance(vaultPath string, file string) error {
+	file, heading, _ := strings.Cut(file, "#")
+	req, err := newLaunchRequest(vaultPath, file, heading)
 	if err != nil {
 		return err
 	}
-
-	cmd := exec.Command(executable)
-	cmd.Env = os.Environ()
-
-	return cmd.Start()
+	a.mu.RLock()
+	self := a.instances.socketPath()
+	a.mu.RUnlock()
+	if handOffLaunch(self, req) {
+		return nil
+	}
+	return startInstance(req.args()...)
 }
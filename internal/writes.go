@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// Wails calls bound methods concurrently. App.mu guards the open vault: a
+// method reading or changing files holds it for reading, while opening or
+// closing a vault, changing its settings, and operations that move or
+// remove files hold it exclusively. Below that, fileWrites orders saves to
+// the same file.
+
+// fileWrites serializes writes to one file. Each write takes a ticket when
+// it is called, then waits for the file. A write that finds a later ticket
+// already saved is dropped, so the save called last wins even when the
+// goroutines reach the file out of order.
+type fileWrites struct {
+	mu    sync.Mutex
+	files map[string]*fileQueue
+}
+
+type fileQueue struct {
+	mu     sync.Mutex // held while the file is written
+	users  int        // tickets not yet released; guarded by fileWrites.mu
+	issued uint64     // last ticket handed out; guarded by fileWrites.mu
+	saved  uint64     // ticket of the last write that completed
+}
+
+// fileWrite is a held ticket for one file.
+type fileWrite struct {
+	w      *fileWrites
+	path   string
+	q      *fileQueue
+	ticket uint64
+	done   bool
+	// since is when the content written was handed to the app: when the
+	// ticket was taken, or for a queued save when it was queued.
+	since time.Time
+}
+
+// acquire takes a ticket for fullPath and waits until earlier writes to it
+// finished. The caller must release it.
+func (w *fileWrites) acquire(fullPath string) *fileWrite {
+	w.mu.Lock()
+	if w.files == nil {
+		w.files = make(map[string]*fileQueue)
+	}
+	q := w.files[fullPath]
+	if q == nil {
+		q = &fileQueue{}
+		w.files[fullPath] = q
+	}
+	q.users++
+	q.issued++
+	fw := &fileWrite{w: w, path: fullPath, q: q, ticket: q.issued, since: time.Now()}
+	w.mu.Unlock()
+
+	q.mu.Lock()
+	return fw
+}
+
+// stale reports whether a write called after this one already saved the
+// file; this one must then not write.
+func (fw *fileWrite) stale() bool {
+	return fw.q.saved > fw.ticket
+}
+
+// saved marks the write as completed, which makes older pending writes
+// stale.
+func (fw *fileWrite) saved() {
+	fw.done = true
+}
+
+func (fw *fileWrite) release() {
+	if fw.done && fw.ticket > fw.q.saved {
+		fw.q.saved = fw.ticket
+	}
+	fw.q.mu.Unlock()
+
+	fw.w.mu.Lock()
+	fw.q.users--
+	if fw.q.users == 0 {
+		delete(fw.w.files, fw.path)
+	}
+	fw.w.mu.Unlock()
+}
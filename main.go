@@ -2,12 +2,14 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"chalkmd/internal"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"github.com/wailsapp/wails/v2/pkg/options/mac"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
 )
 
@@ -15,9 +17,25 @@ import (
 var assets embed.FS
 
 func main() {
-	app := internal.NewApp()
+	if code, ok := internal.RunCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); ok {
+		os.Exit(code)
+	}
+
+	launch, err := internal.ParseLaunchArgs(os.Args[1:])
+	if err != nil {
+		println("Error:", err.Error())
+		os.Exit(internal.ExitUsage)
+	}
+	if internal.HandOffLaunch(launch) {
+		return
+	}
 
-	err := wails.Run(&options.App{
+	app := internal.NewAppWithLaunch(launch)
+	if err := app.ListenForInstances(); err != nil {
+		println("Error:", err.Error())
+	}
+
+	err = wails.Run(&options.App{
 		Title:  "chalkmd",
 		Width:  800,
 		Height: 650,
@@ -26,10 +44,20 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.Startup,
+		OnDomReady:       app.DomReady,
+		OnBeforeClose:    app.BeforeClose,
+		OnShutdown:       app.Shutdown,
 		Bind: []interface{}{
 			app,
 		},
 		Frameless: true,
+		Mac: &mac.Options{
+			OnUrlOpen: func(uri string) {
+				if err := app.HandleLaunchURI(uri); err != nil {
+					println("Error:", err.Error())
+				}
+			},
+		},
 		Windows: &windows.Options{
 			WebviewIsTransparent: false,
 			WindowIsTranslucent:  false,
@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"chalkmd/internal"
+)
+
+// runCLI runs a subcommand against vault and returns its exit code and
+// output. The user config directory is a temporary one.
+func runCLI(t *testing.T, vault string, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	t.Setenv("AppData", configDir)
+	t.Setenv("CHALKMD_VAULT", vault)
+
+	var stdout, stderr bytes.Buffer
+	code, handled := internal.RunCLI(args, strings.NewReader(stdin), &stdout, &stderr)
+	if !handled {
+		t.Fatalf("Expected %v to be handled", args)
+	}
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunCLI(t *testing.T) {
+	t.Run("unknown commands start the GUI", func(t *testing.T) {
+		for _, args := range [][]string{nil, {"-debug"}, {"notacommand"}} {
+			if _, handled := internal.RunCLI(args, nil, &bytes.Buffer{}, &bytes.Buffer{}); handled {
+				t.Errorf("Expected %v not to be handled", args)
+			}
+		}
+	})
+
+	t.Run("new creates a note", func(t *testing.T) {
+		vault := t.TempDir()
+
+		code, out, _ := runCLI(t, vault, "", "new", "Folder/Idea", "--content", "# Idea\n")
+		if code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		if strings.TrimSpace(out) != "Folder/Idea.md" {
+			t.Errorf("Unexpected output %q", out)
+		}
+		data, _ := os.ReadFile(filepath.Join(vault, "Folder", "Idea.md"))
+		if string(data) != "# Idea\n" {
+			t.Errorf("Unexpected content %q", data)
+		}
+
+		code, _, errOut := runCLI(t, vault, "", "new", "Folder/Idea.md")
+		if code != internal.ExitError || !strings.Contains(errOut, "already exists") {
+			t.Errorf("Expected existing note to fail, got %d %q", code, errOut)
+		}
+	})
+
+	t.Run("usage errors", func(t *testing.T) {
+		vault := t.TempDir()
+
+		if code, _, _ := runCLI(t, vault, "", "mv", "only-one.md"); code != internal.ExitUsage {
+			t.Errorf("Expected exit code 2 for missing argument, got %d", code)
+		}
+		if code, _, _ := runCLI(t, vault, "", "check", "--bogus"); code != internal.ExitUsage {
+			t.Errorf("Expected exit code 2 for unknown flag, got %d", code)
+		}
+	})
+
+	t.Run("append adds text and stdin", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "Log.md"), []byte("first"), 0644)
+
+		if code, _, _ := runCLI(t, vault, "", "append", "Log", "second", "line"); code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		if code, _, _ := runCLI(t, vault, "third\n", "append", "Log.md"); code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		data, _ := os.ReadFile(filepath.Join(vault, "Log.md"))
+		if string(data) != "first\nsecond line\nthird\n" {
+			t.Errorf("Unexpected content %q", data)
+		}
+
+		if code, _, _ := runCLI(t, vault, "", "append", "New", "hello"); code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		data, _ = os.ReadFile(filepath.Join(vault, "New.md"))
+		if string(data) != "hello\n" {
+			t.Errorf("Unexpected content %q", data)
+		}
+	})
+
+	t.Run("search prints matches and JSON", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "Fruit.md"), []byte("apples\nbananas\n"), 0644)
+
+		code, out, _ := runCLI(t, vault, "", "search", "bananas")
+		if code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		if !strings.HasPrefix(out, "Fruit.md:2: ") {
+			t.Errorf("Unexpected output %q", out)
+		}
+
+		code, out, _ = runCLI(t, vault, "", "search", "--json", "cherries")
+		if code != internal.ExitNoResult {
+			t.Errorf("Expected exit code 3, got %d", code)
+		}
+		var results []internal.SearchResult
+		if err := json.Unmarshal([]byte(out), &results); err != nil || len(results) != 0 {
+			t.Errorf("Expected empty JSON array, got %q", out)
+		}
+	})
+
+	t.Run("mv rewrites links", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "A.md"), []byte("see [[B]]"), 0644)
+		os.WriteFile(filepath.Join(vault, "B.md"), []byte("b"), 0644)
+
+		code, out, _ := runCLI(t, vault, "", "mv", "B.md", "--json", "C.md")
+		if code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+		var report internal.LinkUpdateReport
+		if err := json.Unmarshal([]byte(out), &report); err != nil || report.LinksUpdated != 1 {
+			t.Errorf("Unexpected report %q", out)
+		}
+		data, _ := os.ReadFile(filepath.Join(vault, "A.md"))
+		if string(data) != "see [[C]]" {
+			t.Errorf("Link not rewritten: %q", data)
+		}
+	})
+
+	t.Run("check exits with issues", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "A.md"), []byte("fine\n[[Missing]]"), 0644)
+
+		code, out, _ := runCLI(t, vault, "", "check")
+		if code != internal.ExitIssues {
+			t.Errorf("Expected exit code 4, got %d", code)
+		}
+		if !strings.Contains(out, "A.md:2: broken-link: Missing") {
+			t.Errorf("Unexpected output %q", out)
+		}
+
+		os.WriteFile(filepath.Join(vault, "Missing.md"), []byte(""), 0644)
+		if code, _, _ := runCLI(t, vault, "", "check"); code != internal.ExitOK {
+			t.Errorf("Expected exit code 0, got %d", code)
+		}
+	})
+
+	t.Run("writes are handed to the app that has the vault open", func(t *testing.T) {
+		runtimeDir(t)
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "Target.md"), []byte(""), 0644)
+		os.WriteFile(filepath.Join(vault, "Source.md"), []byte("see [[Target]]"), 0644)
+		app := listeningApp(t, vault)
+
+		if code, _, errOut := runCLI(t, vault, "", "append", "Log", "kiwi"); code != internal.ExitOK {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, errOut)
+		}
+		if got := readVaultFile(t, vault, "Log.md"); got != "kiwi\n" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if results, _ := app.SearchVault("kiwi", internal.SearchOptions{}); len(results) != 1 {
+			t.Errorf("Expected the app to index the note, got %+v", results)
+		}
+
+		code, out, _ := runCLI(t, vault, "", "mv", "--json", "Target.md", "Moved.md")
+		if code != internal.ExitOK || !strings.Contains(out, "Source.md") {
+			t.Errorf("Expected the links to be updated, got %d: %s", code, out)
+		}
+		if got := readVaultFile(t, vault, "Source.md"); got != "see [[Moved]]" {
+			t.Errorf("Unexpected content %q", got)
+		}
+	})
+
+	t.Run("errors as JSON", func(t *testing.T) {
+		code, _, errOut := runCLI(t, filepath.Join(t.TempDir(), "missing"), "", "check", "--json")
+		if code != internal.ExitError {
+			t.Errorf("Expected exit code 1, got %d", code)
+		}
+		var body map[string]string
+		if err := json.Unmarshal([]byte(errOut), &body); err != nil || body["error"] == "" {
+			t.Errorf("Expected JSON error, got %q", errOut)
+		}
+	})
+}
+
+func TestCheckVault(t *testing.T) {
+	t.Run("finds link and name problems", func(t *testing.T) {
+		app := &internal.App{}
+		vault := t.TempDir()
+		os.MkdirAll(filepath.Join(vault, "x"), 0755)
+		os.MkdirAll(filepath.Join(vault, "y"), 0755)
+		os.WriteFile(filepath.Join(vault, "x", "Dup.md"), []byte(""), 0644)
+		os.WriteFile(filepath.Join(vault, "y", "Dup.md"), []byte(""), 0644)
+		os.WriteFile(filepath.Join(vault, "Bad?.md"), []byte(""), 0644)
+		os.WriteFile(filepath.Join(vault, "Home.md"), []byte("[[Dup]] [[Nowhere]]"), 0644)
+		app.OpenVault(vault)
+
+		issues, err := app.CheckVault()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		kinds := map[string]string{}
+		for _, issue := range issues {
+			kinds[issue.Kind] = issue.Path
+		}
+		if kinds[internal.IssueBrokenLink] != "Home.md" {
+			t.Errorf("Expected broken link in Home.md, got %+v", issues)
+		}
+		if kinds[internal.IssueAmbiguousLink] != "Home.md" {
+			t.Errorf("Expected ambiguous link in Home.md, got %+v", issues)
+		}
+		if kinds[internal.IssueUnportableName] != "Bad?.md" {
+			t.Errorf("Expected unportable name, got %+v", issues)
+		}
+	})
+
+	t.Run("no vault open", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.CheckVault(); err == nil {
+			t.Error("Expected error without a vault")
+		}
+	})
+}
+
+func TestExportVault(t *testing.T) {
+	setup := func(t *testing.T) (*internal.App, string) {
+		app := &internal.App{}
+		vault := t.TempDir()
+		os.MkdirAll(filepath.Join(vault, "Sub"), 0755)
+		os.WriteFile(filepath.Join(vault, "A.md"), []byte("#tag [[Sub/B]]"), 0644)
+		os.WriteFile(filepath.Join(vault, "Sub", "B.md"), []byte("b"), 0644)
+		app.OpenVault(vault)
+		return app, vault
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		app, _ := setup(t)
+		dest := filepath.Join(t.TempDir(), "out.zip")
+		if err := app.ExportVault(dest); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		r, err := zip.OpenReader(dest)
+		if err != nil {
+			t.Fatalf("Expected a zip archive, got %v", err)
+		}
+		defer r.Close()
+		names := map[string]bool{}
+		for _, f := range r.File {
+			names[f.Name] = true
+		}
+		if !names["A.md"] || !names["Sub/B.md"] {
+			t.Errorf("Unexpected entries %v", names)
+		}
+		for name := range names {
+			if strings.HasPrefix(name, ".chalkmd") {
+				t.Errorf("Hidden data should not be exported: %s", name)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		app, _ := setup(t)
+		dest := filepath.Join(t.TempDir(), "out.json")
+		if err := app.ExportVault(dest); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		data, _ := os.ReadFile(dest)
+		var export struct {
+			Notes []struct {
+				Path  string   `json:"path"`
+				Tags  []string `json:"tags"`
+				Links []string `json:"links"`
+			} `json:"notes"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if len(export.Notes) != 2 || export.Notes[0].Path != "A.md" {
+			t.Fatalf("Unexpected notes %+v", export.Notes)
+		}
+		if len(export.Notes[0].Tags) != 1 || len(export.Notes[0].Links) != 1 {
+			t.Errorf("Expected tags and links, got %+v", export.Notes[0])
+		}
+	})
+
+	t.Run("destination inside the vault is rejected", func(t *testing.T) {
+		app, vault := setup(t)
+		if err := app.ExportVault(filepath.Join(vault, "out.zip")); err == nil {
+			t.Error("Expected error for destination inside the vault")
+		}
+		if err := app.ExportVault(filepath.Join(t.TempDir(), "out.tar")); err == nil {
+			t.Error("Expected error for unknown format")
+		}
+	})
+}
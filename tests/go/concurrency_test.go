@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"chalkmd/internal"
+)
+
+// These tests are meant for `go test -race`: Wails calls bound methods
+// from many goroutines at once.
+
+func TestConcurrentFileOperations(t *testing.T) {
+	vault := t.TempDir()
+	app := &internal.App{}
+	if err := app.OpenVault(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer app.Shutdown(context.Background())
+
+	const workers = 8
+	const rounds = 40
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				note := fmt.Sprintf("note%d.md", i%4)
+				switch (w + i) % 6 {
+				case 0, 1:
+					app.WriteFile(note, fmt.Sprintf("content %d %d", w, i))
+				case 2:
+					app.ReadFile(note)
+				case 3:
+					app.RenameFile(note, fmt.Sprintf("moved%d-%d.md", w, i))
+				case 4:
+					app.DeleteFile(note)
+				case 5:
+					app.SearchVault("content", internal.SearchOptions{})
+					app.ListVaultContents()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	files, err := app.ListVaultContents()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, f := range files {
+		content, err := app.ReadFile(f.Path)
+		if err != nil || !strings.HasPrefix(content, "content ") {
+			t.Errorf("%s: unexpected content %q, %v", f.Path, content, err)
+		}
+	}
+	results, _ := app.SearchVault("content", internal.SearchOptions{})
+	if len(results) != len(files) {
+		t.Errorf("Search index out of sync: %d results for %d files", len(results), len(files))
+	}
+}
+
+func TestConcurrentWritesToOneFile(t *testing.T) {
+	t.Run("file holds one complete save", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		const writers = 16
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				content := strings.Repeat(fmt.Sprintf("writer%02d ", w), 2000)
+				if err := app.WriteFile("Shared.md", content); err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		data, _ := os.ReadFile(filepath.Join(vault, "Shared.md"))
+		first := string(data[:len("writer00 ")])
+		if string(data) != strings.Repeat(first, 2000) {
+			t.Errorf("Expected the saves not to interleave, got %q...", data[:40])
+		}
+		results, _ := app.SearchVault(strings.TrimSpace(first), internal.SearchOptions{})
+		if len(results) != 1 {
+			t.Errorf("Expected the search index to match the file, got %+v", results)
+		}
+	})
+
+	t.Run("later saves win", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		for i := 0; i < 50; i++ {
+			app.WriteFile("Note.md", fmt.Sprintf("save %d", i))
+		}
+		if content, _ := app.ReadFile("Note.md"); content != "save 49" {
+			t.Errorf("Expected the last save, got %q", content)
+		}
+	})
+
+	t.Run("one conditional save per version", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+		app.WriteFile("Note.md", "start")
+		start, _ := app.ReadFileWithVersion("Note.md")
+
+		const writers = 16
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		saved := 0
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				result, err := app.WriteFileIfUnchanged("Note.md", fmt.Sprintf("writer %d", w), start.Version)
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+					return
+				}
+				if result.Conflict == nil {
+					mu.Lock()
+					saved++
+					mu.Unlock()
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		if saved != 1 {
+			t.Errorf("Expected exactly one save to succeed, got %d", saved)
+		}
+	})
+}
+
+func TestConcurrentVaultSwitch(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(first, "a.md"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(second, "a.md"), []byte("beta"), 0644)
+
+	app := &internal.App{}
+	app.OpenVault(first)
+	defer app.Shutdown(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if i%2 == 0 {
+				app.OpenVault(second)
+			} else {
+				app.OpenVault(first)
+			}
+		}
+	}()
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				content, err := app.ReadFile("a.md")
+				if err != nil || (content != "alpha" && content != "beta") {
+					t.Errorf("Unexpected read %q, %v", content, err)
+				}
+				app.SearchVault("alpha", internal.SearchOptions{})
+				app.GetBacklinks("a.md")
+				app.GetVaultPath()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if path := app.GetVaultPath(); path != first {
+		t.Errorf("Expected %s to be open, got %s", first, path)
+	}
+}
+
+func TestConcurrentPreferenceChange(t *testing.T) {
+	app, _ := newPrefsApp(t)
+	vault := t.TempDir()
+	os.WriteFile(filepath.Join(vault, "a.md"), []byte("alpha"), 0644)
+	app.OpenVault(vault)
+	defer app.Shutdown(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			policy := "deny"
+			if i%2 == 0 {
+				policy = "within-vault"
+			}
+			if err := app.SetAppPreferences(internal.AppPreferences{SymlinkPolicy: policy}); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := app.ReadFile("a.md"); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+	wg.Wait()
+}
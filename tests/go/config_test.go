@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func writeVaultConfig(t *testing.T, vault, content string) {
+	os.MkdirAll(filepath.Join(vault, ".chalkmd"), 0755)
+	if err := os.WriteFile(filepath.Join(vault, ".chalkmd", "config.json"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestGetVaultConfig(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.GetVaultConfig(); err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("defaults without a config file", func(t *testing.T) {
+		app, vault := writeVault(t, nil)
+
+		cfg, err := app.GetVaultConfig()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expected := internal.VaultConfig{
+			Version:            2,
+			ImageFolder:        "Z Pasted Images",
+			AutoSaveInterval:   100,
+			IndentSize:         4,
+			TrashMode:          "system",
+			TrashRetentionDays: 30,
+		}
+		if cfg != expected {
+			t.Errorf("Expected %+v, got %+v", expected, cfg)
+		}
+		if _, err := os.Stat(filepath.Join(vault, ".chalkmd", "config.json")); !os.IsNotExist(err) {
+			t.Error("Opening a vault should not write a default config")
+		}
+	})
+
+	t.Run("migrates unversioned config", func(t *testing.T) {
+		vault := t.TempDir()
+		writeVaultConfig(t, vault, `{"imageFolder": "Z Pasted Images\\", "autoSaveInterval": 250, "developmentMode": false, "trashMode": "vault"}`)
+
+		app := &internal.App{}
+		app.OpenVault(vault)
+
+		cfg, _ := app.GetVaultConfig()
+		if cfg.Version != 2 || cfg.ImageFolder != "Z Pasted Images" || cfg.AutoSaveInterval != 250 || cfg.TrashMode != "vault" {
+			t.Errorf("Unexpected migrated config %+v", cfg)
+		}
+
+		var saved map[string]interface{}
+		data, _ := os.ReadFile(filepath.Join(vault, ".chalkmd", "config.json"))
+		json.Unmarshal(data, &saved)
+		if saved["version"] != float64(2) {
+			t.Errorf("Expected migrated config to be saved, got %s", data)
+		}
+		if _, ok := saved["developmentMode"]; ok {
+			t.Error("Obsolete settings should be dropped")
+		}
+	})
+
+	t.Run("invalid values fall back to defaults", func(t *testing.T) {
+		vault := t.TempDir()
+		writeVaultConfig(t, vault, `{"version": 2, "indentSize": 99, "autoSaveInterval": "fast", "imageFolder": "../outside", "trashRetentionDays": 7}`)
+
+		app := &internal.App{}
+		app.OpenVault(vault)
+
+		cfg, _ := app.GetVaultConfig()
+		if cfg.IndentSize != 4 || cfg.AutoSaveInterval != 100 || cfg.ImageFolder != "Z Pasted Images" || cfg.TrashRetentionDays != 7 {
+			t.Errorf("Unexpected config %+v", cfg)
+		}
+	})
+
+	t.Run("corrupt file gives defaults and is kept", func(t *testing.T) {
+		vault := t.TempDir()
+		writeVaultConfig(t, vault, `{not json`)
+
+		app := &internal.App{}
+		app.OpenVault(vault)
+
+		cfg, _ := app.GetVaultConfig()
+		if cfg.AutoSaveInterval != 100 {
+			t.Errorf("Expected defaults, got %+v", cfg)
+		}
+		data, _ := os.ReadFile(filepath.Join(vault, ".chalkmd", "config.json"))
+		if string(data) != `{not json` {
+			t.Error("Corrupt config should not be overwritten on open")
+		}
+	})
+}
+
+func TestUpdateVaultConfig(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.UpdateVaultConfig(map[string]interface{}{"indentSize": 2}); err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("applies and persists patch", func(t *testing.T) {
+		app, vault := writeVault(t, nil)
+
+		cfg, err := app.UpdateVaultConfig(map[string]interface{}{
+			"imageFolder":      `assets\images\`,
+			"autoSaveInterval": float64(500),
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.ImageFolder != "assets/images" || cfg.AutoSaveInterval != 500 || cfg.IndentSize != 4 {
+			t.Errorf("Unexpected config %+v", cfg)
+		}
+
+		app.Shutdown(context.Background())
+		reopened := &internal.App{}
+		reopened.OpenVault(vault)
+		if got, _ := reopened.GetVaultConfig(); got != cfg {
+			t.Errorf("Expected %+v after reopening, got %+v", cfg, got)
+		}
+	})
+
+	t.Run("empty image folder is the vault root", func(t *testing.T) {
+		app, _ := writeVault(t, nil)
+
+		cfg, err := app.UpdateVaultConfig(map[string]interface{}{"imageFolder": "./"})
+		if err != nil || cfg.ImageFolder != "" {
+			t.Errorf("Expected root image folder, got %q %v", cfg.ImageFolder, err)
+		}
+	})
+
+	t.Run("rejects invalid settings without changing anything", func(t *testing.T) {
+		patches := []map[string]interface{}{
+			{"unknown": true},
+			{"version": float64(5)},
+			{"indentSize": "four"},
+			{"indentSize": float64(2.5)},
+			{"autoSaveInterval": float64(0)},
+			{"trashMode": "shredder"},
+			{"imageFolder": "../elsewhere"},
+			{"imageFolder": "/abs"},
+			{"imageFolder": ".chalkmd/images"},
+			{"indentSize": float64(2), "trashRetentionDays": float64(-1)},
+		}
+
+		for _, patch := range patches {
+			app, _ := writeVault(t, nil)
+			before, _ := app.GetVaultConfig()
+
+			_, err := app.UpdateVaultConfig(patch)
+			if !errors.Is(err, internal.ErrInvalidConfig) {
+				t.Errorf("Patch %v: expected ErrInvalidConfig, got %v", patch, err)
+			}
+			if after, _ := app.GetVaultConfig(); after != before {
+				t.Errorf("Patch %v: config should be unchanged", patch)
+			}
+		}
+	})
+
+	t.Run("refuses to overwrite a newer config", func(t *testing.T) {
+		vault := t.TempDir()
+		writeVaultConfig(t, vault, `{"version": 99, "indentSize": 2, "futureSetting": true}`)
+
+		app := &internal.App{}
+		app.OpenVault(vault)
+
+		if cfg, _ := app.GetVaultConfig(); cfg.IndentSize != 2 {
+			t.Errorf("Known settings should still be read, got %+v", cfg)
+		}
+		if _, err := app.UpdateVaultConfig(map[string]interface{}{"indentSize": float64(3)}); !errors.Is(err, internal.ErrConfigTooNew) {
+			t.Errorf("Expected ErrConfigTooNew, got %v", err)
+		}
+	})
+}
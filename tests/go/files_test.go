@@ -130,6 +130,24 @@ func TestCreateFile(t *testing.T) {
 			t.Error("Expected error for path traversal attempt")
 		}
 	})
+	
+	t.Run("refuses an existing note", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("content"), 0644)
+		
+		if _, err := app.CreateFile("note"); err == nil {
+			t.Error("Expected error for an existing note")
+		}
+		if _, err := app.UndoLastFileOperation(); err == nil {
+			t.Error("Expected nothing to undo")
+		}
+		if content, _ := os.ReadFile(filepath.Join(tempDir, "note.md")); string(content) != "content" {
+			t.Errorf("Expected the note to be kept, got %q", content)
+		}
+	})
 }
 
 func TestCreateFolder(t *testing.T) {
@@ -246,6 +264,58 @@ func TestWriteFile(t *testing.T) {
 			t.Error("Expected error for path traversal attempt")
 		}
 	})
+	
+	t.Run("preserves existing permissions", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		filePath := filepath.Join(tempDir, "private.md")
+		os.WriteFile(filePath, []byte("old content"), 0600)
+		os.Chmod(filePath, 0600)
+		
+		err := app.WriteFile("private.md", "new content")
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		
+		info, _ := os.Stat(filePath)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+		}
+	})
+	
+	t.Run("leaves no temp files behind", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		for i := 0; i < 5; i++ {
+			if err := app.WriteFile("note.md", strings.Repeat("x", i)); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		
+		entries, _ := os.ReadDir(tempDir)
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".tmp") {
+				t.Errorf("Temp file %s left in vault", entry.Name())
+			}
+		}
+	})
+	
+	t.Run("refuses to overwrite a directory", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		os.Mkdir(filepath.Join(tempDir, "folder.md"), 0755)
+		
+		err := app.WriteFile("folder.md", "content")
+		if err == nil {
+			t.Error("Expected error when writing over a directory")
+		}
+	})
 }
 
 func TestRenameFile(t *testing.T) {
@@ -302,6 +372,22 @@ func TestRenameFile(t *testing.T) {
 			t.Error("Expected error for path traversal attempt")
 		}
 	})
+	
+	t.Run("refuses to overwrite existing file", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		os.WriteFile(filepath.Join(tempDir, "old.md"), []byte("old"), 0644)
+		os.WriteFile(filepath.Join(tempDir, "new.md"), []byte("new"), 0644)
+		
+		if err := app.RenameFile("old.md", "new.md"); err == nil {
+			t.Error("Expected error when destination exists")
+		}
+		if content, _ := os.ReadFile(filepath.Join(tempDir, "new.md")); string(content) != "new" {
+			t.Errorf("Destination was overwritten with %q", content)
+		}
+	})
 }
 
 func TestMoveFile(t *testing.T) {
@@ -367,4 +453,21 @@ func TestMoveFile(t *testing.T) {
 			t.Error("Expected error for path traversal attempt")
 		}
 	})
+	
+	t.Run("refuses to overwrite existing file", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+		
+		os.WriteFile(filepath.Join(tempDir, "file.md"), []byte("moved"), 0644)
+		os.Mkdir(filepath.Join(tempDir, "folder"), 0755)
+		os.WriteFile(filepath.Join(tempDir, "folder", "file.md"), []byte("kept"), 0644)
+		
+		if err := app.MoveFile("file.md", "folder/file.md"); err == nil {
+			t.Error("Expected error when destination exists")
+		}
+		if content, _ := os.ReadFile(filepath.Join(tempDir, "folder", "file.md")); string(content) != "kept" {
+			t.Errorf("Destination was overwritten with %q", content)
+		}
+	})
 }
\ No newline at end of file
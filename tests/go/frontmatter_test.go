@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestGetNoteProperties(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.GetNoteProperties("note.md")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("typed values in order", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"note.md": "---\n" +
+				"title: My Note # a comment\n" +
+				"rating: 4.5\n" +
+				"created: 2024-01-15\n" +
+				"done: true\n" +
+				"tags:\n  - alpha\n  - \"beta gamma\"\n" +
+				"aliases: [one, 'two']\n" +
+				"empty:\n" +
+				"quoted: \"42\"\n" +
+				"---\nbody",
+		})
+
+		props, err := app.GetNoteProperties("note.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := []internal.NoteProperty{
+			{Key: "title", Type: "text", Value: "My Note"},
+			{Key: "rating", Type: "number", Value: 4.5},
+			{Key: "created", Type: "date", Value: "2024-01-15"},
+			{Key: "done", Type: "checkbox", Value: true},
+			{Key: "tags", Type: "list", Value: []string{"alpha", "beta gamma"}},
+			{Key: "aliases", Type: "list", Value: []string{"one", "two"}},
+			{Key: "empty", Type: "text", Value: nil},
+			{Key: "quoted", Type: "text", Value: "42"},
+		}
+		if !reflect.DeepEqual(props, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, props)
+		}
+	})
+
+	t.Run("only decimals are numbers", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"note.md": "---\na: NaN\nb: Inf\nc: -infinity\nd: 0x1p-2\ne: -1.5e3\nf: .5\n---\n",
+		})
+
+		props, err := app.GetNoteProperties("note.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expected := []internal.NoteProperty{
+			{Key: "a", Type: "text", Value: "NaN"},
+			{Key: "b", Type: "text", Value: "Inf"},
+			{Key: "c", Type: "text", Value: "-infinity"},
+			{Key: "d", Type: "text", Value: "0x1p-2"},
+			{Key: "e", Type: "number", Value: -1500.0},
+			{Key: "f", Type: "number", Value: 0.5},
+		}
+		if !reflect.DeepEqual(props, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, props)
+		}
+		if _, err := json.Marshal(props); err != nil {
+			t.Errorf("Expected properties to encode as JSON, got %v", err)
+		}
+	})
+
+	t.Run("note without frontmatter", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": "just text"})
+
+		props, err := app.GetNoteProperties("note.md")
+		if err != nil || len(props) != 0 {
+			t.Errorf("Expected no properties, got %v %v", props, err)
+		}
+	})
+}
+
+func TestSetNoteProperties(t *testing.T) {
+	t.Run("updates in place and preserves comments and body", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"note.md": "---\n# leading comment\ntitle: Old\nstatus: draft # keep\n---\n\n  Body *stays*\n---\nexactly\n",
+		})
+
+		err := app.SetNoteProperties("note.md", map[string]interface{}{
+			"title": "New: improved",
+			"count": float64(3),
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := "---\n# leading comment\ntitle: \"New: improved\"\nstatus: draft # keep\ncount: 3\n---\n\n  Body *stays*\n---\nexactly\n"
+		if got := readVaultFile(t, vault, "note.md"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("adds frontmatter to a plain note", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "body"})
+
+		err := app.SetNoteProperties("note.md", map[string]interface{}{
+			"tags":    []interface{}{"a", "b"},
+			"done":    false,
+			"created": "2024-02-01",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := "---\ncreated: 2024-02-01\ndone: false\ntags:\n  - a\n  - b\n---\nbody"
+		if got := readVaultFile(t, vault, "note.md"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("round trips ambiguous strings", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": ""})
+
+		values := map[string]interface{}{"a": "true", "b": "12", "c": "", "d": "#hash", "e": "line\nbreak"}
+		app.SetNoteProperties("note.md", values)
+
+		props, _ := app.GetNoteProperties("note.md")
+		for _, p := range props {
+			if p.Type != "text" || p.Value != values[p.Key] {
+				t.Errorf("Property %s did not round trip: %+v", p.Key, p)
+			}
+		}
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": ""})
+
+		if err := app.SetNoteProperties("note.md", map[string]interface{}{"bad: key": "x"}); err == nil {
+			t.Error("Expected error for invalid key")
+		}
+	})
+
+	t.Run("preserves CRLF line endings", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "---\r\na: 1\r\n---\r\nbody\r\n"})
+
+		app.SetNoteProperties("note.md", map[string]interface{}{"b": float64(2)})
+
+		expected := "---\r\na: 1\r\nb: 2\r\n---\r\nbody\r\n"
+		if got := readVaultFile(t, vault, "note.md"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+}
+
+func TestDeleteNoteProperty(t *testing.T) {
+	t.Run("removes key with its list items", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"note.md": "---\ntags:\n  - a\n  - b\ntitle: T\n---\nbody",
+		})
+
+		if err := app.DeleteNoteProperty("note.md", "tags"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if got := readVaultFile(t, vault, "note.md"); got != "---\ntitle: T\n---\nbody" {
+			t.Errorf("Unexpected content %q", got)
+		}
+	})
+
+	t.Run("removes empty frontmatter block", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"note.md": "---\ntitle: T\n---\nbody",
+		})
+
+		app.DeleteNoteProperty("note.md", "title")
+
+		if got := readVaultFile(t, vault, "note.md"); got != "body" {
+			t.Errorf("Expected 'body', got %q", got)
+		}
+	})
+
+	t.Run("missing key is a no-op", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "---\na: 1\n---\n"})
+
+		if err := app.DeleteNoteProperty("note.md", "b"); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "note.md"); got != "---\na: 1\n---\n" {
+			t.Errorf("File should be unchanged, got %q", got)
+		}
+	})
+}
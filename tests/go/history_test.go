@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestListFileVersions(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.ListFileVersions("note.md")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("first save snapshots previous content", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": "original"})
+
+		app.WriteFile("note.md", "original, edited")
+
+		versions, err := app.ListFileVersions("note.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(versions) != 1 {
+			t.Fatalf("Expected 1 version, got %d", len(versions))
+		}
+
+		content, err := app.ReadFileVersion("note.md", versions[0].ID)
+		if err != nil || content != "original" {
+			t.Errorf("Expected 'original', got %q %v", content, err)
+		}
+	})
+
+	t.Run("throttles frequent saves", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": "v0 text"})
+
+		app.WriteFile("note.md", "v1 text")
+		app.WriteFile("note.md", "v2 text")
+		app.WriteFile("note.md", "v3 text")
+
+		versions, _ := app.ListFileVersions("note.md")
+		if len(versions) != 1 {
+			t.Errorf("Expected 1 version within the throttle interval, got %d", len(versions))
+		}
+	})
+
+	t.Run("always snapshots a save that empties the note", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": "a"})
+
+		app.WriteFile("note.md", "a long paragraph of important writing")
+		app.WriteFile("note.md", "")
+
+		versions, _ := app.ListFileVersions("note.md")
+		if len(versions) != 2 {
+			t.Fatalf("Expected 2 versions, got %d", len(versions))
+		}
+		content, _ := app.ReadFileVersion("note.md", versions[0].ID)
+		if content != "a long paragraph of important writing" {
+			t.Errorf("Expected newest version first, got %q", content)
+		}
+	})
+
+	t.Run("ignores non-note files", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"data.txt": "x"})
+
+		app.WriteFile("data.txt", "y")
+
+		versions, _ := app.ListFileVersions("data.txt")
+		if len(versions) != 0 {
+			t.Errorf("Expected no versions, got %d", len(versions))
+		}
+	})
+
+	t.Run("survives reopening the vault", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "before"})
+		app.WriteFile("note.md", "after")
+		app.Shutdown(context.Background())
+
+		reopened := &internal.App{}
+		reopened.OpenVault(vault)
+
+		versions, _ := reopened.ListFileVersions("note.md")
+		if len(versions) != 1 {
+			t.Fatalf("Expected 1 version after reopening, got %d", len(versions))
+		}
+		content, _ := reopened.ReadFileVersion("note.md", versions[0].ID)
+		if content != "before" {
+			t.Errorf("Expected 'before', got %q", content)
+		}
+	})
+
+	t.Run("follows renamed and moved notes", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"dir/note.md": "first"})
+		app.WriteFile("dir/note.md", "second")
+
+		app.RenameFile("dir/note.md", "dir/renamed.md")
+		app.MoveFile("dir", "archive/dir")
+
+		versions, _ := app.ListFileVersions(filepath.Join("archive", "dir", "renamed.md"))
+		if len(versions) != 1 {
+			t.Fatalf("Expected history to follow the note, got %d versions", len(versions))
+		}
+		old, _ := app.ListFileVersions(filepath.Join("dir", "note.md"))
+		if len(old) != 0 {
+			t.Errorf("Expected no history at the old path, got %d", len(old))
+		}
+	})
+
+	t.Run("stores identical content once", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"a.md": "same text here", "b.md": "same text here"})
+
+		app.WriteFile("a.md", "")
+		app.WriteFile("b.md", "")
+
+		var objects int
+		filepath.Walk(filepath.Join(vault, ".chalkmd", "history", "objects"), func(p string, info os.FileInfo, err error) error {
+			if err == nil && strings.HasSuffix(p, ".gz") {
+				objects++
+			}
+			return nil
+		})
+		if objects != 1 {
+			t.Errorf("Expected 1 stored object, got %d", objects)
+		}
+	})
+}
+
+func TestReadFileVersion(t *testing.T) {
+	t.Run("unknown version", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"note.md": "text"})
+
+		if _, err := app.ReadFileVersion("note.md", "12345"); err == nil {
+			t.Error("Expected error for unknown version")
+		}
+	})
+}
+
+func TestRestoreFileVersion(t *testing.T) {
+	t.Run("restores and keeps the replaced content", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "precious notes"})
+		app.WriteFile("note.md", "")
+
+		versions, _ := app.ListFileVersions("note.md")
+		if err := app.RestoreFileVersion("note.md", versions[0].ID); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if got := readVaultFile(t, vault, "note.md"); got != "precious notes" {
+			t.Errorf("Expected restored content, got %q", got)
+		}
+
+		versions, _ = app.ListFileVersions("note.md")
+		if len(versions) != 2 {
+			t.Fatalf("Expected 2 versions after restore, got %d", len(versions))
+		}
+		content, _ := app.ReadFileVersion("note.md", versions[0].ID)
+		if content != "" {
+			t.Errorf("Expected the replaced content to be recorded, got %q", content)
+		}
+	})
+
+	t.Run("unknown version leaves file alone", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"note.md": "text"})
+
+		if err := app.RestoreFileVersion("note.md", "nope"); err == nil {
+			t.Error("Expected error for unknown version")
+		}
+		if got := readVaultFile(t, vault, "note.md"); got != "text" {
+			t.Errorf("File should be unchanged, got %q", got)
+		}
+	})
+}
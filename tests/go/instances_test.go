@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"chalkmd/internal"
+)
+
+// listeningApp returns an app that listens for other instances, with vault
+// open if it is not "". Sockets go to a temporary runtime directory.
+func listeningApp(t *testing.T, vault string) *internal.App {
+	t.Helper()
+	app := &internal.App{}
+	if vault != "" {
+		if err := app.OpenVault(vault); err != nil {
+			t.Fatalf("Failed to open vault: %v", err)
+		}
+	}
+	if err := app.ListenForInstances(); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { app.Shutdown(context.Background()) })
+	return app
+}
+
+// runtimeDir points the instance sockets to a new directory with a short
+// path, as socket paths are limited to about 100 bytes.
+func runtimeDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "ipc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	return dir
+}
+
+func TestListInstances(t *testing.T) {
+	t.Run("finds other instances and their vaults", func(t *testing.T) {
+		runtimeDir(t)
+		vault := t.TempDir()
+		self := listeningApp(t, "")
+		listeningApp(t, vault)
+
+		instances, err := self.ListInstances()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(instances) != 1 || instances[0].Vault != vault || instances[0].PID != os.Getpid() {
+			t.Errorf("Unexpected instances %+v", instances)
+		}
+	})
+
+	t.Run("vault changes are reported", func(t *testing.T) {
+		runtimeDir(t)
+		self := listeningApp(t, "")
+		other := listeningApp(t, t.TempDir())
+
+		next := t.TempDir()
+		other.OpenVault(next)
+		instances, _ := self.ListInstances()
+		if len(instances) != 1 || instances[0].Vault != next {
+			t.Errorf("Expected %s, got %+v", next, instances)
+		}
+	})
+
+	t.Run("stale sockets are removed", func(t *testing.T) {
+		dir := runtimeDir(t)
+		self := listeningApp(t, "")
+
+		stale := filepath.Join(dir, "chalkmd", "1-1.sock")
+		l, err := net.Listen("unix", stale)
+		if err != nil {
+			t.Fatalf("Failed to create socket: %v", err)
+		}
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+
+		instances, _ := self.ListInstances()
+		if len(instances) != 0 {
+			t.Errorf("Expected no instances, got %+v", instances)
+		}
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Error("Stale socket should be removed")
+		}
+	})
+
+	t.Run("shutdown stops listening", func(t *testing.T) {
+		dir := runtimeDir(t)
+		app := &internal.App{}
+		app.ListenForInstances()
+		app.Shutdown(context.Background())
+
+		sockets, _ := filepath.Glob(filepath.Join(dir, "chalkmd", "*.sock"))
+		if len(sockets) != 0 {
+			t.Errorf("Expected socket to be removed, got %v", sockets)
+		}
+	})
+}
+
+func TestHandOffLaunch(t *testing.T) {
+	runtimeDir(t)
+	vault := t.TempDir()
+	other := listeningApp(t, vault)
+
+	t.Run("instance with the vault takes the request", func(t *testing.T) {
+		req, _ := internal.ParseLaunchArgs([]string{"--vault", vault, "Idea.md"})
+		if !internal.HandOffLaunch(req) {
+			t.Fatal("Expected the request to be handed off")
+		}
+		got := other.GetLaunchRequest()
+		if got == nil || got.File != "Idea.md" {
+			t.Errorf("Unexpected request %+v", got)
+		}
+	})
+
+	t.Run("no instance with the vault", func(t *testing.T) {
+		req, _ := internal.ParseLaunchArgs([]string{"--vault", t.TempDir()})
+		if internal.HandOffLaunch(req) {
+			t.Error("Expected no instance to take the request")
+		}
+		if internal.HandOffLaunch(nil) {
+			t.Error("Expected nothing to hand off without a request")
+		}
+	})
+}
+
+func TestSaveAnnouncements(t *testing.T) {
+	runtimeDir(t)
+	vault := t.TempDir()
+	writer := listeningApp(t, vault)
+	reader := &internal.App{}
+	if err := reader.OpenVaultReadOnly(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	reader.ListenForInstances()
+	defer reader.Shutdown(context.Background())
+
+	if err := writer.WriteFile("Note.md", "a unicorn appears"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the reader has no watcher, so only the announcement updates its index
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		results, _ := reader.SearchVault("unicorn", internal.SearchOptions{})
+		if len(results) == 1 && results[0].Path == "Note.md" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Save was not announced, got %+v", results)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestUndoLastFileOperation(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.UndoLastFileOperation(); err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("nothing to undo", func(t *testing.T) {
+		app, _ := writeVault(t, nil)
+
+		if _, err := app.UndoLastFileOperation(); !errors.Is(err, internal.ErrNothingToUndo) {
+			t.Errorf("Expected ErrNothingToUndo, got %v", err)
+		}
+		if _, err := app.RedoFileOperation(); !errors.Is(err, internal.ErrNothingToRedo) {
+			t.Errorf("Expected ErrNothingToRedo, got %v", err)
+		}
+	})
+
+	t.Run("create file", func(t *testing.T) {
+		app, vault := writeVault(t, nil)
+		app.CreateFile("new.md")
+
+		op, err := app.UndoLastFileOperation()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if op.Kind != internal.OpCreateFile || op.Path != "new.md" {
+			t.Errorf("Unexpected operation %+v", op)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "new.md")); !os.IsNotExist(err) {
+			t.Error("Created file should be removed")
+		}
+
+		if _, err := app.RedoFileOperation(); err != nil {
+			t.Fatalf("Expected no error on redo, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "new.md")); err != nil {
+			t.Error("File should exist again after redo")
+		}
+	})
+
+	t.Run("refuses to undo create of an edited file", func(t *testing.T) {
+		app, vault := writeVault(t, nil)
+		app.CreateFile("new.md")
+		app.WriteFile("new.md", "keep me")
+
+		if _, err := app.UndoLastFileOperation(); !errors.Is(err, internal.ErrOperationConflict) {
+			t.Errorf("Expected ErrOperationConflict, got %v", err)
+		}
+		if readVaultFile(t, vault, "new.md") != "keep me" {
+			t.Error("Edited file should be left alone")
+		}
+	})
+
+	t.Run("create folder", func(t *testing.T) {
+		app, vault := writeVault(t, nil)
+		app.CreateFolder("dir")
+
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "dir")); !os.IsNotExist(err) {
+			t.Error("Created folder should be removed")
+		}
+	})
+
+	t.Run("rename and redo", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"a.md": "alpha"})
+		app.RenameFile("a.md", "b.md")
+
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if readVaultFile(t, vault, "a.md") != "alpha" {
+			t.Error("File should be back at its old name")
+		}
+
+		if _, err := app.RedoFileOperation(); err != nil {
+			t.Fatalf("Expected no error on redo, got %v", err)
+		}
+		if readVaultFile(t, vault, "b.md") != "alpha" {
+			t.Error("File should be renamed again")
+		}
+	})
+
+	t.Run("moved folder comes back", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"dir/a.md": "alpha", "dir/sub/b.md": "beta"})
+		app.MoveFile("dir", filepath.Join("archive", "dir"))
+
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if readVaultFile(t, vault, "dir/sub/b.md") != "beta" {
+			t.Error("Folder should be back in place")
+		}
+	})
+
+	t.Run("move with links restores link text", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"a.md":     "alpha",
+			"index.md": "see [[a]]",
+		})
+		app.MoveFileWithLinks("a.md", filepath.Join("sub", "a.md"))
+		app.CreateFile("other2.md")
+		app.UndoLastFileOperation()
+
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if readVaultFile(t, vault, "index.md") != "see [[a]]" {
+			t.Errorf("Link should be restored, got %q", readVaultFile(t, vault, "index.md"))
+		}
+		if readVaultFile(t, vault, "a.md") != "alpha" {
+			t.Error("File should be back at the root")
+		}
+	})
+
+	t.Run("refuses when a rewritten note was edited", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"a.md": "alpha", "index.md": "see [[a]]"})
+		app.RenameFileWithLinks("a.md", "b.md")
+		os.WriteFile(filepath.Join(vault, "index.md"), []byte("see [[b]] and more"), 0644)
+
+		if _, err := app.UndoLastFileOperation(); !errors.Is(err, internal.ErrOperationConflict) {
+			t.Errorf("Expected ErrOperationConflict, got %v", err)
+		}
+		if readVaultFile(t, vault, "b.md") != "alpha" {
+			t.Error("Renamed file should stay where it is")
+		}
+	})
+
+	t.Run("refuses when destination is taken", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"a.md": "alpha"})
+		app.RenameFile("a.md", "b.md")
+		os.WriteFile(filepath.Join(vault, "a.md"), []byte("new"), 0644)
+
+		if _, err := app.UndoLastFileOperation(); !errors.Is(err, internal.ErrOperationConflict) {
+			t.Errorf("Expected ErrOperationConflict, got %v", err)
+		}
+	})
+
+	t.Run("delete to vault trash", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"dir/a.md": "alpha"})
+		app.DeleteFile("dir")
+
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if readVaultFile(t, vault, "dir/a.md") != "alpha" {
+			t.Error("Deleted folder should be restored")
+		}
+		if items, _ := app.ListTrash(); len(items) != 0 {
+			t.Errorf("Trash should be empty after undo, got %d items", len(items))
+		}
+
+		if _, err := app.RedoFileOperation(); err != nil {
+			t.Fatalf("Expected no error on redo, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "dir")); !os.IsNotExist(err) {
+			t.Error("Folder should be deleted again")
+		}
+	})
+}
+
+func TestJournalMemory(t *testing.T) {
+	t.Run("oldest content is let go", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_DATA_HOME", home)
+		data := strings.Repeat("x", 25<<20)
+		app, _ := writeVault(t, map[string]string{"a.bin": data, "b.bin": data, "c.bin": data})
+		defer app.Shutdown(context.Background())
+
+		for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+			if err := app.DeleteFile(name); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+
+		// three deletes keep 75 MB, so the oldest gives its content up
+		ops, _ := app.ListRecentOperations()
+		if len(ops) != 3 || !ops[0].Undoable || !ops[1].Undoable || ops[2].Undoable {
+			t.Errorf("Expected only the oldest delete to be no longer undoable, got %+v", ops)
+		}
+	})
+
+	t.Run("only the oldest entries are let go", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_DATA_HOME", home)
+		data := strings.Repeat("x", 25<<20)
+		app, _ := writeVault(t, map[string]string{"a.bin": data, "b.bin": data, "c.bin": data, "n.md": "", "index.md": "[[n]]"})
+		defer app.Shutdown(context.Background())
+
+		app.CreateFolder("dir")
+		app.RenameFileWithLinks("n.md", "renamed.md")
+		for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+			app.DeleteFile(name)
+		}
+
+		// the rename holds content but is older than the first delete
+		ops, _ := app.ListRecentOperations()
+		if len(ops) != 3 || ops[2].Kind != internal.OpDelete || ops[2].Undoable {
+			t.Errorf("Expected the entries before the oldest delete to be dropped, got %+v", ops)
+		}
+	})
+}
+
+func TestListRecentOperations(t *testing.T) {
+	t.Run("newest first and redo cleared by new operations", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"a.md": "alpha"})
+		app.CreateFolder("dir")
+		app.MoveFile("a.md", filepath.Join("dir", "a.md"))
+
+		ops, err := app.ListRecentOperations()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(ops) != 2 || ops[0].Kind != internal.OpMove || ops[1].Kind != internal.OpCreateFolder {
+			t.Fatalf("Unexpected operations %+v", ops)
+		}
+		if ops[0].NewPath != filepath.Join("dir", "a.md") || !ops[0].Undoable {
+			t.Errorf("Unexpected move entry %+v", ops[0])
+		}
+
+		app.UndoLastFileOperation()
+		app.CreateFile("b.md")
+
+		if _, err := app.RedoFileOperation(); !errors.Is(err, internal.ErrNothingToRedo) {
+			t.Errorf("Expected redo history to be cleared, got %v", err)
+		}
+		ops, _ = app.ListRecentOperations()
+		if len(ops) != 2 {
+			t.Errorf("Expected 2 operations, got %d", len(ops))
+		}
+	})
+}
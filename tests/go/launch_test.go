@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestParseLaunchArgs(t *testing.T) {
+	vault := t.TempDir()
+
+	t.Run("no arguments", func(t *testing.T) {
+		req, err := internal.ParseLaunchArgs(nil)
+		if err != nil || req != nil {
+			t.Errorf("Expected nothing to open, got %+v, %v", req, err)
+		}
+		req, err = internal.ParseLaunchArgs([]string{"-psn_0_12345"})
+		if err != nil || req != nil {
+			t.Errorf("Expected process serial number to be ignored, got %+v, %v", req, err)
+		}
+	})
+
+	t.Run("vault and file with heading", func(t *testing.T) {
+		req, err := internal.ParseLaunchArgs([]string{"--vault", vault, "Notes/Idea#Next steps"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if req.Vault != vault || req.File != "Notes/Idea.md" || req.Heading != "Next steps" {
+			t.Errorf("Unexpected request %+v", req)
+		}
+	})
+
+	t.Run("absolute file inside the vault", func(t *testing.T) {
+		req, err := internal.ParseLaunchArgs([]string{"--vault=" + vault, filepath.Join(vault, "a.png")})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if req.File != "a.png" {
+			t.Errorf("Expected a.png, got %q", req.File)
+		}
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		cases := [][]string{
+			{"Idea.md"},
+			{"--vault", filepath.Join(vault, "missing")},
+			{"--vault", vault, "../outside.md"},
+			{"--vault", vault, filepath.Join(t.TempDir(), "outside.md")},
+			{"--vault", vault, "a.md", "b.md"},
+			{"--unknown"},
+		}
+		for _, args := range cases {
+			if _, err := internal.ParseLaunchArgs(args); !errors.Is(err, internal.ErrInvalidLaunch) {
+				t.Errorf("Expected ErrInvalidLaunch for %v, got %v", args, err)
+			}
+		}
+	})
+
+	t.Run("chalkmd URI", func(t *testing.T) {
+		uri := "chalkmd://open?vault=" + url.QueryEscape(vault) + "&file=" + url.QueryEscape("Daily/2024-01-01.md") + "#Tasks"
+		req, err := internal.ParseLaunchArgs([]string{uri})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if req.Vault != vault || req.File != "Daily/2024-01-01.md" || req.Heading != "Tasks" {
+			t.Errorf("Unexpected request %+v", req)
+		}
+
+		req, err = internal.ParseLaunchArgs([]string{"chalkmd://open?vault=" + url.QueryEscape(vault) + "&file=Idea&heading=Intro"})
+		if err != nil || req.File != "Idea.md" || req.Heading != "Intro" {
+			t.Errorf("Unexpected request %+v, %v", req, err)
+		}
+	})
+
+	t.Run("invalid URIs", func(t *testing.T) {
+		cases := []string{
+			"chalkmd://delete?vault=" + url.QueryEscape(vault),
+			"chalkmd://open?file=Idea.md",
+			"chalkmd://open?vault=relative/path",
+			"chalkmd://open?vault=" + url.QueryEscape(vault) + "&file=" + url.QueryEscape("../../etc/passwd"),
+		}
+		for _, uri := range cases {
+			if _, err := internal.ParseLaunchArgs([]string{uri}); !errors.Is(err, internal.ErrInvalidLaunch) {
+				t.Errorf("Expected ErrInvalidLaunch for %s, got %v", uri, err)
+			}
+		}
+	})
+}
+
+func TestGetLaunchRequest(t *testing.T) {
+	t.Run("returned once", func(t *testing.T) {
+		vault := t.TempDir()
+		req, _ := internal.ParseLaunchArgs([]string{"--vault", vault})
+		app := internal.NewAppWithLaunch(req)
+
+		got := app.GetLaunchRequest()
+		if got == nil || got.Vault != vault {
+			t.Fatalf("Expected launch request for %s, got %+v", vault, got)
+		}
+		if again := app.GetLaunchRequest(); again != nil {
+			t.Errorf("Expected request to be consumed, got %+v", again)
+		}
+	})
+
+	t.Run("URI while running", func(t *testing.T) {
+		app := &internal.App{}
+		if app.GetLaunchRequest() != nil {
+			t.Error("Expected no launch request")
+		}
+
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "Idea.md"), []byte("# Idea"), 0644)
+		if err := app.HandleLaunchURI("chalkmd://open?vault=" + url.QueryEscape(vault) + "&file=Idea.md"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		got := app.GetLaunchRequest()
+		if got == nil || got.File != "Idea.md" {
+			t.Errorf("Unexpected request %+v", got)
+		}
+
+		if err := app.HandleLaunchURI("chalkmd://open"); err == nil {
+			t.Error("Expected error for URI without a vault")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestGetBacklinks(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.GetBacklinks("note.md")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("finds links with line and context", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"target.md":        "",
+			"a.md":             "intro\nsee [[target]] here",
+			"folder/b.md":      "![[target|embed]]",
+			"folder/target.md": "a different target",
+			"c.md":             "[[folder/target]]",
+		})
+
+		links, err := app.GetBacklinks("target.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(links) != 2 {
+			t.Fatalf("Expected 2 backlinks, got %+v", links)
+		}
+		if links[0].SourcePath != "a.md" || links[0].Line != 2 || links[0].Context != "see [[target]] here" {
+			t.Errorf("Unexpected first backlink: %+v", links[0])
+		}
+		if links[1].SourcePath != filepath.Join("folder", "b.md") || !links[1].Embed {
+			t.Errorf("Unexpected second backlink: %+v", links[1])
+		}
+	})
+
+	t.Run("follows writes", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"target.md": "",
+			"a.md":      "",
+		})
+
+		app.WriteFile("a.md", "[[target]]")
+		links, _ := app.GetBacklinks("target.md")
+		if len(links) != 1 {
+			t.Errorf("Expected 1 backlink after write, got %d", len(links))
+		}
+
+		app.WriteFile("a.md", "no links")
+		links, _ = app.GetBacklinks("target.md")
+		if len(links) != 0 {
+			t.Errorf("Expected 0 backlinks after removing link, got %d", len(links))
+		}
+	})
+
+	t.Run("follows renames", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"target.md": "",
+			"a.md":      "[[target]]",
+		})
+
+		app.RenameFileWithLinks("target.md", "renamed.md")
+
+		links, _ := app.GetBacklinks("renamed.md")
+		if len(links) != 1 || links[0].Target != "renamed" {
+			t.Errorf("Expected rewritten backlink to renamed.md, got %+v", links)
+		}
+	})
+}
+
+func TestGetOutgoingLinks(t *testing.T) {
+	t.Run("resolves targets", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"note.md":        "[[exists#Heading|alias]] and [[missing]]",
+			"deep/exists.md": "",
+		})
+
+		links, err := app.GetOutgoingLinks("note.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(links) != 2 {
+			t.Fatalf("Expected 2 links, got %+v", links)
+		}
+		if links[0].TargetPath != filepath.Join("deep", "exists.md") || links[0].Heading != "Heading" || links[0].Alias != "alias" {
+			t.Errorf("Unexpected first link: %+v", links[0])
+		}
+		if links[1].TargetPath != "" {
+			t.Errorf("Expected unresolved link, got %+v", links[1])
+		}
+	})
+
+	t.Run("new file resolves existing link", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"note.md": "[[later]]",
+		})
+
+		app.CreateFile("later.md")
+
+		links, _ := app.GetOutgoingLinks("note.md")
+		if len(links) != 1 || links[0].TargetPath != "later.md" {
+			t.Errorf("Expected link to resolve to later.md, got %+v", links)
+		}
+	})
+}
+
+func TestGetUnlinkedMentions(t *testing.T) {
+	app, _ := writeVault(t, map[string]string{
+		"Project X.md": "",
+		"a.md":         "Working on project x today.\n[[Project X]] is linked.",
+		"b.md":         "`Project X` in code, Project Xylophone is not a mention",
+	})
+
+	mentions, err := app.GetUnlinkedMentions("Project X.md")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mentions) != 1 {
+		t.Fatalf("Expected 1 mention, got %+v", mentions)
+	}
+	if mentions[0].SourcePath != "a.md" || mentions[0].Line != 1 || mentions[0].Target != "project x" {
+		t.Errorf("Unexpected mention: %+v", mentions[0])
+	}
+}
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"chalkmd/internal"
+)
+
+// writeLock fakes a lock left by another process.
+func writeLock(t *testing.T, vault string, pid int, host string, heartbeat time.Time) {
+	t.Helper()
+	os.MkdirAll(filepath.Join(vault, ".chalkmd"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{
+		"pid":       pid,
+		"hostname":  host,
+		"started":   heartbeat,
+		"heartbeat": heartbeat,
+		"token":     "other",
+	})
+	os.WriteFile(filepath.Join(vault, ".chalkmd", "lock"), data, 0644)
+}
+
+func TestVaultLock(t *testing.T) {
+	t.Run("open vault is locked", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		if err := app.OpenVault(vault); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer app.Shutdown(context.Background())
+
+		lock, err := app.GetVaultLock(vault)
+		if err != nil || lock == nil || lock.PID != os.Getpid() {
+			t.Fatalf("Expected lock held by this process, got %+v, %v", lock, err)
+		}
+
+		other := &internal.App{}
+		err = other.OpenVault(vault)
+		var inUse *internal.VaultInUseError
+		if !errors.Is(err, internal.ErrVaultInUse) || !errors.As(err, &inUse) {
+			t.Fatalf("Expected vault in use, got %v", err)
+		}
+		if inUse.Lock.PID != os.Getpid() {
+			t.Errorf("Expected holder pid %d, got %+v", os.Getpid(), inUse.Lock)
+		}
+		if other.GetVaultPath() != "" {
+			t.Error("Vault should not be opened")
+		}
+	})
+
+	t.Run("reopening in the same app", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		if err := app.OpenVault(vault); err != nil {
+			t.Errorf("Expected reopening to succeed, got %v", err)
+		}
+		app.Shutdown(context.Background())
+	})
+
+	t.Run("released on shutdown", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		app.Shutdown(context.Background())
+
+		if _, err := os.Stat(filepath.Join(vault, ".chalkmd", "lock")); !os.IsNotExist(err) {
+			t.Error("Lock file should be removed")
+		}
+		other := &internal.App{}
+		if err := other.OpenVault(vault); err != nil {
+			t.Errorf("Expected vault to be free, got %v", err)
+		}
+		other.Shutdown(context.Background())
+	})
+
+	t.Run("failed open keeps the current vault", func(t *testing.T) {
+		locked := t.TempDir()
+		holder := &internal.App{}
+		holder.OpenVault(locked)
+		defer holder.Shutdown(context.Background())
+
+		current := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(current)
+		defer app.Shutdown(context.Background())
+		if err := app.OpenVault(locked); err == nil {
+			t.Fatal("Expected vault in use")
+		}
+		if app.GetVaultPath() != current {
+			t.Errorf("Expected %s to stay open, got %s", current, app.GetVaultPath())
+		}
+	})
+
+	t.Run("stale locks", func(t *testing.T) {
+		host, _ := os.Hostname()
+		cases := map[string]func(vault string){
+			"dead process":      func(v string) { writeLock(t, v, 0x7ffffff0, host, time.Now()) },
+			"no heartbeat":      func(v string) { writeLock(t, v, os.Getppid(), "elsewhere", time.Now().Add(-time.Hour)) },
+			"unreadable":        func(v string) { writeLock(t, v, 0, "", time.Time{}) },
+			"live on this host": func(v string) { writeLock(t, v, os.Getppid(), host, time.Now()) },
+		}
+		for name, setup := range cases {
+			vault := t.TempDir()
+			setup(vault)
+			app := &internal.App{}
+			if err := app.OpenVault(vault); err != nil {
+				t.Errorf("%s: expected stale lock to be replaced, got %v", name, err)
+			}
+			app.Shutdown(context.Background())
+		}
+	})
+
+	t.Run("live lock from another host", func(t *testing.T) {
+		vault := t.TempDir()
+		writeLock(t, vault, 42, "elsewhere", time.Now())
+
+		app := &internal.App{}
+		var inUse *internal.VaultInUseError
+		if err := app.OpenVault(vault); !errors.As(err, &inUse) || inUse.Lock.Hostname != "elsewhere" {
+			t.Fatalf("Expected vault in use on elsewhere, got %v", err)
+		}
+		if lock, _ := app.GetVaultLock(vault); lock == nil || lock.PID != 42 {
+			t.Errorf("Expected lock info for pid 42, got %+v", lock)
+		}
+	})
+}
+
+func TestOpenVaultReadOnly(t *testing.T) {
+	vault := t.TempDir()
+	os.WriteFile(filepath.Join(vault, "note.md"), []byte("hello"), 0644)
+	holder := &internal.App{}
+	holder.OpenVault(vault)
+	defer holder.Shutdown(context.Background())
+
+	app := &internal.App{}
+	if err := app.OpenVaultReadOnly(vault); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer app.Shutdown(context.Background())
+
+	if !app.IsVaultReadOnly() || holder.IsVaultReadOnly() {
+		t.Error("Only the read-only app should report read-only")
+	}
+	if content, err := app.ReadFile("note.md"); err != nil || content != "hello" {
+		t.Errorf("Expected to read note, got %q, %v", content, err)
+	}
+	if results, _ := app.SearchVault("hello", internal.SearchOptions{}); len(results) != 1 {
+		t.Errorf("Expected search to work, got %+v", results)
+	}
+
+	writes := map[string]error{
+		"WriteFile":    app.WriteFile("note.md", "changed"),
+		"CreateFolder": app.CreateFolder("dir"),
+		"DeleteFile":   app.DeleteFile("note.md"),
+		"RenameFile":   app.RenameFile("note.md", "other.md"),
+	}
+	_, writes["CreateFile"] = app.CreateFile("new.md")
+	_, writes["UpdateVaultConfig"] = app.UpdateVaultConfig(map[string]interface{}{"indentSize": float64(2)})
+	_, writes["MoveFileWithLinks"] = app.MoveFileWithLinks("note.md", "dir/note.md")
+	for name, err := range writes {
+		if !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(vault, "note.md")); string(data) != "hello" {
+		t.Errorf("Vault should be unchanged, got %q", data)
+	}
+
+	if lock, _ := app.GetVaultLock(vault); lock == nil || lock.PID != os.Getpid() {
+		t.Errorf("Lock should stay with the holder, got %+v", lock)
+	}
+}
+
+func TestOpenVaultReadOnlyWritesNothing(t *testing.T) {
+	vault := t.TempDir()
+	os.WriteFile(filepath.Join(vault, "note.md"), []byte("hello"), 0644)
+	os.MkdirAll(filepath.Join(vault, ".chalkmd", "history"), 0755)
+	os.WriteFile(filepath.Join(vault, ".chalkmd", "config.json"), []byte(`{"trashMode": "vault"}`), 0644)
+	old := `{"version": 1, "notes": {"note.md": [{"id": "1", "time": "2000-01-01T00:00:00Z", "hash": "` + strings.Repeat("a", 64) + `", "size": 1}]}}`
+	os.WriteFile(filepath.Join(vault, ".chalkmd", "history", "manifest.json"), []byte(old), 0644)
+
+	files := func() map[string]string {
+		found := map[string]string{}
+		filepath.Walk(filepath.Join(vault, ".chalkmd"), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				data, _ := os.ReadFile(path)
+				found[path] = string(data)
+			}
+			return nil
+		})
+		return found
+	}
+	before := files()
+
+	app := &internal.App{}
+	if err := app.OpenVaultReadOnly(vault); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if results, _ := app.SearchVault("hello", internal.SearchOptions{}); len(results) != 1 {
+		t.Errorf("Expected search to work, got %+v", results)
+	}
+	if cfg, _ := app.GetVaultConfig(); cfg.TrashMode != "vault" {
+		t.Errorf("Expected the config to be read, got %+v", cfg)
+	}
+	app.Shutdown(context.Background())
+
+	after := files()
+	if len(after) != len(before) {
+		t.Errorf("Expected no files to be added, got %v", after)
+	}
+	for path, data := range before {
+		if after[path] != data {
+			t.Errorf("%s was changed to %q", path, after[path])
+		}
+	}
+}
+
+func TestTakeOverVault(t *testing.T) {
+	vault := t.TempDir()
+	holder := &internal.App{}
+	holder.OpenVault(vault)
+	defer holder.Shutdown(context.Background())
+
+	app := &internal.App{}
+	if err := app.TakeOverVault(vault); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if app.IsVaultReadOnly() {
+		t.Error("Taken over vault should be writable")
+	}
+	if err := app.WriteFile("note.md", "mine"); err != nil {
+		t.Errorf("Expected write to succeed, got %v", err)
+	}
+	app.Shutdown(context.Background())
+
+	if _, err := os.Stat(filepath.Join(vault, ".chalkmd", "lock")); !os.IsNotExist(err) {
+		t.Error("Lock file should be removed by the last holder")
+	}
+}
+
+func TestTakenOverVaultStaysInUse(t *testing.T) {
+	vault := t.TempDir()
+	holder := &internal.App{}
+	holder.OpenVault(vault)
+
+	taker := &internal.App{}
+	if err := taker.TakeOverVault(vault); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer taker.Shutdown(context.Background())
+	holder.Shutdown(context.Background())
+
+	third := &internal.App{}
+	if err := third.OpenVault(vault); !errors.Is(err, internal.ErrVaultInUse) {
+		t.Errorf("Expected ErrVaultInUse while the taker has the vault open, got %v", err)
+	}
+	third.Shutdown(context.Background())
+}
+
+func TestCLIWithVaultInUse(t *testing.T) {
+	runtimeDir(t)
+	vault := t.TempDir()
+	os.WriteFile(filepath.Join(vault, "note.md"), []byte("[[missing]]"), 0644)
+	holder := &internal.App{}
+	holder.OpenVault(vault)
+	defer holder.Shutdown(context.Background())
+
+	if code, _, _ := runCLI(t, vault, "", "check"); code != internal.ExitIssues {
+		t.Errorf("Expected check to run read-only, got exit code %d", code)
+	}
+
+	code, _, errOut := runCLI(t, vault, "", "append", "note.md", "more")
+	if code != internal.ExitInUse || !strings.Contains(errOut, "in use") {
+		t.Errorf("Expected append to fail, got %d %q", code, errOut)
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestCreateVault(t *testing.T) {
+	t.Run("empty vault is created and opened", func(t *testing.T) {
+		app := &internal.App{}
+		parent := t.TempDir()
+
+		vaultPath, err := app.CreateVault(parent, "  My Vault ", "empty")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if vaultPath != filepath.Join(parent, "My Vault") {
+			t.Errorf("Unexpected vault path %q", vaultPath)
+		}
+		if app.GetVaultPath() != vaultPath {
+			t.Error("New vault should be opened")
+		}
+		if _, err := os.Stat(filepath.Join(vaultPath, ".chalkmd", "config.json")); err != nil {
+			t.Error("Vault config should be created")
+		}
+		files, _ := app.ListVaultContents()
+		if len(files) != 0 {
+			t.Errorf("Expected no files, got %+v", files)
+		}
+	})
+
+	t.Run("built-in templates", func(t *testing.T) {
+		expected := map[string][]string{
+			"journal":      {"Welcome.md", "Journal", "Templates/Daily note.md"},
+			"zettelkasten": {"Welcome.md", "Inbox", "Notes/Index.md", "Sources"},
+			"project-wiki": {"Home.md", "Overview.md", "Meetings", "Decisions", "Templates/Meeting notes.md"},
+		}
+
+		app := &internal.App{}
+		for _, template := range app.ListVaultTemplates() {
+			if template == "empty" {
+				continue
+			}
+			paths, ok := expected[template]
+			if !ok {
+				t.Errorf("Untested template %q", template)
+				continue
+			}
+
+			vaultPath, err := app.CreateVault(t.TempDir(), "vault", template)
+			if err != nil {
+				t.Fatalf("Template %s: expected no error, got %v", template, err)
+			}
+			for _, p := range paths {
+				if _, err := os.Stat(filepath.Join(vaultPath, filepath.FromSlash(p))); err != nil {
+					t.Errorf("Template %s: missing %s", template, p)
+				}
+			}
+			filepath.Walk(vaultPath, func(p string, info os.FileInfo, err error) error {
+				if err == nil && info.Name() == ".keep" {
+					t.Errorf("Template %s: placeholder copied to %s", template, p)
+				}
+				return nil
+			})
+		}
+	})
+
+	t.Run("existing vault as template", func(t *testing.T) {
+		source, _ := writeVault(t, map[string]string{
+			"note.md":         "hello",
+			"folder/other.md": "other",
+			".trash/old.md":   "trashed",
+		})
+		source.UpdateVaultConfig(map[string]interface{}{"indentSize": float64(2)})
+		source.WriteFile("note.md", "hello again")
+
+		app := &internal.App{}
+		vaultPath, err := app.CreateVault(t.TempDir(), "copy", source.GetVaultPath())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if readVaultFile(t, vaultPath, "folder/other.md") != "other" {
+			t.Error("Notes should be copied")
+		}
+		if _, err := os.Stat(filepath.Join(vaultPath, ".trash")); !os.IsNotExist(err) {
+			t.Error("Trash should not be copied")
+		}
+		if _, err := os.Stat(filepath.Join(vaultPath, ".chalkmd", "history")); !os.IsNotExist(err) {
+			t.Error("History should not be copied")
+		}
+		if cfg, _ := app.GetVaultConfig(); cfg.IndentSize != 2 {
+			t.Errorf("Config should be copied, got %+v", cfg)
+		}
+	})
+
+	t.Run("empty existing folder is used", func(t *testing.T) {
+		parent := t.TempDir()
+		os.Mkdir(filepath.Join(parent, "vault"), 0755)
+
+		app := &internal.App{}
+		if _, err := app.CreateVault(parent, "vault", ""); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("refuses non-empty folder", func(t *testing.T) {
+		parent := t.TempDir()
+		os.Mkdir(filepath.Join(parent, "vault"), 0755)
+		os.WriteFile(filepath.Join(parent, "vault", "keep.md"), []byte("mine"), 0644)
+
+		app := &internal.App{}
+		_, err := app.CreateVault(parent, "vault", "journal")
+		if !errors.Is(err, internal.ErrVaultNotEmpty) {
+			t.Errorf("Expected ErrVaultNotEmpty, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "vault", "Welcome.md")); !os.IsNotExist(err) {
+			t.Error("Template should not be copied into a non-empty folder")
+		}
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		app := &internal.App{}
+		parent := t.TempDir()
+
+		for _, name := range []string{"", "   ", ".", "..", "a/b", `a\b`, "what?", "CON", "nul.txt", "trailing.", ".hidden", "tab\there"} {
+			_, err := app.CreateVault(parent, name, "")
+			if !errors.Is(err, internal.ErrInvalidVaultName) {
+				t.Errorf("Name %q: expected ErrInvalidVaultName, got %v", name, err)
+			}
+		}
+		entries, _ := os.ReadDir(parent)
+		if len(entries) != 0 {
+			t.Errorf("Nothing should be created, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("rejects unknown template", func(t *testing.T) {
+		app := &internal.App{}
+		parent := t.TempDir()
+
+		for _, template := range []string{"novel", "../templates", filepath.Join(parent, "missing")} {
+			_, err := app.CreateVault(parent, "vault", template)
+			if !errors.Is(err, internal.ErrUnknownTemplate) {
+				t.Errorf("Template %q: expected ErrUnknownTemplate, got %v", template, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(parent, "vault")); !os.IsNotExist(err) {
+			t.Error("Vault folder should not be created")
+		}
+	})
+
+	t.Run("refuses to nest inside its template", func(t *testing.T) {
+		app := &internal.App{}
+		source := t.TempDir()
+
+		if _, err := app.CreateVault(source, "inner", source); err == nil {
+			t.Error("Expected error for vault inside its template")
+		}
+	})
+
+	t.Run("missing parent", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.CreateVault(filepath.Join(t.TempDir(), "nope"), "vault", ""); err == nil {
+			t.Error("Expected error for missing parent folder")
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"chalkmd/internal"
+)
+
+// newSiblingVault creates <tmp>/notes as the vault next to <tmp>/notes-private,
+// the directory a prefix-only check would wrongly treat as inside the vault.
+func newSiblingVault(t testing.TB) (*internal.App, string, string) {
+	base := t.TempDir()
+	vault := filepath.Join(base, "notes")
+	private := filepath.Join(base, "notes-private")
+	os.Mkdir(vault, 0755)
+	os.Mkdir(private, 0755)
+	os.WriteFile(filepath.Join(private, "secret.md"), []byte("secret"), 0644)
+
+	app := &internal.App{}
+	if err := app.OpenVault(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	return app, vault, private
+}
+
+var traversalAttempts = []string{
+	"../notes-private/secret.md",
+	"../notes-private/new.md",
+	"../../etc/passwd",
+	"folder/../../notes-private/secret.md",
+	"./../notes-private/secret.md",
+	"a/b/c/../../../../notes-private/secret.md",
+	"..",
+	"../",
+	"",
+	".",
+	"folder/..",
+}
+
+func TestPathTraversal(t *testing.T) {
+	for _, attempt := range traversalAttempts {
+		t.Run("read "+attempt, func(t *testing.T) {
+			app, _, _ := newSiblingVault(t)
+			if _, err := app.ReadFile(attempt); err == nil {
+				t.Errorf("Expected error reading %q", attempt)
+			}
+		})
+
+		t.Run("write "+attempt, func(t *testing.T) {
+			app, _, private := newSiblingVault(t)
+			if err := app.WriteFile(attempt, "pwned"); err == nil {
+				t.Errorf("Expected error writing %q", attempt)
+			}
+			content, _ := os.ReadFile(filepath.Join(private, "secret.md"))
+			if string(content) != "secret" {
+				t.Error("File outside vault was modified")
+			}
+		})
+
+		t.Run("delete "+attempt, func(t *testing.T) {
+			app, vault, _ := newSiblingVault(t)
+			if err := app.DeleteFile(attempt); err == nil {
+				t.Errorf("Expected error deleting %q", attempt)
+			}
+			if _, err := os.Stat(vault); err != nil {
+				t.Error("Vault root was removed")
+			}
+		})
+	}
+}
+
+func TestSiblingDirectoryRejected(t *testing.T) {
+	app, _, _ := newSiblingVault(t)
+
+	_, err := app.ReadFile("../notes-private/secret.md")
+	if !errors.Is(err, internal.ErrOutsideVault) {
+		t.Errorf("Expected ErrOutsideVault, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "outside vault") {
+		t.Errorf("Expected 'outside vault' error, got %v", err)
+	}
+
+	var pathErr *internal.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("Expected *PathError, got %T", err)
+	}
+}
+
+func TestNoVaultError(t *testing.T) {
+	app := &internal.App{}
+	_, err := app.ReadFile("note.md")
+	if !errors.Is(err, internal.ErrNoVault) {
+		t.Errorf("Expected ErrNoVault, got %v", err)
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	names := []string{
+		"CON",
+		"con.md",
+		"folder/NUL.txt",
+		"LPT1",
+		"aux/note.md",
+		".chalkmd/config.json",
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			app := &internal.App{}
+			app.OpenVault(t.TempDir())
+
+			err := app.WriteFile(name, "content")
+			if !errors.Is(err, internal.ErrReservedName) {
+				t.Errorf("Expected ErrReservedName for %q, got %v", name, err)
+			}
+		})
+	}
+
+	t.Run("similar names are allowed", func(t *testing.T) {
+		app := &internal.App{}
+		app.OpenVault(t.TempDir())
+
+		for _, name := range []string{"console.md", "auxiliary/notes.md", "COM10.md"} {
+			if err := app.WriteFile(name, "content"); err != nil {
+				t.Errorf("Expected %q to be allowed, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on Windows")
+	}
+
+	setup := func(t *testing.T) (*internal.App, string) {
+		app, vault, private := newSiblingVault(t)
+		os.Mkdir(filepath.Join(vault, "real"), 0755)
+		os.WriteFile(filepath.Join(vault, "real", "note.md"), []byte("inside"), 0644)
+		os.Symlink(private, filepath.Join(vault, "escape"))
+		os.Symlink(filepath.Join(vault, "real"), filepath.Join(vault, "alias"))
+		return app, private
+	}
+
+	t.Run("within-vault rejects escaping symlink", func(t *testing.T) {
+		app, private := setup(t)
+
+		_, err := app.ReadFile("escape/secret.md")
+		if !errors.Is(err, internal.ErrSymlinkEscape) {
+			t.Errorf("Expected ErrSymlinkEscape, got %v", err)
+		}
+
+		app.WriteFile("escape/new.md", "pwned")
+		if _, err := os.Stat(filepath.Join(private, "new.md")); err == nil {
+			t.Error("File was written through escaping symlink")
+		}
+	})
+
+	t.Run("dangling symlink is not written through", func(t *testing.T) {
+		app, private := setup(t)
+		vault := filepath.Join(filepath.Dir(private), "notes")
+		target := filepath.Join(private, "pwn.md")
+		os.Symlink(target, filepath.Join(vault, "link.md"))
+
+		if _, err := app.CreateFile("link.md"); !errors.Is(err, internal.ErrSymlinkDangling) {
+			t.Errorf("Expected ErrSymlinkDangling, got %v", err)
+		}
+		if err := app.WriteFile("link.md", "pwned"); !errors.Is(err, internal.ErrSymlinkDangling) {
+			t.Errorf("Expected ErrSymlinkDangling, got %v", err)
+		}
+		if _, err := os.Lstat(target); err == nil {
+			t.Fatal("File was created through a dangling symlink")
+		}
+
+		if err := app.RenameFile("link.md", "moved.md"); err != nil {
+			t.Errorf("Expected the link itself to be renamed, got %v", err)
+		}
+		if err := app.DeleteFile("moved.md"); err != nil {
+			t.Errorf("Expected the link itself to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("create checks the name with its extension", func(t *testing.T) {
+		app, private := setup(t)
+		vault := filepath.Join(filepath.Dir(private), "notes")
+		outside := filepath.Join(private, "secret.md")
+		os.Symlink(outside, filepath.Join(vault, "note.md"))
+		os.Symlink(filepath.Join(private, "pwn.md"), filepath.Join(vault, "d.md"))
+
+		if _, err := app.CreateFile("note"); err == nil {
+			t.Error("Expected error for a symlink out of the vault")
+		}
+		if got, _ := os.ReadFile(outside); string(got) != "secret" {
+			t.Errorf("File outside the vault was changed to %q", got)
+		}
+		if _, err := app.CreateFile("d"); !errors.Is(err, internal.ErrSymlinkDangling) {
+			t.Errorf("Expected ErrSymlinkDangling, got %v", err)
+		}
+		if _, err := os.Lstat(filepath.Join(private, "pwn.md")); err == nil {
+			t.Error("File was created through a dangling symlink")
+		}
+
+		app.SetSymlinkPolicy("allow")
+		if _, err := app.CreateFile("note"); err == nil {
+			t.Error("Expected an existing link to be refused")
+		}
+		if got, _ := os.ReadFile(outside); string(got) != "secret" {
+			t.Errorf("File outside the vault was changed to %q", got)
+		}
+	})
+
+	t.Run("undo does not restore through a symlink", func(t *testing.T) {
+		app, private := setup(t)
+		vault := filepath.Join(filepath.Dir(private), "notes")
+		app.CreateFile("real/new.md")
+		app.UndoLastFileOperation()
+
+		os.Rename(filepath.Join(vault, "real"), filepath.Join(vault, "real-moved"))
+		os.Symlink(private, filepath.Join(vault, "real"))
+		if _, err := app.RedoFileOperation(); err == nil {
+			t.Error("Expected redo through an escaping symlink to fail")
+		}
+		if _, err := os.Lstat(filepath.Join(private, "new.md")); err == nil {
+			t.Error("File was created through an escaping symlink")
+		}
+	})
+
+	t.Run("within-vault follows internal symlink", func(t *testing.T) {
+		app, _ := setup(t)
+
+		content, err := app.ReadFile("alias/note.md")
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if content != "inside" {
+			t.Errorf("Expected 'inside', got '%s'", content)
+		}
+	})
+
+	t.Run("deny rejects any symlink", func(t *testing.T) {
+		app, _ := setup(t)
+		app.SetSymlinkPolicy("deny")
+
+		_, err := app.ReadFile("alias/note.md")
+		if !errors.Is(err, internal.ErrSymlinkDenied) {
+			t.Errorf("Expected ErrSymlinkDenied, got %v", err)
+		}
+
+		if _, err := app.ReadFile("real/note.md"); err != nil {
+			t.Errorf("Expected plain path to work, got %v", err)
+		}
+	})
+
+	t.Run("allow follows escaping symlink", func(t *testing.T) {
+		app, _ := setup(t)
+		app.SetSymlinkPolicy("allow")
+
+		content, err := app.ReadFile("escape/secret.md")
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if content != "secret" {
+			t.Errorf("Expected 'secret', got '%s'", content)
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		app := &internal.App{}
+		if err := app.SetSymlinkPolicy("sometimes"); err == nil {
+			t.Error("Expected error for unknown policy")
+		}
+	})
+}
+
+// FuzzVaultPaths writes through arbitrary relative paths and checks that
+// nothing outside the vault is ever touched.
+func FuzzVaultPaths(f *testing.F) {
+	for _, seed := range traversalAttempts {
+		f.Add(seed)
+	}
+	f.Add("note.md")
+	f.Add("deep/nested/note.md")
+	f.Add("..\\..\\notes-private\\secret.md")
+	f.Add("/etc/passwd")
+	f.Add("C:\\Windows\\system.ini")
+	f.Add("a\x00b")
+
+	f.Fuzz(func(t *testing.T, relativePath string) {
+		app, vault, private := newSiblingVault(t)
+		base := filepath.Dir(vault)
+
+		app.WriteFile(relativePath, "fuzz")
+		app.CreateFolder(relativePath)
+
+		entries, _ := os.ReadDir(base)
+		if len(entries) != 2 {
+			t.Fatalf("Path %q created entries outside the vault", relativePath)
+		}
+		privateEntries, _ := os.ReadDir(private)
+		if len(privateEntries) != 1 {
+			t.Fatalf("Path %q wrote into sibling directory", relativePath)
+		}
+		content, _ := os.ReadFile(filepath.Join(private, "secret.md"))
+		if string(content) != "secret" {
+			t.Fatalf("Path %q modified a file outside the vault", relativePath)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+// newPrefsApp returns an app whose preferences live in a temporary config
+// directory.
+func newPrefsApp(t *testing.T) (*internal.App, string) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	t.Setenv("AppData", configDir)
+	return internal.NewApp(), configDir
+}
+
+func TestListRecentVaults(t *testing.T) {
+	t.Run("unavailable outside the app", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.ListRecentVaults(); err == nil {
+			t.Error("Expected error without an app config directory")
+		}
+	})
+
+	t.Run("records opened vaults, most recent first", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		first, second := t.TempDir(), t.TempDir()
+		app.OpenVault(first)
+		app.OpenVault(second)
+
+		vaults, err := app.ListRecentVaults()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(vaults) != 2 || vaults[0].Path != second || vaults[1].Path != first {
+			t.Fatalf("Unexpected recent vaults %+v", vaults)
+		}
+		if vaults[0].Name != filepath.Base(second) || !vaults[0].Exists || vaults[0].LastOpened == "" {
+			t.Errorf("Unexpected entry %+v", vaults[0])
+		}
+
+		app.OpenVault(first)
+		vaults, _ = app.ListRecentVaults()
+		if len(vaults) != 2 || vaults[0].Path != first {
+			t.Errorf("Reopened vault should move to the top, got %+v", vaults)
+		}
+	})
+
+	t.Run("shared between instances", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		vault := t.TempDir()
+		app.OpenVault(vault)
+
+		other := internal.NewApp()
+		vaults, _ := other.ListRecentVaults()
+		if len(vaults) != 1 || vaults[0].Path != vault {
+			t.Errorf("Expected another instance to see the vault, got %+v", vaults)
+		}
+	})
+
+	t.Run("reports missing folders", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		vault := filepath.Join(t.TempDir(), "gone")
+		os.Mkdir(vault, 0755)
+		app.OpenVault(vault)
+		os.RemoveAll(vault)
+
+		vaults, _ := app.ListRecentVaults()
+		if len(vaults) != 1 || vaults[0].Exists {
+			t.Errorf("Expected a missing vault entry, got %+v", vaults)
+		}
+	})
+
+	t.Run("pinned vaults first and kept past the limit", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		prefs, _ := app.GetAppPreferences()
+		prefs.MaxRecentVaults = 2
+		app.SetAppPreferences(prefs)
+
+		pinned := t.TempDir()
+		app.OpenVault(pinned)
+		if err := app.SetVaultPinned(pinned, true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			app.OpenVault(t.TempDir())
+		}
+
+		vaults, _ := app.ListRecentVaults()
+		if len(vaults) != 3 || vaults[0].Path != pinned || !vaults[0].Pinned {
+			t.Errorf("Expected pinned vault first plus 2 recent, got %+v", vaults)
+		}
+	})
+}
+
+func TestForgetVault(t *testing.T) {
+	t.Run("removes entry and default", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		vault := t.TempDir()
+		app.OpenVault(vault)
+		app.SetAppPreferences(internal.AppPreferences{DefaultVault: vault, OpenLastVault: true})
+
+		if err := app.ForgetVault(vault); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		vaults, _ := app.ListRecentVaults()
+		if len(vaults) != 0 {
+			t.Errorf("Expected no recent vaults, got %+v", vaults)
+		}
+		prefs, _ := app.GetAppPreferences()
+		if prefs.DefaultVault != "" {
+			t.Errorf("Forgotten vault should not stay the default, got %q", prefs.DefaultVault)
+		}
+		if _, err := os.Stat(vault); err != nil {
+			t.Error("Forgetting a vault must not delete it")
+		}
+	})
+}
+
+func TestAppPreferences(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+
+		prefs, err := app.GetAppPreferences()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expected := internal.AppPreferences{OpenLastVault: true, SymlinkPolicy: "within-vault", MaxRecentVaults: 20}
+		if prefs != expected {
+			t.Errorf("Expected %+v, got %+v", expected, prefs)
+		}
+	})
+
+	t.Run("persist across instances", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		vault := t.TempDir()
+
+		err := app.SetAppPreferences(internal.AppPreferences{DefaultVault: vault, SymlinkPolicy: "deny", MaxRecentVaults: 5})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		prefs, _ := internal.NewApp().GetAppPreferences()
+		if prefs.DefaultVault != vault || prefs.SymlinkPolicy != "deny" || prefs.OpenLastVault || prefs.MaxRecentVaults != 5 {
+			t.Errorf("Unexpected preferences %+v", prefs)
+		}
+	})
+
+	t.Run("rejects invalid preferences", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+
+		invalid := []internal.AppPreferences{
+			{SymlinkPolicy: "sometimes"},
+			{DefaultVault: "relative/path"},
+			{MaxRecentVaults: 1000},
+		}
+		for _, prefs := range invalid {
+			if err := app.SetAppPreferences(prefs); err == nil {
+				t.Errorf("Expected error for %+v", prefs)
+			}
+		}
+	})
+
+	t.Run("symlink policy applies to new instances", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		app.SetAppPreferences(internal.AppPreferences{SymlinkPolicy: "deny"})
+
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "real.md"), []byte("x"), 0644)
+		if err := os.Symlink(filepath.Join(vault, "real.md"), filepath.Join(vault, "link.md")); err != nil {
+			t.Skip("symlinks not supported")
+		}
+
+		other := internal.NewApp()
+		other.OpenVault(vault)
+		if _, err := other.ReadFile("link.md"); err == nil {
+			t.Error("Expected symlink to be denied")
+		}
+	})
+
+	t.Run("broken file falls back to defaults", func(t *testing.T) {
+		app, configDir := newPrefsApp(t)
+		os.MkdirAll(filepath.Join(configDir, "chalkmd"), 0755)
+		os.WriteFile(filepath.Join(configDir, "chalkmd", "preferences.json"), []byte("{oops"), 0644)
+
+		prefs, err := app.GetAppPreferences()
+		if err != nil || prefs.MaxRecentVaults != 20 {
+			t.Errorf("Expected defaults, got %+v %v", prefs, err)
+		}
+	})
+}
+
+func TestGetStartupVault(t *testing.T) {
+	t.Run("default vault wins", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		def, last := t.TempDir(), t.TempDir()
+		app.OpenVault(last)
+		app.SetAppPreferences(internal.AppPreferences{DefaultVault: def, OpenLastVault: true})
+
+		if got, _ := app.GetStartupVault(); got != def {
+			t.Errorf("Expected default vault %q, got %q", def, got)
+		}
+	})
+
+	t.Run("last existing vault", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		kept := t.TempDir()
+		gone := filepath.Join(t.TempDir(), "gone")
+		os.Mkdir(gone, 0755)
+		app.OpenVault(kept)
+		app.OpenVault(gone)
+		os.RemoveAll(gone)
+
+		if got, _ := app.GetStartupVault(); got != kept {
+			t.Errorf("Expected %q, got %q", kept, got)
+		}
+	})
+
+	t.Run("nothing when last vault is not reopened", func(t *testing.T) {
+		app, _ := newPrefsApp(t)
+		app.OpenVault(t.TempDir())
+		app.SetAppPreferences(internal.AppPreferences{OpenLastVault: false})
+
+		if got, _ := app.GetStartupVault(); got != "" {
+			t.Errorf("Expected no startup vault, got %q", got)
+		}
+	})
+}
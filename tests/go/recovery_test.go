@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"chalkmd/internal"
+)
+
+// crashedVault returns a vault whose Note.md has unsaved text "lost edits"
+// in the recovery journal, as left by an app that died before saving.
+func crashedVault(t *testing.T) string {
+	t.Helper()
+	vault := t.TempDir()
+	os.WriteFile(filepath.Join(vault, "Note.md"), []byte("saved"), 0644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(filepath.Join(vault, "Note.md"), old, old)
+
+	app := &internal.App{}
+	app.OpenVault(vault)
+	if err := app.StashBuffer("Note.md", "lost edits"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	app.Shutdown(context.Background())
+	return vault
+}
+
+func TestRecoveryJournal(t *testing.T) {
+	t.Run("unsaved text is reported after reopening", func(t *testing.T) {
+		vault := crashedVault(t)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		buffers, err := app.ListRecoverableBuffers()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(buffers) != 1 || buffers[0].Path != "Note.md" || buffers[0].Size != len("lost edits") || buffers[0].Modified == "" {
+			t.Errorf("Unexpected buffers %+v", buffers)
+		}
+
+		// the editor saving the note as it is on disk must not lose it
+		app.StashBuffer("Note.md", "saved")
+		app.WriteFile("Note.md", "saved")
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 1 {
+			t.Errorf("Expected buffer to survive a save, got %+v", buffers)
+		}
+	})
+
+	t.Run("recover writes the text", func(t *testing.T) {
+		vault := crashedVault(t)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		content, err := app.RecoverBuffer("Note.md")
+		if err != nil || content != "lost edits" {
+			t.Fatalf("Expected recovered text, got %q, %v", content, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(vault, "Note.md")); string(data) != "lost edits" {
+			t.Errorf("Expected note to hold recovered text, got %q", data)
+		}
+		versions, _ := app.ListFileVersions("Note.md")
+		if len(versions) == 0 {
+			t.Fatal("Expected the replaced text in the version history")
+		}
+		if old, _ := app.ReadFileVersion("Note.md", versions[0].ID); old != "saved" {
+			t.Errorf("Expected saved text in history, got %q", old)
+		}
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 0 {
+			t.Errorf("Expected journal to be cleared, got %+v", buffers)
+		}
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		vault := crashedVault(t)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		if err := app.DiscardRecovery("Note.md"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 0 {
+			t.Errorf("Expected journal to be cleared, got %+v", buffers)
+		}
+		if _, err := app.RecoverBuffer("Note.md"); !errors.Is(err, internal.ErrNoRecovery) {
+			t.Errorf("Expected ErrNoRecovery, got %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(vault, "Note.md")); string(data) != "saved" {
+			t.Errorf("Note should be unchanged, got %q", data)
+		}
+	})
+
+	t.Run("cleared by saving the same text", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		app.StashBuffer("Note.md", "draft")
+		app.StashBuffer("Note.md", "draft 2")
+		app.WriteFile("Note.md", "draft")
+		stashed, _ := filepath.Glob(filepath.Join(vault, ".chalkmd", "recovery", "*"))
+		if len(stashed) != 1 {
+			t.Errorf("Newer stash should survive an older save, got %v", stashed)
+		}
+		app.WriteFile("Note.md", "draft 2")
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 0 {
+			t.Errorf("Expected journal to be cleared, got %+v", buffers)
+		}
+	})
+
+	t.Run("an older stash is not written after a save", func(t *testing.T) {
+		vault := t.TempDir()
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		app.StashBuffer("Note.md", "draft")
+		app.QueueSave("Note.md", "draft and more")
+		app.FlushSaves()
+		app.StashBuffer("Other.md", "other draft")
+		app.WriteFile("Other.md", "other draft and more")
+
+		time.Sleep(1200 * time.Millisecond)
+		if stashed, _ := filepath.Glob(filepath.Join(vault, ".chalkmd", "recovery", "*")); len(stashed) != 0 {
+			t.Errorf("Expected no stash after newer text was saved, got %v", stashed)
+		}
+
+		app.StashBuffer("Note.md", "draft and even more")
+		app.WriteFile("Other.md", "other draft and more")
+		app.Shutdown(context.Background())
+		if stashed, _ := filepath.Glob(filepath.Join(vault, ".chalkmd", "recovery", "*")); len(stashed) != 1 {
+			t.Errorf("Expected the stash after the save to be kept, got %v", stashed)
+		}
+	})
+
+	t.Run("combined until the editor pauses", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "Note.md"), []byte("saved"), 0644)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		for _, content := range []string{"s", "sa", "sav"} {
+			app.StashBuffer("Note.md", content)
+		}
+		stash := filepath.Join(vault, ".chalkmd", "recovery", "*")
+		if stashed, _ := filepath.Glob(stash); len(stashed) != 0 {
+			t.Errorf("Expected nothing written yet, got %v", stashed)
+		}
+		time.Sleep(time.Second)
+		stashed, _ := filepath.Glob(stash)
+		if len(stashed) != 1 {
+			t.Fatalf("Expected one stash, got %v", stashed)
+		}
+		if data, _ := os.ReadFile(stashed[0]); !strings.Contains(string(data), `"content":"sav"`) {
+			t.Errorf("Expected the newest text, got %s", data)
+		}
+	})
+
+	t.Run("outdated stashes are dropped", func(t *testing.T) {
+		vault := crashedVault(t)
+		os.WriteFile(filepath.Join(vault, "Note.md"), []byte("saved elsewhere"), 0644)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 0 {
+			t.Errorf("Expected no buffers for a note saved since, got %+v", buffers)
+		}
+	})
+
+	t.Run("follows renames and deletes", func(t *testing.T) {
+		vault := crashedVault(t)
+		app := &internal.App{}
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+
+		app.RenameFile("Note.md", "Renamed.md")
+		buffers, _ := app.ListRecoverableBuffers()
+		if len(buffers) != 1 || buffers[0].Path != "Renamed.md" {
+			t.Fatalf("Expected stash to follow the rename, got %+v", buffers)
+		}
+		app.DeleteFile("Renamed.md")
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 0 {
+			t.Errorf("Expected stash to be deleted with the note, got %+v", buffers)
+		}
+	})
+
+	t.Run("read-only vault", func(t *testing.T) {
+		vault := crashedVault(t)
+		holder := &internal.App{}
+		holder.OpenVault(vault)
+		defer holder.Shutdown(context.Background())
+		app := &internal.App{}
+		app.OpenVaultReadOnly(vault)
+		defer app.Shutdown(context.Background())
+
+		if buffers, _ := app.ListRecoverableBuffers(); len(buffers) != 1 {
+			t.Errorf("Expected buffers to be listed, got %+v", buffers)
+		}
+		if err := app.StashBuffer("Note.md", "x"); !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+		if _, err := app.RecoverBuffer("Note.md"); !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+	})
+}
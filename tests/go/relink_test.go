@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func writeVault(t *testing.T, files map[string]string) (*internal.App, string) {
+	tempDir := t.TempDir()
+	for name, content := range files {
+		full := filepath.Join(tempDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+
+	app := &internal.App{}
+	if err := app.OpenVault(tempDir); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	return app, tempDir
+}
+
+func readVaultFile(t *testing.T, vault, name string) string {
+	content, err := os.ReadFile(filepath.Join(vault, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestRenameFileWithLinks(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.RenameFileWithLinks("old.md", "new.md")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("rewrites links, aliases and headings", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"old.md":   "# Old",
+			"index.md": "See [[old]], [[old|the old one]] and [[old#Section|sec]].\nAlso [[old.md]].",
+			"other.md": "Unrelated [[index]]",
+		})
+
+		report, err := app.RenameFileWithLinks("old.md", "new.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := "See [[new]], [[new|the old one]] and [[new#Section|sec]].\nAlso [[new.md]]."
+		if got := readVaultFile(t, vault, "index.md"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+		if got := readVaultFile(t, vault, "other.md"); got != "Unrelated [[index]]" {
+			t.Errorf("Unrelated note was modified: %q", got)
+		}
+		if len(report.UpdatedFiles) != 1 || report.LinksUpdated != 4 {
+			t.Errorf("Expected 1 file and 4 links updated, got %+v", report)
+		}
+	})
+
+	t.Run("uses full path when the new name is ambiguous", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"notes/draft.md":   "draft",
+			"ideas.md":         "[[draft]]",
+			"archive/final.md": "",
+		})
+
+		_, err := app.RenameFileWithLinks("notes/draft.md", "notes/final.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if got := readVaultFile(t, vault, "ideas.md"); got != "[[notes/final]]" {
+			t.Errorf("Expected '[[notes/final]]', got %q", got)
+		}
+	})
+
+	t.Run("rewrites embeds and keeps image width", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"images/paste.png": "png",
+			"note.md":          "![[paste.png|300]]",
+		})
+
+		_, err := app.RenameFileWithLinks("images/paste.png", "images/diagram.png")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if got := readVaultFile(t, vault, "note.md"); got != "![[diagram.png|300]]" {
+			t.Errorf("Expected '![[diagram.png|300]]', got %q", got)
+		}
+	})
+
+	t.Run("ignores links in code", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"old.md":  "",
+			"note.md": "`[[old]]`\n```\n[[old]]\n```\n[[old]]",
+		})
+
+		_, err := app.RenameFileWithLinks("old.md", "new.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := "`[[old]]`\n```\n[[old]]\n```\n[[new]]"
+		if got := readVaultFile(t, vault, "note.md"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("refuses to overwrite existing file", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"a.md": "a",
+			"b.md": "b",
+		})
+
+		_, err := app.RenameFileWithLinks("a.md", "b.md")
+		if err == nil {
+			t.Error("Expected error when destination exists")
+		}
+		if got := readVaultFile(t, vault, "b.md"); got != "b" {
+			t.Error("Destination was overwritten")
+		}
+	})
+
+	t.Run("case-only rename", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"note.md":  "n",
+			"index.md": "See [[note]]",
+		})
+
+		if _, err := app.RenameFileWithLinks("note.md", "Note.md"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "Note.md"); got != "n" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if got := readVaultFile(t, vault, "index.md"); got != "See [[Note]]" {
+			t.Errorf("Unexpected links %q", got)
+		}
+		if _, err := app.UndoLastFileOperation(); err != nil {
+			t.Errorf("Expected the rename to be undone, got %v", err)
+		}
+	})
+
+	t.Run("queued saves are kept and editors reload", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "note.md"), []byte("n"), 0644)
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		app.WriteFile("index.md", "See [[note]]")
+		app.QueueSave("index.md", "See [[note]] and more")
+		if _, err := app.RenameFileWithLinks("note.md", "renamed.md"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		app.FlushSaves()
+		if got := readVaultFile(t, vault, "index.md"); got != "See [[renamed]] and more" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "index.md" {
+			t.Errorf("Expected a change of index.md, got %+v", e)
+		}
+	})
+}
+
+func TestMoveFileWithLinks(t *testing.T) {
+	t.Run("moves folder and rewrites links to its files", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"projects/alpha.md": "[[projects/beta]]",
+			"projects/beta.md":  "",
+			"index.md":          "[[projects/alpha]] [[beta|B]]",
+		})
+
+		report, err := app.MoveFileWithLinks("projects", "archive/projects")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if got := readVaultFile(t, vault, "index.md"); got != "[[archive/projects/alpha]] [[beta|B]]" {
+			t.Errorf("Unexpected index.md: %q", got)
+		}
+		if got := readVaultFile(t, vault, "archive/projects/alpha.md"); got != "[[archive/projects/beta]]" {
+			t.Errorf("Unexpected alpha.md: %q", got)
+		}
+		if len(report.UpdatedFiles) != 2 {
+			t.Errorf("Expected 2 updated files, got %+v", report.UpdatedFiles)
+		}
+	})
+
+	t.Run("path traversal attack", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"file.md": "content"})
+
+		_, err := app.MoveFileWithLinks("file.md", "../../../tmp/evil.md")
+		if err == nil {
+			t.Error("Expected error for path traversal attempt")
+		}
+	})
+}
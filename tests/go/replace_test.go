@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func replaceVault(t *testing.T) (*internal.App, string) {
+	return writeVault(t, map[string]string{
+		"a.md":           "---\ntitle: Alpha\n---\nAlpha launch, alphabet soup.\n`Alpha` in code",
+		"b.md":           "The alpha team\n```\nalpha = 1\n```",
+		"notes/c.md":     "Nothing here",
+		"notes/d.md":     "ALPHA and Alpha",
+		"attachment.txt": "alpha",
+	})
+}
+
+func TestPreviewReplace(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.PreviewReplace("a", "b", internal.ReplaceOptions{}); !errors.Is(err, internal.ErrNoVault) {
+			t.Errorf("Expected ErrNoVault, got %v", err)
+		}
+	})
+
+	t.Run("finds matches with context", func(t *testing.T) {
+		app, _ := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		preview, err := app.PreviewReplace("alpha", "beta", internal.ReplaceOptions{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if preview.ID == "" || preview.Files != 3 || len(preview.Edits) != 8 {
+			t.Fatalf("Unexpected preview: %d files, %d edits", preview.Files, len(preview.Edits))
+		}
+		first := preview.Edits[0]
+		if first.ID != 0 || first.Path != "a.md" || first.Line != 2 || first.Match != "Alpha" || first.Before != "title: Alpha" || first.After != "title: beta" {
+			t.Errorf("Unexpected first edit %+v", first)
+		}
+		for _, e := range preview.Edits {
+			if filepath.Base(e.Path) == "attachment.txt" {
+				t.Errorf("Only notes should be searched, got %+v", e)
+			}
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		app, _ := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		cases := []struct {
+			name    string
+			pattern string
+			options internal.ReplaceOptions
+			edits   int
+		}{
+			{"case sensitive", "Alpha", internal.ReplaceOptions{CaseSensitive: true}, 4},
+			{"whole word", "alpha", internal.ReplaceOptions{WholeWord: true}, 7},
+			{"skip code", "alpha", internal.ReplaceOptions{SkipCode: true}, 6},
+			{"skip frontmatter", "alpha", internal.ReplaceOptions{SkipFrontmatter: true}, 7},
+			{"path glob", "alpha", internal.ReplaceOptions{PathGlob: "notes/**"}, 2},
+			{"file name glob", "alpha", internal.ReplaceOptions{PathGlob: "b.*"}, 2},
+			{"regex", `alpha\w+`, internal.ReplaceOptions{Regex: true}, 1},
+		}
+		for _, c := range cases {
+			preview, err := app.PreviewReplace(c.pattern, "x", c.options)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", c.name, err)
+			}
+			if len(preview.Edits) != c.edits {
+				t.Errorf("%s: expected %d edits, got %+v", c.name, c.edits, preview.Edits)
+			}
+		}
+	})
+
+	t.Run("regex groups", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"n.md": "Due 2024-03-05"})
+		defer app.Shutdown(context.Background())
+
+		preview, err := app.PreviewReplace(`(\d{4})-(\d{2})-(\d{2})`, "$3.$2.$1", internal.ReplaceOptions{Regex: true})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(preview.Edits) != 1 || preview.Edits[0].Replacement != "05.03.2024" || preview.Edits[0].After != "Due 05.03.2024" {
+			t.Errorf("Unexpected edits %+v", preview.Edits)
+		}
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		app, _ := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		if _, err := app.PreviewReplace("", "x", internal.ReplaceOptions{}); !errors.Is(err, internal.ErrInvalidPattern) {
+			t.Errorf("Expected ErrInvalidPattern, got %v", err)
+		}
+		if _, err := app.PreviewReplace("(", "x", internal.ReplaceOptions{Regex: true}); !errors.Is(err, internal.ErrInvalidPattern) {
+			t.Errorf("Expected ErrInvalidPattern, got %v", err)
+		}
+	})
+}
+
+func TestApplyReplace(t *testing.T) {
+	t.Run("applies every edit and undoes as one", func(t *testing.T) {
+		app, vault := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("alpha", "beta", internal.ReplaceOptions{WholeWord: true, SkipCode: true})
+		report, err := app.ApplyReplace(preview.ID, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(report.UpdatedFiles) != 3 || report.Replacements != 5 {
+			t.Errorf("Unexpected report %+v", report)
+		}
+		expected := map[string]string{
+			"a.md":       "---\ntitle: beta\n---\nbeta launch, alphabet soup.\n`Alpha` in code",
+			"b.md":       "The beta team\n```\nalpha = 1\n```",
+			"notes/d.md": "beta and beta",
+		}
+		for name, want := range expected {
+			if got := readVaultFile(t, vault, name); got != want {
+				t.Errorf("%s: expected %q, got %q", name, want, got)
+			}
+		}
+
+		op, err := app.UndoLastFileOperation()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if op.Kind != internal.OpReplace {
+			t.Errorf("Unexpected operation %+v", op)
+		}
+		if got := readVaultFile(t, vault, "notes/d.md"); got != "ALPHA and Alpha" {
+			t.Errorf("Expected undo to restore the note, got %q", got)
+		}
+		if _, err := app.RedoFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "b.md"); got != expected["b.md"] {
+			t.Errorf("Expected redo to replace again, got %q", got)
+		}
+	})
+
+	t.Run("selected edits only", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": "one one one"})
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("one", "two", internal.ReplaceOptions{})
+		if _, err := app.ApplyReplace(preview.ID, []int{2, 0}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "n.md"); got != "two one two" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if _, err := app.ApplyReplace(preview.ID, nil); !errors.Is(err, internal.ErrPreviewExpired) {
+			t.Errorf("A preview should apply once, got %v", err)
+		}
+	})
+
+	t.Run("refuses notes changed since the preview", func(t *testing.T) {
+		app, vault := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("alpha", "beta", internal.ReplaceOptions{})
+		os.WriteFile(filepath.Join(vault, "b.md"), []byte("edited elsewhere: alpha"), 0644)
+		if _, err := app.ApplyReplace(preview.ID, nil); !errors.Is(err, internal.ErrPreviewOutdated) {
+			t.Fatalf("Expected ErrPreviewOutdated, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "notes/d.md"); got != "ALPHA and Alpha" {
+			t.Errorf("No note should be written, got %q", got)
+		}
+	})
+
+	t.Run("queued saves count as changes", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": "old"})
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("old", "new", internal.ReplaceOptions{})
+		app.QueueSave("n.md", "old, edited")
+		if _, err := app.ApplyReplace(preview.ID, nil); !errors.Is(err, internal.ErrPreviewOutdated) {
+			t.Fatalf("Expected ErrPreviewOutdated, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "n.md"); got != "old, edited" {
+			t.Errorf("Expected the queued save, got %q", got)
+		}
+	})
+
+	t.Run("editors are told to reload", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "n.md"), []byte("old"), 0644)
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("old", "new", internal.ReplaceOptions{})
+		if _, err := app.ApplyReplace(preview.ID, nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "n.md" {
+			t.Errorf("Expected a change of n.md, got %+v", e)
+		}
+		app.UndoLastFileOperation()
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "n.md" {
+			t.Errorf("Expected a change of n.md on undo, got %+v", e)
+		}
+	})
+
+	t.Run("undo refuses notes changed since", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": "old"})
+		defer app.Shutdown(context.Background())
+
+		preview, _ := app.PreviewReplace("old", "new", internal.ReplaceOptions{})
+		app.ApplyReplace(preview.ID, nil)
+		app.WriteFile("n.md", "newer")
+		if _, err := app.UndoLastFileOperation(); !errors.Is(err, internal.ErrOperationConflict) {
+			t.Errorf("Expected ErrOperationConflict, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "n.md"); got != "newer" {
+			t.Errorf("Note should be unchanged, got %q", got)
+		}
+	})
+
+	t.Run("unknown preview and edits", func(t *testing.T) {
+		app, _ := replaceVault(t)
+		defer app.Shutdown(context.Background())
+
+		if _, err := app.ApplyReplace("missing", nil); !errors.Is(err, internal.ErrPreviewExpired) {
+			t.Errorf("Expected ErrPreviewExpired, got %v", err)
+		}
+		first, _ := app.PreviewReplace("alpha", "beta", internal.ReplaceOptions{})
+		second, _ := app.PreviewReplace("team", "crew", internal.ReplaceOptions{})
+		if _, err := app.ApplyReplace(first.ID, nil); !errors.Is(err, internal.ErrPreviewExpired) {
+			t.Errorf("Expected a newer preview to replace the older one, got %v", err)
+		}
+		if _, err := app.ApplyReplace(second.ID, []int{5}); err == nil {
+			t.Error("Expected error for an edit not in the preview")
+		}
+	})
+
+	t.Run("read-only vault", func(t *testing.T) {
+		holder, vault := replaceVault(t)
+		defer holder.Shutdown(context.Background())
+		app := &internal.App{}
+		app.OpenVaultReadOnly(vault)
+		defer app.Shutdown(context.Background())
+
+		preview, err := app.PreviewReplace("alpha", "beta", internal.ReplaceOptions{})
+		if err != nil {
+			t.Fatalf("Preview should work read-only, got %v", err)
+		}
+		if _, err := app.ApplyReplace(preview.ID, nil); !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+	})
+}
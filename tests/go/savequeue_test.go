@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"chalkmd/internal"
+)
+
+// queueApp opens vault with an autosave interval of interval ms.
+func queueApp(t *testing.T, vault string, interval int) *internal.App {
+	t.Helper()
+	app := &internal.App{}
+	if err := app.OpenVault(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	if _, err := app.UpdateVaultConfig(map[string]interface{}{"autoSaveInterval": float64(interval)}); err != nil {
+		t.Fatalf("Failed to set autosave interval: %v", err)
+	}
+	return app
+}
+
+func TestQueueSave(t *testing.T) {
+	t.Run("saves are coalesced until flushed", func(t *testing.T) {
+		vault := t.TempDir()
+		app := queueApp(t, vault, 60000)
+		defer app.Shutdown(context.Background())
+
+		for _, content := range []string{"a", "ab", "abc"} {
+			if err := app.QueueSave("Note.md", content); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(vault, "Note.md")); !os.IsNotExist(err) {
+			t.Fatal("Nothing should be written before the interval passed")
+		}
+
+		if err := app.FlushSaves(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		data, _ := os.ReadFile(filepath.Join(vault, "Note.md"))
+		if string(data) != "abc" {
+			t.Errorf("Expected newest content, got %q", data)
+		}
+		if versions, _ := app.ListFileVersions("Note.md"); len(versions) != 0 {
+			t.Errorf("Expected a single write, got %d earlier versions", len(versions))
+		}
+	})
+
+	t.Run("written in the background", func(t *testing.T) {
+		vault := t.TempDir()
+		app := queueApp(t, vault, 10)
+		defer app.Shutdown(context.Background())
+
+		app.QueueSave("Note.md", "hello")
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if data, _ := os.ReadFile(filepath.Join(vault, "Note.md")); string(data) == "hello" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Queued save was not written")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if results, _ := app.SearchVault("hello", internal.SearchOptions{}); len(results) != 1 {
+			t.Errorf("Expected the index to be updated, got %+v", results)
+		}
+	})
+
+	t.Run("flushed on shutdown", func(t *testing.T) {
+		vault := t.TempDir()
+		app := queueApp(t, vault, 60000)
+		app.QueueSave("Note.md", "last words")
+		app.Shutdown(context.Background())
+
+		if data, _ := os.ReadFile(filepath.Join(vault, "Note.md")); string(data) != "last words" {
+			t.Errorf("Expected queued save to be written, got %q", data)
+		}
+	})
+
+	t.Run("flushed before closing", func(t *testing.T) {
+		vault := t.TempDir()
+		app := queueApp(t, vault, 60000)
+		defer app.Shutdown(context.Background())
+
+		app.QueueSave("Note.md", "closing")
+		if app.BeforeClose(context.Background()) {
+			t.Error("Closing should not be prevented")
+		}
+		if data, _ := os.ReadFile(filepath.Join(vault, "Note.md")); string(data) != "closing" {
+			t.Errorf("Expected queued save to be written, got %q", data)
+		}
+	})
+
+	t.Run("written to the vault it was queued for", func(t *testing.T) {
+		first, second := t.TempDir(), t.TempDir()
+		app := queueApp(t, first, 60000)
+		defer app.Shutdown(context.Background())
+
+		app.QueueSave("Note.md", "first vault")
+		if err := app.OpenVault(second); err != nil {
+			t.Fatalf("Failed to open vault: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(first, "Note.md")); string(data) != "first vault" {
+			t.Errorf("Expected save in the first vault, got %q", data)
+		}
+		if _, err := os.Stat(filepath.Join(second, "Note.md")); !os.IsNotExist(err) {
+			t.Error("Save should not go to the second vault")
+		}
+	})
+
+	t.Run("flushed before a rename or delete", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "a.md"), []byte("old"), 0644)
+		os.MkdirAll(filepath.Join(vault, "dir"), 0755)
+		os.WriteFile(filepath.Join(vault, "dir", "c.md"), []byte("old"), 0644)
+		app := queueApp(t, vault, 60000)
+		defer app.Shutdown(context.Background())
+
+		app.QueueSave("a.md", "typed")
+		if err := app.RenameFile("a.md", "b.md"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		app.QueueSave("dir/c.md", "typed")
+		if err := app.DeleteFile("dir"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		app.FlushSaves()
+
+		if data, _ := os.ReadFile(filepath.Join(vault, "b.md")); string(data) != "typed" {
+			t.Errorf("Expected the save to be written before the rename, got %q", data)
+		}
+		for _, name := range []string{"a.md", "dir"} {
+			if _, err := os.Stat(filepath.Join(vault, name)); !os.IsNotExist(err) {
+				t.Errorf("%s should not be brought back", name)
+			}
+		}
+	})
+
+	t.Run("not written to a note that is gone", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "a.md"), []byte("old"), 0644)
+		app := queueApp(t, vault, 60000)
+		defer app.Shutdown(context.Background())
+
+		app.QueueSave("a.md", "typed")
+		os.Remove(filepath.Join(vault, "a.md"))
+		if err := app.FlushSaves(); !errors.Is(err, internal.ErrFileGone) {
+			t.Errorf("Expected ErrFileGone, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "a.md")); !os.IsNotExist(err) {
+			t.Error("The note should not be brought back")
+		}
+	})
+
+	t.Run("rejected saves", func(t *testing.T) {
+		app := &internal.App{}
+		if err := app.QueueSave("Note.md", "x"); !errors.Is(err, internal.ErrNoVault) {
+			t.Errorf("Expected ErrNoVault, got %v", err)
+		}
+
+		vault := t.TempDir()
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+		if err := app.QueueSave("../outside.md", "x"); !errors.Is(err, internal.ErrOutsideVault) {
+			t.Errorf("Expected ErrOutsideVault, got %v", err)
+		}
+
+		reader := &internal.App{}
+		reader.OpenVaultReadOnly(vault)
+		defer reader.Shutdown(context.Background())
+		if err := reader.QueueSave("Note.md", "x"); !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+	})
+}
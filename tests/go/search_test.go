@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func searchPaths(results []internal.SearchResult) []string {
+	paths := []string{}
+	for _, r := range results {
+		paths = append(paths, filepath.ToSlash(r.Path))
+	}
+	return paths
+}
+
+func TestSearchVault(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.SearchVault("anything", internal.SearchOptions{})
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("words are ANDed and ranked", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"garden.md":  "Tomato tomato tomato and basil",
+			"kitchen.md": "A tomato sauce without basil? No.",
+			"other.md":   "Only basil here",
+		})
+
+		results, err := app.SearchVault("tomato basil", internal.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		paths := searchPaths(results)
+		if len(paths) != 2 || paths[0] != "garden.md" {
+			t.Errorf("Expected garden.md then kitchen.md, got %v", paths)
+		}
+	})
+
+	t.Run("match offsets and snippets", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"note.md": "first line\nthe Needle is here",
+		})
+
+		results, _ := app.SearchVault("needle", internal.SearchOptions{})
+		if len(results) != 1 || len(results[0].Matches) != 1 {
+			t.Fatalf("Expected one match, got %+v", results)
+		}
+		m := results[0].Matches[0]
+		if m.Start != 15 || m.End != 21 || m.Line != 2 || m.Snippet != "the Needle is here" {
+			t.Errorf("Unexpected match: %+v", m)
+		}
+	})
+
+	t.Run("phrases", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"a.md": "the quick brown fox",
+			"b.md": "brown and quick",
+		})
+
+		results, _ := app.SearchVault(`"quick brown"`, internal.SearchOptions{})
+		if paths := searchPaths(results); len(paths) != 1 || paths[0] != "a.md" {
+			t.Errorf("Expected only a.md, got %v", paths)
+		}
+	})
+
+	t.Run("prefix matching", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"a.md": "programming in Go",
+			"b.md": "a program",
+			"c.md": "nothing relevant",
+		})
+
+		results, _ := app.SearchVault("program*", internal.SearchOptions{})
+		if len(results) != 2 {
+			t.Errorf("Expected 2 results, got %v", searchPaths(results))
+		}
+
+		results, _ = app.SearchVault("prog* go", internal.SearchOptions{})
+		if paths := searchPaths(results); len(paths) != 1 || paths[0] != "a.md" {
+			t.Errorf("Expected only a.md, got %v", paths)
+		}
+
+		results, _ = app.SearchVault("Program*", internal.SearchOptions{CaseSensitive: true})
+		if len(results) != 0 {
+			t.Errorf("Expected no case-sensitive match, got %v", searchPaths(results))
+		}
+	})
+
+	t.Run("case and diacritic folding", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"a.md": "Café Crème",
+		})
+
+		results, _ := app.SearchVault("cafe creme", internal.SearchOptions{})
+		if len(results) != 1 {
+			t.Error("Expected folded match")
+		}
+
+		results, _ = app.SearchVault("café", internal.SearchOptions{CaseSensitive: true})
+		if len(results) != 0 {
+			t.Error("Expected no case-sensitive match")
+		}
+
+		results, _ = app.SearchVault("Cafe", internal.SearchOptions{MatchDiacritics: true})
+		if len(results) != 0 {
+			t.Error("Expected no diacritic-sensitive match")
+		}
+
+		results, _ = app.SearchVault("Café", internal.SearchOptions{CaseSensitive: true, MatchDiacritics: true})
+		if len(results) != 1 {
+			t.Error("Expected exact match")
+		}
+	})
+
+	t.Run("path and tag filters", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"work/plan.md":   "meeting notes #project/alpha",
+			"home/plan.md":   "meeting with family",
+			"work/other.md":  "---\ntags: [project]\n---\nmeeting",
+			"work/random.md": "meeting",
+		})
+
+		results, _ := app.SearchVault("meeting path:work", internal.SearchOptions{})
+		if len(results) != 3 {
+			t.Errorf("Expected 3 results in work/, got %v", searchPaths(results))
+		}
+
+		results, _ = app.SearchVault("meeting tag:project", internal.SearchOptions{})
+		if len(results) != 2 {
+			t.Errorf("Expected 2 results tagged project, got %v", searchPaths(results))
+		}
+
+		results, _ = app.SearchVault("tag:project/alpha", internal.SearchOptions{})
+		if paths := searchPaths(results); len(paths) != 1 || paths[0] != "work/plan.md" {
+			t.Errorf("Expected work/plan.md, got %v", paths)
+		}
+	})
+
+	t.Run("updates on write, rename and delete", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"a.md": "old words",
+		})
+
+		app.WriteFile("a.md", "fresh words")
+		if results, _ := app.SearchVault("old", internal.SearchOptions{}); len(results) != 0 {
+			t.Error("Expected stale content to be gone")
+		}
+		if results, _ := app.SearchVault("fresh", internal.SearchOptions{}); len(results) != 1 {
+			t.Error("Expected new content to be found")
+		}
+
+		app.RenameFile("a.md", "b.md")
+		results, _ := app.SearchVault("fresh", internal.SearchOptions{})
+		if paths := searchPaths(results); len(paths) != 1 || paths[0] != "b.md" {
+			t.Errorf("Expected renamed path, got %v", paths)
+		}
+	})
+
+	t.Run("many notes are all indexed", func(t *testing.T) {
+		files := map[string]string{}
+		for i := 0; i < 100; i++ {
+			files[fmt.Sprintf("dir%d/n%d.md", i%7, i)] = fmt.Sprintf("common word%d [[n%d]]", i, (i+1)%100)
+		}
+		app, _ := writeVault(t, files)
+		defer app.Shutdown(context.Background())
+
+		if results, _ := app.SearchVault("common", internal.SearchOptions{Limit: 1000}); len(results) != 100 {
+			t.Errorf("Expected 100 notes, got %d", len(results))
+		}
+		if results, _ := app.SearchVault("word42", internal.SearchOptions{}); len(results) != 1 {
+			t.Errorf("Expected one note, got %+v", results)
+		}
+		if backlinks, _ := app.GetBacklinks("dir0/n0.md"); len(backlinks) != 1 {
+			t.Errorf("Expected a backlink from n99, got %+v", backlinks)
+		}
+	})
+
+	t.Run("persisted index is refreshed on open", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{
+			"a.md": "alpha",
+		})
+		if _, err := os.Stat(filepath.Join(vault, ".chalkmd", "search-index.gob")); err != nil {
+			t.Fatalf("Expected index to be persisted, got %v", err)
+		}
+
+		os.WriteFile(filepath.Join(vault, "b.md"), []byte("alpha beta"), 0644)
+		os.Remove(filepath.Join(vault, "a.md"))
+
+		app.Shutdown(context.Background())
+		reopened := &internal.App{}
+		reopened.OpenVault(vault)
+		results, _ := reopened.SearchVault("alpha", internal.SearchOptions{})
+		if paths := searchPaths(results); len(paths) != 1 || paths[0] != "b.md" {
+			t.Errorf("Expected only b.md, got %v", paths)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"chalkmd/internal"
+)
+
+func tagVault(t *testing.T) (*internal.App, string) {
+	return writeVault(t, map[string]string{
+		"a.md":        "---\ntags: [project/alpha, Reading]\n---\nNotes on #project/alpha and #todo",
+		"b.md":        "#project/beta here, but `#project/code` and\n```\n#project/fenced\n```",
+		"c.md":        "Just #Project and issue #123",
+		"sub/d.md":    "Nested #project/alpha/v2",
+		"untagged.md": "No tags",
+	})
+}
+
+func TestListTags(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.ListTags(); !errors.Is(err, internal.ErrNoVault) {
+			t.Errorf("Expected ErrNoVault, got %v", err)
+		}
+	})
+
+	t.Run("counts and hierarchy", func(t *testing.T) {
+		app, _ := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		tags, err := app.ListTags()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Tag)
+		}
+		if !reflect.DeepEqual(names, []string{"project", "reading", "todo"}) {
+			t.Fatalf("Unexpected top-level tags %v", names)
+		}
+		project := tags[0]
+		if project.Count != 4 || len(project.Children) != 2 {
+			t.Fatalf("Unexpected project tag %+v", project)
+		}
+		alpha := project.Children[0]
+		if alpha.Tag != "project/alpha" || alpha.Name != "alpha" || alpha.Count != 2 {
+			t.Errorf("Unexpected alpha tag %+v", alpha)
+		}
+		if len(alpha.Children) != 1 || alpha.Children[0].Tag != "project/alpha/v2" || alpha.Children[0].Count != 1 {
+			t.Errorf("Unexpected alpha children %+v", alpha.Children)
+		}
+		if beta := project.Children[1]; beta.Tag != "project/beta" || beta.Count != 1 || len(beta.Children) != 0 {
+			t.Errorf("Unexpected beta tag %+v", beta)
+		}
+	})
+
+	t.Run("follows edits", func(t *testing.T) {
+		app, _ := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		app.WriteFile("untagged.md", "Now #fresh")
+		app.DeleteFile("c.md")
+		tags, _ := app.ListTags()
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Tag)
+		}
+		if !reflect.DeepEqual(names, []string{"fresh", "project", "reading", "todo"}) {
+			t.Errorf("Unexpected tags %v", names)
+		}
+		if tags[1].Count != 3 {
+			t.Errorf("Expected 3 notes tagged project, got %d", tags[1].Count)
+		}
+	})
+}
+
+func TestGetNotesWithTag(t *testing.T) {
+	app, _ := tagVault(t)
+	defer app.Shutdown(context.Background())
+
+	cases := []struct {
+		tag      string
+		children bool
+		want     []string
+	}{
+		{"project", false, []string{"c.md"}},
+		{"#Project", true, []string{"a.md", "b.md", "c.md", "sub/d.md"}},
+		{"project/alpha", true, []string{"a.md", "sub/d.md"}},
+		{"project/code", true, []string{}},
+		{"reading", false, []string{"a.md"}},
+		{"missing", true, []string{}},
+	}
+	for _, c := range cases {
+		notes, err := app.GetNotesWithTag(c.tag, c.children)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for i := range notes {
+			notes[i] = filepath.ToSlash(notes[i])
+		}
+		if !reflect.DeepEqual(notes, c.want) {
+			t.Errorf("GetNotesWithTag(%q, %v) = %v, want %v", c.tag, c.children, notes, c.want)
+		}
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	t.Run("rewrites inline and frontmatter tags", func(t *testing.T) {
+		app, vault := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		report, err := app.RenameTag("project", "work")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(report.UpdatedFiles) != 4 || report.TagsUpdated != 5 {
+			t.Errorf("Unexpected report %+v", report)
+		}
+
+		expected := map[string]string{
+			"a.md":     "---\ntags:\n  - work/alpha\n  - Reading\n---\nNotes on #work/alpha and #todo",
+			"b.md":     "#work/beta here, but `#project/code` and\n```\n#project/fenced\n```",
+			"c.md":     "Just #work and issue #123",
+			"sub/d.md": "Nested #work/alpha/v2",
+		}
+		for name, want := range expected {
+			if got := readVaultFile(t, vault, name); got != want {
+				t.Errorf("%s: expected %q, got %q", name, want, got)
+			}
+		}
+		if notes, _ := app.GetNotesWithTag("work", true); len(notes) != 4 {
+			t.Errorf("Expected the index to follow the rename, got %v", notes)
+		}
+		if notes, _ := app.GetNotesWithTag("project", true); len(notes) != 0 {
+			t.Errorf("Expected no notes with the old tag, got %v", notes)
+		}
+	})
+
+	t.Run("nested tag only", func(t *testing.T) {
+		app, vault := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		if _, err := app.RenameTag("#project/alpha", "alpha"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "sub/d.md"); got != "Nested #alpha/v2" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if got := readVaultFile(t, vault, "c.md"); got != "Just #Project and issue #123" {
+			t.Errorf("Parent tag should be untouched, got %q", got)
+		}
+	})
+
+	t.Run("does not match longer tags", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": "#pro #project #pro/x"})
+		defer app.Shutdown(context.Background())
+
+		app.RenameTag("pro", "amateur")
+		if got := readVaultFile(t, vault, "n.md"); got != "#amateur #project #amateur/x" {
+			t.Errorf("Unexpected content %q", got)
+		}
+	})
+
+	t.Run("merges into an existing tag", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": "---\ntags: [old, new]\n---\n#old"})
+		defer app.Shutdown(context.Background())
+
+		app.RenameTag("old", "new")
+		if got := readVaultFile(t, vault, "n.md"); got != "---\ntags:\n  - new\n---\n#new" {
+			t.Errorf("Unexpected content %q", got)
+		}
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		app, vault := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		app.RenameTag("project", "work")
+		op, err := app.UndoLastFileOperation()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if op.Kind != internal.OpRenameTag {
+			t.Errorf("Expected a tag rename, got %+v", op)
+		}
+		if got := readVaultFile(t, vault, "c.md"); got != "Just #Project and issue #123" {
+			t.Errorf("Expected the note restored, got %q", got)
+		}
+		if _, err := app.RedoFileOperation(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := readVaultFile(t, vault, "c.md"); got != "Just #work and issue #123" {
+			t.Errorf("Expected the rename again, got %q", got)
+		}
+	})
+
+	t.Run("queued saves are kept and editors reload", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		app.WriteFile("n.md", "#old")
+		app.QueueSave("n.md", "#old and more")
+		if _, err := app.RenameTag("old", "new"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		app.FlushSaves()
+		if got := readVaultFile(t, vault, "n.md"); got != "#new and more" {
+			t.Errorf("Unexpected content %q", got)
+		}
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "n.md" {
+			t.Errorf("Expected a change of n.md, got %+v", e)
+		}
+	})
+
+	t.Run("invalid tags", func(t *testing.T) {
+		app, _ := tagVault(t)
+		defer app.Shutdown(context.Background())
+
+		for _, pair := range [][2]string{{"", "x"}, {"project", "two words"}, {"project", "123"}, {"project", "a//b"}} {
+			if _, err := app.RenameTag(pair[0], pair[1]); !errors.Is(err, internal.ErrInvalidTag) {
+				t.Errorf("RenameTag(%q, %q): expected ErrInvalidTag, got %v", pair[0], pair[1], err)
+			}
+		}
+	})
+
+	t.Run("read-only vault", func(t *testing.T) {
+		holder, vault := tagVault(t)
+		defer holder.Shutdown(context.Background())
+		app := &internal.App{}
+		app.OpenVaultReadOnly(vault)
+		defer app.Shutdown(context.Background())
+
+		if _, err := app.RenameTag("project", "work"); !errors.Is(err, internal.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+	})
+}
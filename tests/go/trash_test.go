@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func vaultTrashApp(t *testing.T, files map[string]string) (*internal.App, string) {
+	app, vault := writeVault(t, files)
+	if err := app.SetTrashSettings(internal.TrashSettings{Mode: "vault", RetentionDays: 30}); err != nil {
+		t.Fatalf("Failed to enable vault trash: %v", err)
+	}
+	return app, vault
+}
+
+func TestTrashSettings(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.GetTrashSettings(); err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("defaults to system trash", func(t *testing.T) {
+		app, _ := writeVault(t, nil)
+
+		settings, err := app.GetTrashSettings()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if settings.Mode != "system" || settings.RetentionDays != 30 {
+			t.Errorf("Unexpected defaults %+v", settings)
+		}
+	})
+
+	t.Run("persists per vault", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, nil)
+		app.Shutdown(context.Background())
+
+		reopened := &internal.App{}
+		reopened.OpenVault(vault)
+		settings, _ := reopened.GetTrashSettings()
+		if settings.Mode != "vault" {
+			t.Errorf("Expected vault mode after reopening, got %q", settings.Mode)
+		}
+	})
+
+	t.Run("rejects invalid settings", func(t *testing.T) {
+		app, _ := writeVault(t, nil)
+
+		if err := app.SetTrashSettings(internal.TrashSettings{Mode: "shredder"}); err == nil {
+			t.Error("Expected error for unknown mode")
+		}
+		if err := app.SetTrashSettings(internal.TrashSettings{Mode: "vault", RetentionDays: -1}); err == nil {
+			t.Error("Expected error for negative retention")
+		}
+	})
+}
+
+func TestVaultTrash(t *testing.T) {
+	t.Run("delete moves into vault trash", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"notes/a.md": "alpha"})
+
+		if err := app.DeleteFile(filepath.Join("notes", "a.md")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(vault, "notes", "a.md")); !os.IsNotExist(err) {
+			t.Error("File should be gone from its folder")
+		}
+
+		items, err := app.ListTrash()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(items) != 1 || items[0].Name != "a.md" || items[0].OriginalPath != filepath.Join("notes", "a.md") {
+			t.Fatalf("Unexpected trash items %+v", items)
+		}
+
+		contents, _ := app.ListVaultContents()
+		for _, f := range contents {
+			if f.Name == "a.md" {
+				t.Error("Trashed file should not be listed in the vault")
+			}
+		}
+	})
+
+	t.Run("restore recreates missing folders", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"deep/dir/a.md": "alpha"})
+
+		app.DeleteFile(filepath.Join("deep", "dir", "a.md"))
+		os.RemoveAll(filepath.Join(vault, "deep"))
+
+		items, _ := app.ListTrash()
+		restored, err := app.RestoreFromTrash(items[0].ID)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if restored != filepath.Join("deep", "dir", "a.md") {
+			t.Errorf("Unexpected restored path %q", restored)
+		}
+		if got := readVaultFile(t, vault, "deep/dir/a.md"); got != "alpha" {
+			t.Errorf("Expected restored content, got %q", got)
+		}
+
+		items, _ = app.ListTrash()
+		if len(items) != 0 {
+			t.Errorf("Expected empty trash after restore, got %d items", len(items))
+		}
+	})
+
+	t.Run("restore avoids name collisions", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"a.md": "old"})
+
+		app.DeleteFile("a.md")
+		os.WriteFile(filepath.Join(vault, "a.md"), []byte("new"), 0644)
+
+		items, _ := app.ListTrash()
+		restored, err := app.RestoreFromTrash(items[0].ID)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if restored != "a 1.md" {
+			t.Errorf("Expected 'a 1.md', got %q", restored)
+		}
+		if readVaultFile(t, vault, "a.md") != "new" || readVaultFile(t, vault, "a 1.md") != "old" {
+			t.Error("Existing file should be kept and restored file renamed")
+		}
+	})
+
+	t.Run("restores folders", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"dir/a.md": "a", "dir/sub/b.md": "b"})
+
+		app.DeleteFile("dir")
+		items, _ := app.ListTrash()
+		if len(items) != 1 || !items[0].IsDir {
+			t.Fatalf("Expected one folder in trash, got %+v", items)
+		}
+
+		app.RestoreFromTrash(items[0].ID)
+		if readVaultFile(t, vault, "dir/sub/b.md") != "b" {
+			t.Error("Folder contents should be restored")
+		}
+	})
+
+	t.Run("unknown item", func(t *testing.T) {
+		app, _ := vaultTrashApp(t, nil)
+
+		if _, err := app.RestoreFromTrash("../../etc"); err == nil {
+			t.Error("Expected error for invalid id")
+		}
+	})
+
+	t.Run("empty trash", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"a.md": "a", "b.md": "b"})
+
+		app.DeleteFile("a.md")
+		app.DeleteFile("b.md")
+		if err := app.EmptyTrash(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		items, _ := app.ListTrash()
+		if len(items) != 0 {
+			t.Errorf("Expected empty trash, got %d items", len(items))
+		}
+		if _, err := os.Stat(filepath.Join(vault, ".trash")); !os.IsNotExist(err) {
+			t.Error("Trash folder should be removed")
+		}
+	})
+
+	t.Run("purges old items on open", func(t *testing.T) {
+		app, vault := vaultTrashApp(t, map[string]string{"a.md": "a", "b.md": "b"})
+
+		app.DeleteFile("a.md")
+		app.DeleteFile("b.md")
+		items, _ := app.ListTrash()
+		old := filepath.Join(vault, ".trash", items[1].ID+".json")
+		os.WriteFile(old, []byte(`{"originalPath":"a.md","deletedAt":"2001-01-01T00:00:00Z"}`), 0644)
+		app.Shutdown(context.Background())
+
+		reopened := &internal.App{}
+		reopened.OpenVault(vault)
+
+		items, _ = reopened.ListTrash()
+		if len(items) != 1 || items[0].Name != "b.md" {
+			t.Errorf("Expected only the recent item to remain, got %+v", items)
+		}
+		if _, err := os.Stat(filepath.Join(vault, ".trash", filepath.Base(old[:len(old)-5]))); !os.IsNotExist(err) {
+			t.Error("Purged item contents should be deleted")
+		}
+	})
+}
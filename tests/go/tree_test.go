@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"chalkmd/internal"
+)
+
+func pageNames(page internal.DirectoryPage) []string {
+	names := []string{}
+	for _, e := range page.Entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestListDirectory(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.ListDirectory("", "", 0); !errors.Is(err, internal.ErrNoVault) {
+			t.Errorf("Expected ErrNoVault, got %v", err)
+		}
+	})
+
+	t.Run("folders first, then files by name", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"b.md":          "",
+			"A.md":          "",
+			"zeta/n.md":     "",
+			"Alpha/n.md":    "",
+			".hidden.md":    "",
+			"Alpha/deep.md": "",
+		})
+		defer app.Shutdown(context.Background())
+
+		page, err := app.ListDirectory("", "", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := fmt.Sprint(pageNames(page)); got != "[Alpha zeta A.md b.md]" {
+			t.Errorf("Unexpected entries %s", got)
+		}
+		if page.NextCursor != "" || page.Token == "" {
+			t.Errorf("Unexpected cursor %q or token %q", page.NextCursor, page.Token)
+		}
+
+		page, err = app.ListDirectory("Alpha", "", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := fmt.Sprint(pageNames(page)); got != "[deep.md n.md]" {
+			t.Errorf("Unexpected entries %s", got)
+		}
+		if page.Entries[0].Path != filepath.Join("Alpha", "deep.md") {
+			t.Errorf("Expected paths relative to the vault, got %q", page.Entries[0].Path)
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		files := map[string]string{}
+		for i := 0; i < 7; i++ {
+			files[fmt.Sprintf("n%d.md", i)] = ""
+		}
+		app, _ := writeVault(t, files)
+		defer app.Shutdown(context.Background())
+
+		var names []string
+		cursor, pages := "", 0
+		for {
+			page, err := app.ListDirectory("", cursor, 3)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			names = append(names, pageNames(page)...)
+			pages++
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if pages != 3 || fmt.Sprint(names) != "[n0.md n1.md n2.md n3.md n4.md n5.md n6.md]" {
+			t.Errorf("Unexpected %d pages of %v", pages, names)
+		}
+	})
+
+	t.Run("invalid folders", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"n.md": ""})
+		defer app.Shutdown(context.Background())
+
+		if _, err := app.ListDirectory("missing", "", 0); err == nil {
+			t.Error("Expected error for a missing folder")
+		}
+		if _, err := app.ListDirectory("../", "", 0); err == nil {
+			t.Error("Expected error for a folder outside the vault")
+		}
+	})
+}
+
+func TestGetVaultChangesSince(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		if _, err := app.GetVaultChangesSince(""); !errors.Is(err, internal.ErrNoVault) {
+			t.Errorf("Expected ErrNoVault, got %v", err)
+		}
+	})
+
+	t.Run("unknown tokens reset", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"n.md": ""})
+		defer app.Shutdown(context.Background())
+
+		for _, token := range []string{"", "bogus", "abc-1"} {
+			changes, err := app.GetVaultChangesSince(token)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !changes.Reset || changes.Token == "" {
+				t.Errorf("%q: expected a reset with a new token, got %+v", token, changes)
+			}
+		}
+	})
+
+	t.Run("reports the app's changes", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"old.md": "", "gone.md": ""})
+		defer app.Shutdown(context.Background())
+
+		page, _ := app.ListDirectory("", "", 0)
+		app.CreateFolder("folder")
+		app.WriteFile("folder/new.md", "# New")
+		app.RenameFile("old.md", "renamed.md")
+		app.DeleteFile("gone.md")
+
+		changes, err := app.GetVaultChangesSince(page.Token)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if changes.Reset || changes.Token == page.Token {
+			t.Errorf("Unexpected reset or token in %+v", changes)
+		}
+		updated := map[string]internal.FileInfo{}
+		for _, f := range changes.Updated {
+			updated[f.Path] = f
+		}
+		for _, p := range []string{"folder", filepath.Join("folder", "new.md"), "renamed.md"} {
+			if _, ok := updated[p]; !ok {
+				t.Errorf("Expected %s to be updated, got %+v", p, changes.Updated)
+			}
+		}
+		if !updated["folder"].IsDir || updated[filepath.Join("folder", "new.md")].Stats == nil {
+			t.Errorf("Expected full details, got %+v", changes.Updated)
+		}
+		if fmt.Sprint(changes.Removed) != "[gone.md old.md]" {
+			t.Errorf("Unexpected removed paths %v", changes.Removed)
+		}
+
+		again, _ := app.GetVaultChangesSince(changes.Token)
+		if again.Reset || len(again.Updated) != 0 || len(again.Removed) != 0 || again.Token != changes.Token {
+			t.Errorf("Expected no further changes, got %+v", again)
+		}
+	})
+
+	t.Run("tokens belong to one scan", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"n.md": ""})
+		defer app.Shutdown(context.Background())
+
+		page, _ := app.ListDirectory("", "", 0)
+		if err := app.OpenVault(vault); err != nil {
+			t.Fatalf("Failed to reopen vault: %v", err)
+		}
+		changes, _ := app.GetVaultChangesSince(page.Token)
+		if !changes.Reset {
+			t.Errorf("Expected a reset after the vault is scanned again, got %+v", changes)
+		}
+	})
+}
+
+func TestVaultScanProgress(t *testing.T) {
+	vault := t.TempDir()
+	for d := 0; d < 40; d++ {
+		dir := filepath.Join(vault, fmt.Sprintf("d%d", d))
+		os.Mkdir(dir, 0755)
+		for f := 0; f < 100; f++ {
+			os.WriteFile(filepath.Join(dir, fmt.Sprintf("n%d.md", f)), nil, 0644)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		progress []internal.ScanProgress
+	)
+	app := &internal.App{}
+	internal.ListenForEvents(app, func(name string, data ...interface{}) {
+		if p, ok := data[0].(internal.ScanProgress); ok && name == internal.EventVaultScanProgress {
+			mu.Lock()
+			progress = append(progress, p)
+			mu.Unlock()
+		}
+	})
+	if err := app.OpenVault(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer app.Shutdown(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) < 5 || !progress[len(progress)-1].Done || progress[len(progress)-1].Scanned != 4040 {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i].Scanned < progress[i-1].Scanned+1000 && !progress[i].Done {
+			t.Errorf("Progress went from %d to %d", progress[i-1].Scanned, progress[i].Scanned)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package tests
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 	"chalkmd/internal"
 )
 
@@ -132,4 +134,84 @@ func TestListVaultContents(t *testing.T) {
 			t.Errorf("Expected 1 visible file, got %d", len(files))
 		}
 	})
+
+	t.Run("file details", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{
+			"Note.md":       "---\ntitle: x\n---\n```\n# not this\n```\n## First heading ##\nSome words here",
+			"img/Photo.PNG": "png",
+			"paper.pdf":     "pdf",
+			"board.canvas":  "{}",
+			"song.mp3":      "mp3",
+			"data.bin":      "??",
+		})
+		defer app.Shutdown(context.Background())
+
+		files, err := app.ListVaultContents()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		byPath := make(map[string]internal.FileInfo)
+		for _, f := range files {
+			byPath[filepath.ToSlash(f.Path)] = f
+		}
+
+		kinds := map[string][3]string{
+			"Note.md":       {internal.FileKindNote, "md", "text/markdown"},
+			"img":           {internal.FileKindFolder, "", ""},
+			"img/Photo.PNG": {internal.FileKindImage, "png", "image/png"},
+			"paper.pdf":     {internal.FileKindPDF, "pdf", "application/pdf"},
+			"board.canvas":  {internal.FileKindCanvas, "canvas", "application/json"},
+			"song.mp3":      {internal.FileKindAudio, "mp3", "audio/mpeg"},
+			"data.bin":      {internal.FileKindOther, "bin", "application/octet-stream"},
+		}
+		for path, want := range kinds {
+			f := byPath[path]
+			if f.Kind != want[0] || f.Extension != want[1] || f.MimeType != want[2] {
+				t.Errorf("%s: unexpected kind %q, extension %q, type %q", path, f.Kind, f.Extension, f.MimeType)
+			}
+		}
+
+		note := byPath["Note.md"]
+		if note.Size != int64(len("---\ntitle: x\n---\n```\n# not this\n```\n## First heading ##\nSome words here")) || note.Created == "" {
+			t.Errorf("Unexpected size or creation time %+v", note)
+		}
+		body := "```\n# not this\n```\n## First heading ##\nSome words here"
+		if note.Stats == nil || note.Stats.Words != 7 || note.Stats.Characters != len(body) || note.Stats.Heading != "First heading" {
+			t.Errorf("Unexpected stats %+v", note.Stats)
+		}
+		if byPath["paper.pdf"].Stats != nil {
+			t.Error("Only notes should have stats")
+		}
+	})
+
+	t.Run("stats follow edits", func(t *testing.T) {
+		app, _ := writeVault(t, map[string]string{"Note.md": "# Old\none two"})
+		defer app.Shutdown(context.Background())
+
+		app.ListVaultContents()
+		app.WriteFile("Note.md", "# New\none two three")
+		files, _ := app.ListVaultContents()
+		if len(files) != 1 || files[0].Stats == nil || files[0].Stats.Words != 4 || files[0].Stats.Heading != "New" {
+			t.Errorf("Expected stats of the new content, got %+v", files)
+		}
+	})
+
+	t.Run("creation time survives saves and renames", func(t *testing.T) {
+		app, vault := writeVault(t, map[string]string{"Note.md": "first"})
+
+		files, _ := app.ListVaultContents()
+		created := files[0].Created
+		time.Sleep(1100 * time.Millisecond)
+		app.WriteFile("Note.md", "second")
+		app.RenameFile("Note.md", "Renamed.md")
+		app.WriteFile("Renamed.md", "third")
+		app.Shutdown(context.Background())
+
+		app.OpenVault(vault)
+		defer app.Shutdown(context.Background())
+		files, _ = app.ListVaultContents()
+		if len(files) != 1 || files[0].Created != created {
+			t.Errorf("Expected creation time %s to be kept, got %+v", created, files)
+		}
+	})
 }
\ No newline at end of file
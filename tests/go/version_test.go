@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"chalkmd/internal"
+)
+
+func TestReadFileWithVersion(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.ReadFileWithVersion("test.md")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("returns content and version", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("hello"), 0644)
+
+		file, err := app.ReadFileWithVersion("note.md")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if file.Content != "hello" {
+			t.Errorf("Expected 'hello', got '%s'", file.Content)
+		}
+		if file.Version == "" {
+			t.Error("Expected a version token")
+		}
+	})
+
+	t.Run("version changes with content", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		filePath := filepath.Join(tempDir, "note.md")
+		os.WriteFile(filePath, []byte("one"), 0644)
+		first, _ := app.ReadFileWithVersion("note.md")
+
+		os.WriteFile(filePath, []byte("two"), 0644)
+		second, _ := app.ReadFileWithVersion("note.md")
+
+		if first.Version == second.Version {
+			t.Error("Expected version to change when content changes")
+		}
+	})
+}
+
+func TestWriteFileIfUnchanged(t *testing.T) {
+	t.Run("no vault opened", func(t *testing.T) {
+		app := &internal.App{}
+		_, err := app.WriteFileIfUnchanged("test.md", "content", "")
+		if err == nil {
+			t.Error("Expected error when no vault is opened")
+		}
+	})
+
+	t.Run("saves when version matches", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		filePath := filepath.Join(tempDir, "note.md")
+		os.WriteFile(filePath, []byte("original"), 0644)
+		file, _ := app.ReadFileWithVersion("note.md")
+
+		result, err := app.WriteFileIfUnchanged("note.md", "mine", file.Version)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Conflict != nil {
+			t.Fatalf("Expected no conflict, got %v", result.Conflict)
+		}
+		if result.Version == "" || result.Version == file.Version {
+			t.Error("Expected a new version token after save")
+		}
+
+		readContent, _ := os.ReadFile(filePath)
+		if string(readContent) != "mine" {
+			t.Errorf("Expected 'mine', got '%s'", string(readContent))
+		}
+	})
+
+	t.Run("chained saves using returned version", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		result, err := app.WriteFileIfUnchanged("note.md", "first", "")
+		if err != nil || result.Conflict != nil {
+			t.Fatalf("Expected first save to succeed, got %v %v", err, result.Conflict)
+		}
+
+		result, err = app.WriteFileIfUnchanged("note.md", "second", result.Version)
+		if err != nil || result.Conflict != nil {
+			t.Fatalf("Expected second save to succeed, got %v %v", err, result.Conflict)
+		}
+		if file, _ := app.ReadFileWithVersion("note.md"); file.Version != result.Version {
+			t.Errorf("Expected the saved version %s to be read back, got %s", result.Version, file.Version)
+		}
+	})
+
+	t.Run("conflict when changed externally", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		filePath := filepath.Join(tempDir, "note.md")
+		os.WriteFile(filePath, []byte("original"), 0644)
+		file, _ := app.ReadFileWithVersion("note.md")
+
+		os.WriteFile(filePath, []byte("theirs"), 0644)
+
+		result, err := app.WriteFileIfUnchanged("note.md", "mine", file.Version)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Conflict == nil {
+			t.Fatal("Expected a conflict")
+		}
+		if result.Conflict.CurrentContent != "theirs" {
+			t.Errorf("Expected on-disk content 'theirs', got '%s'", result.Conflict.CurrentContent)
+		}
+
+		readContent, _ := os.ReadFile(filePath)
+		if string(readContent) != "theirs" {
+			t.Error("File should not be overwritten on conflict")
+		}
+	})
+
+	t.Run("conflict when deleted externally", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		filePath := filepath.Join(tempDir, "note.md")
+		os.WriteFile(filePath, []byte("original"), 0644)
+		file, _ := app.ReadFileWithVersion("note.md")
+
+		os.Remove(filePath)
+
+		result, _ := app.WriteFileIfUnchanged("note.md", "mine", file.Version)
+		if result.Conflict == nil || !result.Conflict.Deleted {
+			t.Error("Expected a deleted conflict")
+		}
+	})
+
+	t.Run("conflict when creating over existing file", func(t *testing.T) {
+		app := &internal.App{}
+		tempDir := t.TempDir()
+		app.OpenVault(tempDir)
+
+		os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("exists"), 0644)
+
+		result, _ := app.WriteFileIfUnchanged("note.md", "mine", "")
+		if result.Conflict == nil {
+			t.Error("Expected a conflict when file already exists")
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"chalkmd/internal"
+)
+
+type vaultEvent struct {
+	name  string
+	event internal.VaultEvent
+}
+
+// watchedVault opens vault with a listener and returns the vault events it
+// receives.
+func watchedVault(t *testing.T, vault string) (*internal.App, chan vaultEvent) {
+	t.Helper()
+	events := make(chan vaultEvent, 100)
+	app := &internal.App{}
+	internal.ListenForEvents(app, func(name string, data ...interface{}) {
+		if !strings.HasPrefix(name, "vault:file-") || len(data) == 0 {
+			return
+		}
+		if event, ok := data[0].(internal.VaultEvent); ok {
+			events <- vaultEvent{name, event}
+		}
+	})
+	if err := app.OpenVault(vault); err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	return app, events
+}
+
+// nextEvent waits for the next vault event, failing after a few seconds.
+func nextEvent(t *testing.T, events chan vaultEvent) vaultEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event received")
+	}
+	return vaultEvent{}
+}
+
+// noEvent checks that no vault event arrives for a while.
+func noEvent(t *testing.T, events chan vaultEvent) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Errorf("Unexpected event %+v", e)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestVaultWatcher(t *testing.T) {
+	t.Run("external changes", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		note := filepath.Join(vault, "note.md")
+		os.WriteFile(note, []byte("one"), 0644)
+		if e := nextEvent(t, events); e.name != internal.EventFileCreated || e.event.Path != "note.md" {
+			t.Errorf("Expected a create of note.md, got %+v", e)
+		}
+
+		os.WriteFile(note, []byte("two"), 0644)
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "note.md" {
+			t.Errorf("Expected a change of note.md, got %+v", e)
+		}
+
+		os.Rename(note, filepath.Join(vault, "renamed.md"))
+		e := nextEvent(t, events)
+		if e.name != internal.EventFileRenamed || e.event.Path != "renamed.md" || e.event.OldPath != "note.md" {
+			t.Errorf("Expected a rename to renamed.md, got %+v", e)
+		}
+
+		os.Remove(filepath.Join(vault, "renamed.md"))
+		if e := nextEvent(t, events); e.name != internal.EventFileDeleted || e.event.Path != "renamed.md" {
+			t.Errorf("Expected a delete of renamed.md, got %+v", e)
+		}
+	})
+
+	t.Run("external changes update the indexes", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		os.WriteFile(filepath.Join(vault, "note.md"), []byte("pineapple"), 0644)
+		nextEvent(t, events)
+		if results, _ := app.SearchVault("pineapple", internal.SearchOptions{}); len(results) != 1 {
+			t.Errorf("Expected the search index to be updated, got %+v", results)
+		}
+	})
+
+	t.Run("bursts are coalesced", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		note := filepath.Join(vault, "note.md")
+		for _, content := range []string{"a", "ab", "abc"} {
+			os.WriteFile(note, []byte(content), 0644)
+		}
+		if e := nextEvent(t, events); e.name != internal.EventFileCreated {
+			t.Errorf("Expected one create, got %+v", e)
+		}
+		noEvent(t, events)
+	})
+
+	t.Run("a note created and removed at once is not reported", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		note := filepath.Join(vault, "note.md")
+		os.WriteFile(note, []byte("one"), 0644)
+		os.Remove(note)
+		noEvent(t, events)
+	})
+
+	t.Run("a note removed and created again is a change", func(t *testing.T) {
+		vault := t.TempDir()
+		note := filepath.Join(vault, "note.md")
+		os.WriteFile(note, []byte("one"), 0644)
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		os.Remove(note)
+		os.WriteFile(note, []byte("two"), 0644)
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "note.md" {
+			t.Errorf("Expected a change of note.md, got %+v", e)
+		}
+		noEvent(t, events)
+	})
+
+	t.Run("an atomic save by another editor is a change", func(t *testing.T) {
+		vault := t.TempDir()
+		note := filepath.Join(vault, "note.md")
+		os.WriteFile(note, []byte("one"), 0644)
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		tmp := filepath.Join(vault, ".note.md.123.tmp")
+		os.WriteFile(tmp, []byte("two"), 0644)
+		os.Rename(tmp, note)
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "note.md" {
+			t.Errorf("Expected a change of note.md, got %+v", e)
+		}
+		noEvent(t, events)
+	})
+
+	t.Run("hidden paths are skipped", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		os.Mkdir(filepath.Join(vault, ".hidden"), 0755)
+		os.WriteFile(filepath.Join(vault, ".hidden", "note.md"), []byte("one"), 0644)
+		os.WriteFile(filepath.Join(vault, ".note.md"), []byte("one"), 0644)
+		noEvent(t, events)
+	})
+
+	t.Run("moves into a folder and folder renames", func(t *testing.T) {
+		vault := t.TempDir()
+		os.WriteFile(filepath.Join(vault, "a.md"), []byte("a"), 0644)
+		os.MkdirAll(filepath.Join(vault, "old", "sub"), 0755)
+		os.WriteFile(filepath.Join(vault, "old", "n.md"), []byte("n"), 0644)
+		os.Mkdir(filepath.Join(vault, "dir"), 0755)
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		os.Rename(filepath.Join(vault, "a.md"), filepath.Join(vault, "dir", "a.md"))
+		e := nextEvent(t, events)
+		if e.name != internal.EventFileRenamed || e.event.Path != filepath.Join("dir", "a.md") || e.event.OldPath != "a.md" {
+			t.Errorf("Expected a rename to dir/a.md, got %+v", e)
+		}
+
+		os.Rename(filepath.Join(vault, "old"), filepath.Join(vault, "new"))
+		e = nextEvent(t, events)
+		if e.name != internal.EventFileRenamed || e.event.Path != "new" || e.event.OldPath != "old" {
+			t.Errorf("Expected a rename of the folder, got %+v", e)
+		}
+		noEvent(t, events)
+	})
+
+	t.Run("the app's own changes are not reported", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		app.WriteFile("note.md", "mine")
+		app.CreateFolder("folder")
+		app.RenameFile("note.md", "folder/note.md")
+		noEvent(t, events)
+	})
+
+	t.Run("an external edit right after a save is reported", func(t *testing.T) {
+		vault := t.TempDir()
+		app, events := watchedVault(t, vault)
+		defer app.Shutdown(context.Background())
+
+		app.WriteFile("note.md", "mine")
+		time.Sleep(300 * time.Millisecond)
+		os.WriteFile(filepath.Join(vault, "note.md"), []byte("theirs, and longer"), 0644)
+		if e := nextEvent(t, events); e.name != internal.EventFileChanged || e.event.Path != "note.md" {
+			t.Errorf("Expected a change of note.md, got %+v", e)
+		}
+	})
+}
@@ -1,62 +1,49 @@
-import { useEffect, useRef } from "react";
-import { WriteFile } from "../wailsjs/go/internal/App";
+import { useEffect } from "react";
 import { TabProvider } from "./TabProvider";
 import { VaultProvider, useVault } from "./VaultProvider";
 
 // main pages
 import Start from "./components/start/Start";
 import Editor from "./components/editor/Editor";
-
-// settings, eventually extensions
-import settings from "../../settings.json";
+import VaultInUseDialog from "./components/ui/VaultInUseDialog";
+import RecoveryDialog from "./components/ui/RecoveryDialog";
 
 import "./style.css";
 
 function App() {
-    const { vaultPath, currentFile, content } = useVault();
+    const {
+        vaultPath,
+        currentFile,
+        content,
+        readOnly,
+        vaultInUse,
+        queueSave,
+        stashBuffer,
+        recoverable,
+    } = useVault();
     
-    // Track pending save to prevent race conditions
-    const pendingSaveRef = useRef(null);
-
-    // auto-save 100ms after last change
-    // IMPORTANT: Capture values at effect creation time to prevent race conditions
-    // where switching files causes content to be written to the wrong file
+    // auto-save: the Go save queue combines saves made within the vault's
+    // autoSaveInterval and writes them in the background, so every change is
+    // handed over right away, paired with the file it belongs to
     useEffect(() => {
-        if (!currentFile || !vaultPath) return;
-
-        // Capture values at the moment the effect runs, not when timeout fires
-        const fileToSave = currentFile;
-        const contentToSave = content;
-        
-        // Cancel any pending save for a different file
-        if (pendingSaveRef.current) {
-            clearTimeout(pendingSaveRef.current.timeout);
-        }
+        if (!currentFile || !vaultPath || readOnly) return;
 
-        const timeout = setTimeout(async () => {
-            try {
-                await WriteFile(fileToSave, contentToSave);
-                // Clear the ref after successful save
-                if (pendingSaveRef.current?.file === fileToSave) {
-                    pendingSaveRef.current = null;
-                }
-            } catch (err) {
-                console.error("Auto-save failed:", err, { file: fileToSave });
-            }
-        }, settings.autoSaveInterval || 100);
-        
-        pendingSaveRef.current = { timeout, file: fileToSave };
+        // kept until the save below clears it, in case the app dies first
+        stashBuffer(currentFile, content);
+        Promise.resolve(queueSave(currentFile, content)).catch((err) => {
+            console.error("Auto-save failed:", err, { file: currentFile });
+        });
+    }, [content, currentFile, vaultPath, readOnly]);
 
-        return () => {
-            clearTimeout(timeout);
-        };
-    }, [content, currentFile, vaultPath]);
+    const showRecovery = vaultPath && !vaultInUse && recoverable?.length > 0;
 
-    if (!vaultPath) {
-        return <Start />;
-    }
-
-    return <Editor />;
+    return (
+        <>
+            {vaultPath ? <Editor /> : <Start />}
+            {vaultInUse && <VaultInUseDialog />}
+            {showRecovery && <RecoveryDialog />}
+        </>
+    );
 }
 
 function AppWithProvider() {
@@ -8,6 +8,11 @@ import {
     moveFile as MoveFile,
     deleteFile as DeleteFile,
     readFile,
+    readVersion,
+    watchFileChanges,
+    queueSave,
+    flushSaves,
+    watchSaveStatus,
 } from "./fs/file";
 
 import {
@@ -15,11 +20,26 @@ import {
     createVault,
     openVault as OpenVault,
     selectVaultFolder,
+    listRecentVaults,
+    forgetVault as ForgetVault,
+    getStartupVault,
+    getLaunchRequest,
+    watchLaunchRequests,
+    watchVaultLock,
 } from "./fs/vault";
 
 import { readBinaryFile, writeBinaryFile } from "./fs/assets";
 
-import { spawnInstance } from "./fs/window";
+import { loadVaultConfig, updateVaultConfig, watchVaultConfig } from "./fs/config";
+
+import { spawnInstance, listInstances } from "./fs/window";
+
+import {
+    stashBuffer,
+    listRecoverableBuffers,
+    recoverBuffer as RecoverBuffer,
+    discardRecovery as DiscardRecovery,
+} from "./fs/recovery";
 
 // settings, eventually extensions
 import settings from "../../settings.json";
@@ -40,6 +60,17 @@ export const VaultProvider = ({ children }) => {
     const [currentFile, setCurrentFile] = useState(null);
     const [content, setContent] = useState("");
     const [expandedFolders, setExpandedFolders] = useState(new Set());
+    const [vaultConfig, setVaultConfig] = useState(null);
+    const [recentVaults, setRecentVaults] = useState([]);
+    // note (and heading) to show once a launch request opened its vault
+    const [launchTarget, setLaunchTarget] = useState(null);
+    // the vault another process has open, while the user decides what to do
+    const [vaultInUse, setVaultInUse] = useState(null);
+    const [readOnly, setReadOnly] = useState(false);
+    // latest save status per note, from the Go save queue
+    const [saveStatus, setSaveStatus] = useState({});
+    // notes with unsaved text left by a crash, found when a vault opens
+    const [recoverable, setRecoverable] = useState([]);
 
     // Refs for debouncing and serializing vault reloads
     // This prevents race conditions when multiple file operations happen rapidly
@@ -47,8 +78,17 @@ export const VaultProvider = ({ children }) => {
     const reloadPromiseRef = useRef(null);
     const reloadGenerationRef = useRef(0);
 
-    const openVault = async (x) => {
-        await OpenVault(x, setVaultPath, setFiles);
+    const openVault = async (x, mode = "exclusive") => {
+        try {
+            await OpenVault(x, setVaultPath, setFiles, mode);
+            setReadOnly(mode === "read-only");
+            setVaultInUse(null);
+        } catch (error) {
+            if (error.vaultInUse) {
+                setVaultInUse(error.vaultInUse);
+            }
+            throw error;
+        }
     };
 
     // Debounced and serialized vault reload to prevent race conditions
@@ -116,6 +156,21 @@ export const VaultProvider = ({ children }) => {
         await DeleteFile(x, loadVaultContents);
     };
 
+    // recent vaults are kept by the Go side; localStorage history is the fallback
+    const loadRecentVaults = useCallback(async () => {
+        try {
+            setRecentVaults((await listRecentVaults()) || []);
+        } catch {
+            const history = JSON.parse(localStorage.getItem("vaultHistory")) || [];
+            setRecentVaults(history.map((path) => ({ path, exists: true })));
+        }
+    }, []);
+
+    const forgetVault = async (path) => {
+        await ForgetVault(path);
+        await loadRecentVaults();
+    };
+
     // persist vaultPath to localStorage
     useEffect(() => {
         if (vaultPath) {
@@ -131,13 +186,102 @@ export const VaultProvider = ({ children }) => {
         } else {
             localStorage.removeItem("vaultPath");
         }
+        loadRecentVaults();
+    }, [vaultPath]);
+
+    // per-vault settings from .chalkmd/config.json, kept live
+    useEffect(() => {
+        if (!vaultPath) {
+            setVaultConfig(null);
+            return;
+        }
+
+        loadVaultConfig()
+            .then(setVaultConfig)
+            .catch(() => {});
+
+        const unsubscribe = watchVaultConfig(setVaultConfig);
+        return () => {
+            if (unsubscribe) unsubscribe();
+        };
+    }, [vaultPath]);
+
+    // opens what the app was launched with; returns false if there was nothing
+    const handleLaunchRequest = async () => {
+        const request = await getLaunchRequest();
+        if (!request) return false;
+
+        try {
+            await openVault(request.vault);
+            await loadVaultContents();
+            if (request.file) {
+                setLaunchTarget({ file: request.file, heading: request.heading || "" });
+            }
+        } catch (error) {
+            console.error("Error opening launch request:", error);
+        }
+        return true;
+    };
+
+    useEffect(() => {
+        if (!vaultPath) {
+            setRecoverable([]);
+            return;
+        }
+        Promise.resolve(listRecoverableBuffers()).then((buffers) =>
+            setRecoverable(buffers || [])
+        );
     }, [vaultPath]);
 
+    // writes a note's unsaved text and shows it if the note is open
+    const recoverBuffer = async (path) => {
+        const recovered = await RecoverBuffer(path);
+        setRecoverable((prev) => prev.filter((b) => b.path !== path));
+        if (path === currentFile) {
+            setContent(recovered);
+        }
+    };
+
+    const discardRecovery = async (path) => {
+        await DiscardRecovery(path);
+        setRecoverable((prev) => prev.filter((b) => b.path !== path));
+    };
+
+    useEffect(() => {
+        const unsubscribe = watchSaveStatus((status) =>
+            setSaveStatus((prev) => ({ ...prev, [status.path]: status }))
+        );
+        return () => {
+            if (unsubscribe) unsubscribe();
+        };
+    }, []);
+
+    useEffect(() => {
+        const unsubscribe = watchVaultLock(() => setReadOnly(true));
+        return () => {
+            if (unsubscribe) unsubscribe();
+        };
+    }, []);
+
+    useEffect(() => {
+        const unsubscribe = watchLaunchRequests(handleLaunchRequest);
+        return () => {
+            if (unsubscribe) unsubscribe();
+        };
+    }, []);
+
     // attempt to autoopen vault on startup
     
     useEffect(() => {
         const initializeVault = async () => {
-            const savedPath = localStorage.getItem("vaultPath");
+            if (await handleLaunchRequest()) return;
+
+            // a missing vaultHistory means the webview profile was reset, so
+            // ask the Go side; a cleared vaultPath alone means "show the start page"
+            let savedPath = localStorage.getItem("vaultPath");
+            if (!savedPath && localStorage.getItem("vaultHistory") === null) {
+                savedPath = await getStartupVault();
+            }
             if (savedPath) {
                 try {
                     console.log("Auto-opening saved vault at:", savedPath);
@@ -160,6 +304,15 @@ export const VaultProvider = ({ children }) => {
         loadVaultContents,
         createVault,
         openVault,
+        vaultConfig,
+        updateVaultConfig,
+        recentVaults,
+        forgetVault,
+        launchTarget,
+        setLaunchTarget,
+        vaultInUse,
+        setVaultInUse,
+        readOnly,
     };
 
     const fileMethods = {
@@ -173,6 +326,15 @@ export const VaultProvider = ({ children }) => {
         moveFile,
         deleteFile,
         readFile,
+        readVersion,
+        watchFileChanges,
+        queueSave,
+        flushSaves,
+        saveStatus,
+        stashBuffer,
+        recoverable,
+        recoverBuffer,
+        discardRecovery,
     };
 
     const assetMethods = {
@@ -186,6 +348,7 @@ export const VaultProvider = ({ children }) => {
         content,
         setContent,
         spawnInstance,
+        listInstances,
         ...vaultMethods,
         ...fileMethods,
         ...assetMethods,
@@ -3,12 +3,12 @@ import EditorTitleBar from "./EditorTitleBar";
 import EditorSidebar from "./EditorSidebar";
 import EditorEngine from "./render/EditorEngine";
 import { useVault } from "../../VaultProvider";
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 
 const Editor = () => {
-    const { currentFile, setCurrentFile, setContent, readFile } =
+    const { currentFile, setCurrentFile, content, setContent, readFile, launchTarget, setLaunchTarget, watchFileChanges } =
         useVault();
-    const { loadFileInTab } = useTabContext();
+    const { loadFileInTab, updateTabContent } = useTabContext();
     const [sidebarWidth, setSidebarWidth] = useState(235);
 
     const handleFileClick = async (file) => {
@@ -27,6 +27,38 @@ const Editor = () => {
         }
     };
 
+    // open the note from a launch request; the editor scrolls to its heading
+    useEffect(() => {
+        if (launchTarget && !launchTarget.opened) {
+            handleFileClick({ path: launchTarget.file, isDir: false });
+            setLaunchTarget(launchTarget.heading ? { ...launchTarget, opened: true } : null);
+        }
+    }, [launchTarget]);
+
+    // reload the open note when another window saves it, instead of
+    // overwriting its changes with the next auto-save
+    const openNoteRef = useRef({ currentFile, content });
+    openNoteRef.current = { currentFile, content };
+
+    useEffect(() => {
+        const unsubscribe = watchFileChanges(async (event) => {
+            const { currentFile, content } = openNoteRef.current;
+            if (!event || event.path !== currentFile) return;
+            try {
+                const fileContent = await readFile(currentFile);
+                if (fileContent !== content && openNoteRef.current.currentFile === currentFile) {
+                    setContent(fileContent);
+                    updateTabContent(fileContent);
+                }
+            } catch (error) {
+                console.error("Error reloading file:", error);
+            }
+        });
+        return () => {
+            if (unsubscribe) unsubscribe();
+        };
+    }, [updateTabContent]);
+
     useEffect(() => {
         if (currentFile) {
             const loadFileContent = async () => {
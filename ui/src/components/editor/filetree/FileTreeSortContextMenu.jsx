@@ -65,6 +65,25 @@ const FileTreeSortContextMenu = ({ x, y, onClose, active, setActive }) => {
                     )
                 }
             </div>
+
+            <div className={dividerClass} />
+
+            <div className={itemClass} onClick={() => {setActive("size-desc"); onClose()}}>
+                <span className={`${labelClass}`}>Size (large to small)</span>
+                {
+                    active === "size-desc" && (
+                        <Check size={checkSize} className={checkClass} />
+                    )
+                }
+            </div>
+            <div className={itemClass} onClick={() => {setActive("size-asc"); onClose()}}>
+                <span className={`${labelClass}`}>Size (small to large)</span>
+                {
+                    active === "size-asc" && (
+                        <Check size={checkSize} className={checkClass} />
+                    )
+                }
+            </div>
         </>
     );
 
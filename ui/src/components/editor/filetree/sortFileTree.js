@@ -1,3 +1,6 @@
+// times are RFC3339 strings; created is missing where the OS does not record it
+const time = (value) => Date.parse(value) || 0;
+
 export const sortItems = (items, sortType = 'name-asc') => {
     items.sort((a, b) => {
         if (a.isDir && !b.isDir) return -1;
@@ -39,11 +42,14 @@ export const sortItems = (items, sortType = 'name-asc') => {
             });
             return sortType === 'name-asc' ? comparison : -comparison;
         } else if (sortType.startsWith('created-')) {
-            const comparison = a.created - b.created;
+            const comparison = time(a.created) - time(b.created);
             return sortType === 'created-asc' ? comparison : -comparison;
         } else if (sortType.startsWith('modified-')) {
-            const comparison = a.modified - b.modified;
+            const comparison = time(a.modified) - time(b.modified);
             return sortType === 'modified-asc' ? comparison : -comparison;
+        } else if (sortType.startsWith('size-')) {
+            const comparison = (a.size || 0) - (b.size || 0);
+            return sortType === 'size-asc' ? comparison : -comparison;
         }
 
         return 0;
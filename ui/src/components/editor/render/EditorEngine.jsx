@@ -8,7 +8,7 @@ import { HistoryManager } from "../stores/HistoryManager";
 import EditorInfoWidget from "./EditorInfoWidget";
 
 const EditorEngine = () => {
-    const { content, setContent, currentFile, setCurrentFile, renameFile, readBinaryFile, writeBinaryFile, files, readFile } =
+    const { content, setContent, currentFile, setCurrentFile, renameFile, readBinaryFile, writeBinaryFile, files, readFile, launchTarget, setLaunchTarget, readOnly } =
         useVault();
     const { updateTabContent, loadFileInTab, pushToHistory } = useTabContext();
     const titleInputRef = useRef(null);
@@ -74,6 +74,33 @@ const EditorEngine = () => {
         }
     }, [content, editor]);
 
+    useEffect(() => {
+        if (editor && !editor.isDestroyed) {
+            editor.setEditable(!readOnly);
+        }
+    }, [editor, readOnly]);
+
+    // scroll to the heading a launch request asked for, once its note is loaded
+    useEffect(() => {
+        if (!editor || !launchTarget?.opened || launchTarget.file !== currentFile || content == null) {
+            return;
+        }
+
+        const wanted = launchTarget.heading.trim().toLowerCase();
+        let target = null;
+        editor.state.doc.descendants((node, pos) => {
+            if (target !== null) return false;
+            const match = node.isTextblock && node.textContent.match(/^#{1,6}\s+(.*)$/);
+            if (match && match[1].trim().toLowerCase() === wanted) {
+                target = pos + 1;
+            }
+        });
+        if (target !== null) {
+            editor.chain().setTextSelection(target).scrollIntoView().run();
+        }
+        setLaunchTarget(null);
+    }, [content, editor, launchTarget, currentFile]);
+
     useEffect(() => {
         if (currentFile) {
             const fileName = currentFile
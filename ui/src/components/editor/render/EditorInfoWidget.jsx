@@ -2,7 +2,8 @@ import { GalleryVerticalEnd, PenLine } from "lucide-react";
 import { useVault } from "../../../VaultProvider";
 
 const EditorInfoWidget = () => {
-    const { content } = useVault();
+    const { content, currentFile, saveStatus } = useVault();
+    const status = saveStatus[currentFile];
 
     const wordCount = (text) => {
         if (!text || text.trim() === "") return 0;
@@ -16,6 +17,15 @@ const EditorInfoWidget = () => {
 
     return (
         <div className="fixed right-0 bottom-0 pr-2 pl-4 pb-1 h-7 backdrop-blur-sm rounded-tl-md text-gray-500 border border-[#d3d3d3] text-[12px] flex flex-row items-end justify-center gap-2 z-50">
+            {status?.status === "pending" && (
+                <span className="select-none">Saving…</span>
+            )}
+            {status?.status === "failed" && (
+                <span className="select-none text-red-500" title={status.error}>
+                    Save failed
+                </span>
+            )}
+
             <span className="select-none">0 backlinks</span>
 
             <PenLine size={13} className="inline-block ml-2 mb-1" />
@@ -1,13 +1,11 @@
 import { Node } from "@tiptap/core";
 import { Plugin, PluginKey, TextSelection } from "@tiptap/pm/state";
 import { Decoration, DecorationSet } from "prosemirror-view";
-import settings from "../../../../../../../settings";
-
-const IMAGES_FOLDER = settings.imageFolder || "";
+import { imagePath as vaultImagePath } from "../../../../../fs/config";
 
 async function loadImage(filename, readBinaryFile) {
     try {
-        const imagePath = `${IMAGES_FOLDER}${filename}`;
+        const imagePath = vaultImagePath(filename);
         const base64Data = await readBinaryFile(imagePath);
         if (!base64Data) return null;
 
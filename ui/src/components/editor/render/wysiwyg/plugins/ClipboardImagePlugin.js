@@ -1,6 +1,4 @@
-import settings from "../../../../../../../settings.json";
-
-const IMAGES_FOLDER = settings.imageFolder || "";
+import { imagePath } from "../../../../../fs/config";
 
 function generateImageFilename(extension = "png") {
     const timestamp = Date.now();
@@ -54,7 +52,7 @@ export const handleImagePaste = (writeBinaryFile) => {
                 else if (item.type === "image/webp") extension = "webp";
 
                 const filename = generateImageFilename(extension);
-                const fullPath = `${IMAGES_FOLDER}${filename}`;
+                const fullPath = imagePath(filename);
 
                 console.log("Will save to:", fullPath);
 
@@ -1,9 +1,9 @@
 import {
     Vault,
 } from "lucide-react";
+import { useEffect, useState } from "react";
 import ContextMenu from "../../ui/ContextMenu";
 import { useVault } from "../../../VaultProvider";
-import { useEffect, useState } from "react";
 
 const EditorFooterContextMenu = ({ x, y, onClose }) => {
     const iconSize = 15;
@@ -11,15 +11,16 @@ const EditorFooterContextMenu = ({ x, y, onClose }) => {
         "flex items-center gap-3 rounded-md mx-1 px-2 py-1 hover:bg-black/[0.06] cursor-default font-sans text-[#333333] transition-colors";
     const dividerClass = "border-t border-gray-300 my-1";
     const labelClass = "";
-    const { vaultPath, setVaultPath, spawnInstance: WindowFork } = useVault();
-
-    const [history, setHistory] = useState(
-        JSON.parse(localStorage.getItem("vaultHistory")) || []
-    );
+    const { recentVaults, spawnInstance: WindowFork, listInstances } = useVault();
+    const history = recentVaults.map((vault) => vault.path);
 
+    // vaults another window has open; choosing one brings that window forward
+    const [openElsewhere, setOpenElsewhere] = useState(new Set());
     useEffect(() => {
-        setHistory(JSON.parse(localStorage.getItem("vaultHistory")) || []);
-    }, [vaultPath]);
+        Promise.resolve(listInstances()).then((instances) =>
+            setOpenElsewhere(new Set((instances || []).map((instance) => instance.vault)))
+        );
+    }, []);
 
     const handleNewWindow = () => {
         localStorage.removeItem("vaultPath");
@@ -28,8 +29,7 @@ const EditorFooterContextMenu = ({ x, y, onClose }) => {
     };
 
     const handleOpenVault = (path) => {
-        localStorage.setItem("vaultPath", path);
-        WindowFork();
+        WindowFork(path);
         onClose();
     }
 
@@ -44,6 +44,9 @@ const EditorFooterContextMenu = ({ x, y, onClose }) => {
                     }}
                 >
                     <span className={`${labelClass}`}>{path.split("\\").pop()}</span>
+                    {openElsewhere.has(path) && (
+                        <span className="ml-auto text-xs text-gray-400">open</span>
+                    )}
                 </div>
             ))}
 
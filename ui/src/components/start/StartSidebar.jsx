@@ -1,15 +1,8 @@
-import { useEffect, useState } from "react";
 import { useVault } from "../../VaultProvider";
 
 const StartSidebar = () => {
-    const { vaultPath } = useVault();
-    const [history, setHistory] = useState(
-        JSON.parse(localStorage.getItem("vaultHistory")) || []
-    );
-
-    useEffect(() => {
-        setHistory(JSON.parse(localStorage.getItem("vaultHistory")) || []);
-    }, [vaultPath]);
+    const { recentVaults } = useVault();
+    const history = recentVaults.map((vault) => vault.path);
 
     return (
         <div className="w-[280px] h-screen bg-white border-r border-gray-200 flex flex-col">
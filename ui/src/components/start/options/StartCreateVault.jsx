@@ -5,6 +5,7 @@ import { useVault } from "../../../VaultProvider";
 const StartCreateVault = ({ onBack, onCreate }) => {
     const [vaultName, setVaultName] = useState("");
     const [vaultLocation, setVaultLocation] = useState("");
+    const [template, setTemplate] = useState("empty");
 
     const { selectVaultFolder } = useVault();
 
@@ -28,11 +29,11 @@ const StartCreateVault = ({ onBack, onCreate }) => {
             alert("Please select a vault location");
             return;
         }
-        onCreate(vaultLocation, vaultName);
+        onCreate(vaultLocation, vaultName, template);
     };
 
     return (
-        <div className="w-full px-8 h-72">
+        <div className="w-full px-8 h-80">
             <button
                 onClick={onBack}
                 className="flex items-center gap-2 text-gray-600 hover:text-gray-900 mb-6 transition-colors"
@@ -80,6 +81,27 @@ const StartCreateVault = ({ onBack, onCreate }) => {
                     </button>
                 </div>
 
+                <div className="flex flex-row justify-between w-full">
+                    <div className="flex flex-col items-start">
+                        <span className="block text-[14px] font-medium">
+                            Template
+                        </span>
+                        <span className="block text-[10px] text-gray-500 mb-2">
+                            Start with some folders and notes.
+                        </span>
+                    </div>
+                    <select
+                        value={template}
+                        onChange={(e) => setTemplate(e.target.value)}
+                        className="w-40 px-2 rounded-lg border border-gray-200 focus:border-gray-400 focus:outline-none transition-colors text-[14px]"
+                    >
+                        <option value="empty">Empty</option>
+                        <option value="journal">Journal</option>
+                        <option value="zettelkasten">Zettelkasten</option>
+                        <option value="project-wiki">Project wiki</option>
+                    </select>
+                </div>
+
                 <div className="w-full flex-1">
                     <button
                         onClick={handleCreate}
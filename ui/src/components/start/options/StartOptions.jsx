@@ -4,8 +4,7 @@ import StartCreateVault from "./StartCreateVault";
 import OptionCard from "./OptionCard";
 
 const StartOptions = () => {
-    const { openVault, createVault, selectVaultFolder, setVaultPath } =
-        useVault();
+    const { openVault, createVault, selectVaultFolder } = useVault();
     const [showCreateVault, setShowCreateVault] = useState(false);
 
     const handleOpenVault = async () => {
@@ -15,23 +14,24 @@ const StartOptions = () => {
 
             await openVault(path);
         } catch (error) {
-            alert("Error opening vault: " + error);
+            // a vault in use gets its own dialog
+            if (!error.vaultInUse) {
+                alert("Error opening vault: " + error);
+            }
         }
     };
 
-    const handleCreateVault = async (parentPath, vaultName) => {
+    const handleCreateVault = async (parentPath, vaultName, template) => {
         try {
-            const vaultPath = await createVault(parentPath, vaultName);
-            await openVault(vaultPath, setVaultPath);
-            setVaultPath(vaultPath);
-            await loadVaultContents();
+            const vaultPath = await createVault(parentPath, vaultName, template);
+            await openVault(vaultPath);
         } catch (error) {
             alert("Error creating vault: " + error);
         }
     };
 
     return (
-        <div className="relative w-full h-64 overflow-hidden">
+        <div className="relative w-full h-80 overflow-hidden">
             <div
                 className={`transition-transform duration-300 ease-in-out ${
                     showCreateVault ? "-translate-x-full" : "translate-x-0"
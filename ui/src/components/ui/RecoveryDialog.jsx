@@ -0,0 +1,64 @@
+import ReactDOM from "react-dom";
+import { useVault } from "../../VaultProvider";
+
+// Lists notes with text that was not saved when the app last quit, and
+// lets the user recover or discard each one.
+const RecoveryDialog = () => {
+    const { recoverable, recoverBuffer, discardRecovery } = useVault();
+
+    const handle = async (action, path) => {
+        try {
+            await action(path);
+        } catch (error) {
+            alert("Error: " + error);
+        }
+    };
+
+    return ReactDOM.createPortal(
+        <div className="fixed inset-0 z-[9998] bg-black/20 flex items-center justify-center">
+            <div className="bg-[#F2F2F2] border border-gray-300 shadow-xl rounded-md p-5 w-[420px] text-[12px] text-gray-900 select-none">
+                <span className="block font-semibold text-[14px] mb-2">
+                    Unsaved changes found
+                </span>
+                <span className="block text-gray-600 mb-4">
+                    These notes had changes that were not saved when chalkmd last
+                    quit. Recovering replaces the note; its current text stays in
+                    the version history.
+                </span>
+                <div className="flex flex-col gap-2 max-h-60 overflow-y-auto">
+                    {recoverable.map((buffer) => (
+                        <div
+                            key={buffer.path}
+                            className="flex flex-row items-center justify-between gap-2"
+                        >
+                            <div className="flex flex-col min-w-0">
+                                <span className="truncate">{buffer.path}</span>
+                                <span className="text-[10px] text-gray-500">
+                                    {new Date(buffer.stashed).toLocaleString()}
+                                    {!buffer.modified && " · note was deleted"}
+                                </span>
+                            </div>
+                            <div className="flex flex-row gap-2 shrink-0">
+                                <button
+                                    onClick={() => handle(discardRecovery, buffer.path)}
+                                    className="px-3 py-1 rounded hover:bg-gray-200 transition-colors"
+                                >
+                                    Discard
+                                </button>
+                                <button
+                                    onClick={() => handle(recoverBuffer, buffer.path)}
+                                    className="px-3 py-1 rounded bg-gray-900 text-white hover:bg-gray-700 transition-colors"
+                                >
+                                    Recover
+                                </button>
+                            </div>
+                        </div>
+                    ))}
+                </div>
+            </div>
+        </div>,
+        document.body
+    );
+};
+
+export default RecoveryDialog;
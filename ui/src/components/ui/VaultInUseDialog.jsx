@@ -0,0 +1,58 @@
+import ReactDOM from "react-dom";
+import { useVault } from "../../VaultProvider";
+
+// Shown when the vault to open is locked by another process. The user can
+// open it read-only, take it over, or leave it.
+const VaultInUseDialog = () => {
+    const { vaultInUse, setVaultInUse, openVault } = useVault();
+    const { path, lock } = vaultInUse;
+
+    const open = async (mode) => {
+        try {
+            await openVault(path, mode);
+        } catch (error) {
+            if (!error.vaultInUse) {
+                alert("Error opening vault: " + error);
+            }
+        }
+    };
+
+    return ReactDOM.createPortal(
+        <div className="fixed inset-0 z-[9998] bg-black/20 flex items-center justify-center">
+            <div className="bg-[#F2F2F2] border border-gray-300 shadow-xl rounded-md p-5 w-[380px] text-[12px] text-gray-900 select-none">
+                <span className="block font-semibold text-[14px] mb-2">
+                    Vault is in use
+                </span>
+                <span className="block text-gray-600 mb-1 break-all">{path}</span>
+                <span className="block text-gray-600 mb-4">
+                    {lock
+                        ? `Opened by process ${lock.pid} on ${lock.hostname} since ${new Date(lock.since).toLocaleString()}.`
+                        : "Another process has this vault open."}
+                </span>
+                <div className="flex flex-row justify-end gap-2">
+                    <button
+                        onClick={() => setVaultInUse(null)}
+                        className="px-3 py-1 rounded hover:bg-gray-200 transition-colors"
+                    >
+                        Cancel
+                    </button>
+                    <button
+                        onClick={() => open("take-over")}
+                        className="px-3 py-1 rounded hover:bg-gray-200 transition-colors"
+                    >
+                        Take over
+                    </button>
+                    <button
+                        onClick={() => open("read-only")}
+                        className="px-3 py-1 rounded bg-gray-900 text-white hover:bg-gray-700 transition-colors"
+                    >
+                        Open read-only
+                    </button>
+                </div>
+            </div>
+        </div>,
+        document.body
+    );
+};
+
+export default VaultInUseDialog;
@@ -0,0 +1,53 @@
+import {
+    GetVaultConfig,
+    UpdateVaultConfig,
+} from "../../wailsjs/go/internal/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+
+// defaults match defaultVaultConfig in internal/config.go and are used
+// until a vault is opened
+let currentConfig = {
+    imageFolder: "Z Pasted Images",
+    autoSaveInterval: 100,
+    indentSize: 4,
+};
+
+const setCurrentConfig = (config) => {
+    if (config) {
+        currentConfig = config;
+    }
+    return currentConfig;
+};
+
+const loadVaultConfig = async () => {
+    try {
+        return setCurrentConfig(await GetVaultConfig());
+    } catch (error) {
+        console.error("Error loading vault config:", error);
+        throw error;
+    }
+};
+
+const updateVaultConfig = async (patch) => {
+    try {
+        return setCurrentConfig(await UpdateVaultConfig(patch));
+    } catch (error) {
+        console.error("Error updating vault config:", error);
+        throw error;
+    }
+};
+
+// calls onChange whenever the vault config changes; returns an unsubscribe function
+const watchVaultConfig = (onChange) => {
+    return EventsOn("vault:config-changed", (config) => {
+        onChange(setCurrentConfig(config));
+    });
+};
+
+// vault-relative path of an image in the configured image folder
+const imagePath = (filename) => {
+    const folder = currentConfig.imageFolder;
+    return folder ? `${folder}/${filename}` : filename;
+};
+
+export { loadVaultConfig, updateVaultConfig, watchVaultConfig, imagePath };
@@ -3,9 +3,12 @@ import {
     CreateFolder,
     RenameFile,
     DeleteFile,
-    ReadFile,
+    ReadFileWithVersion,
     MoveFile,
+    QueueSave,
+    FlushSaves,
 } from "../../wailsjs/go/internal/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
 
 const createFile = async (fileName, files, vaultPath, setCurrentFile, loadVaultContents) => {
     try {
@@ -102,14 +105,70 @@ const deleteFile = async (path, loadVaultContents) => {
     }
 };
 
+// version token of the text last read per note, for WriteFileIfUnchanged
+const readVersions = new Map();
+
 const readFile = async (path) => {
     try {
-        const result = await ReadFile(path);
-        return result;
+        const { content, version } = await ReadFileWithVersion(path);
+        readVersions.set(path, version);
+        return content;
     } catch (err) {
         console.error("Failed to read file:", err);
         throw err;
     }
 };
 
-export { createFile, createFolder, renameFile, moveFile, deleteFile, readFile };
\ No newline at end of file
+// returns the version token of the text last read from path, if any
+const readVersion = (path) => readVersions.get(path);
+
+// calls onChange({ path }) when a note is saved by another window or changed
+// outside the app; returns an unsubscribe function
+const watchFileChanges = (onChange) => {
+    const unsubscribers = [
+        EventsOn("instance:file-saved", onChange),
+        EventsOn("vault:file-changed", onChange),
+    ];
+    return () => unsubscribers.forEach((unsubscribe) => unsubscribe && unsubscribe());
+};
+
+// hands content to the Go save queue, which combines rapid saves and writes
+// them in the background
+const queueSave = async (path, content) => {
+    try {
+        await QueueSave(path, content);
+    } catch (err) {
+        console.error("Failed to queue save:", err, { file: path });
+        throw err;
+    }
+};
+
+// writes all queued saves now
+const flushSaves = async () => {
+    try {
+        await FlushSaves();
+    } catch (err) {
+        console.error("Failed to flush saves:", err);
+        throw err;
+    }
+};
+
+// calls onStatus({ path, status, error }) as queued saves go from "pending"
+// to "saved" or "failed"; returns an unsubscribe function
+const watchSaveStatus = (onStatus) => {
+    return EventsOn("file:save-status", onStatus);
+};
+
+export {
+    createFile,
+    createFolder,
+    renameFile,
+    moveFile,
+    deleteFile,
+    readFile,
+    readVersion,
+    watchFileChanges,
+    queueSave,
+    flushSaves,
+    watchSaveStatus,
+};
\ No newline at end of file
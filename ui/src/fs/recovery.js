@@ -0,0 +1,47 @@
+import {
+    StashBuffer,
+    ListRecoverableBuffers,
+    RecoverBuffer,
+    DiscardRecovery,
+} from "../../wailsjs/go/internal/App";
+
+// records the editor's unsaved text so it survives a crash; saving the same
+// text clears it
+const stashBuffer = async (path, content) => {
+    try {
+        await StashBuffer(path, content);
+    } catch (err) {
+        console.error("Failed to stash buffer:", err, { file: path });
+    }
+};
+
+// notes with text that was never saved, from the last session
+const listRecoverableBuffers = async () => {
+    try {
+        return (await ListRecoverableBuffers()) || [];
+    } catch (err) {
+        console.error("Failed to list recoverable buffers:", err);
+        return [];
+    }
+};
+
+// writes the unsaved text to the note and returns it
+const recoverBuffer = async (path) => {
+    try {
+        return await RecoverBuffer(path);
+    } catch (err) {
+        console.error("Failed to recover buffer:", err);
+        throw err;
+    }
+};
+
+const discardRecovery = async (path) => {
+    try {
+        await DiscardRecovery(path);
+    } catch (err) {
+        console.error("Failed to discard recovery:", err);
+        throw err;
+    }
+};
+
+export { stashBuffer, listRecoverableBuffers, recoverBuffer, discardRecovery };
@@ -0,0 +1,25 @@
+import { PreviewReplace, ApplyReplace } from "../../wailsjs/go/internal/App";
+
+// every edit a vault-wide replace would make; options are
+// { regex, caseSensitive, wholeWord, pathGlob, skipCode, skipFrontmatter }
+const previewReplace = async (pattern, replacement, options = {}) => {
+    try {
+        return await PreviewReplace(pattern, replacement, options);
+    } catch (err) {
+        console.error("Failed to preview replace:", err, { pattern });
+        throw err;
+    }
+};
+
+// writes the chosen edits of a preview (all of them if none are given);
+// undone as one operation
+const applyReplace = async (previewId, editIds = []) => {
+    try {
+        return await ApplyReplace(previewId, editIds);
+    } catch (err) {
+        console.error("Failed to apply replace:", err, { previewId });
+        throw err;
+    }
+};
+
+export { previewReplace, applyReplace };
@@ -0,0 +1,37 @@
+import {
+    ListTags,
+    GetNotesWithTag,
+    RenameTag,
+} from "../../wailsjs/go/internal/App";
+
+// the vault's tags as a tree of { tag, name, count, children }
+const listTags = async () => {
+    try {
+        return (await ListTags()) || [];
+    } catch (err) {
+        console.error("Failed to list tags:", err);
+        return [];
+    }
+};
+
+// paths of the notes with a tag, optionally including nested tags
+const getNotesWithTag = async (tag, includeChildren = true) => {
+    try {
+        return (await GetNotesWithTag(tag, includeChildren)) || [];
+    } catch (err) {
+        console.error("Failed to get notes with tag:", err, { tag });
+        return [];
+    }
+};
+
+// rewrites a tag and the tags nested below it in every note
+const renameTag = async (oldTag, newTag) => {
+    try {
+        return await RenameTag(oldTag, newTag);
+    } catch (err) {
+        console.error("Failed to rename tag:", err, { oldTag, newTag });
+        throw err;
+    }
+};
+
+export { listTags, getNotesWithTag, renameTag };
@@ -1,39 +1,147 @@
 import {
     OpenVault,
-    ListVaultContents,
+    CreateVault,
+    ListDirectory,
+    GetVaultChangesSince,
     SelectVaultFolder,
+    ListRecentVaults,
+    ForgetVault,
+    GetStartupVault,
+    GetLaunchRequest,
+    OpenVaultReadOnly,
+    TakeOverVault,
+    GetVaultLock,
 } from "../../wailsjs/go/internal/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
 
+// token of the last listing, to ask the backend only for what changed since
+let contentsToken = "";
+
+// paths in removed are gone along with everything below them
+const applyVaultChanges = (files, { updated, removed }) => {
+    const gone = (path) =>
+        removed.some((r) => path === r || path.startsWith(r + "/") || path.startsWith(r + "\\"));
+    const byPath = new Map(updated.map((f) => [f.path, f]));
+    const kept = files
+        .filter((f) => !gone(f.path))
+        .map((f) => {
+            const next = byPath.get(f.path);
+            byPath.delete(f.path);
+            return next || f;
+        });
+    return [...kept, ...byPath.values()];
+};
+
+// lists every entry of the vault a folder level at a time, calling onLevel
+// with what was found so far after each level, so the top of the tree shows
+// before a large vault is listed; returns the token of the listing
+const listVaultTree = async (onLevel) => {
+    let token = "";
+    const entries = [];
+    let level = [""];
+    while (level.length > 0) {
+        const next = [];
+        for (const folder of level) {
+            let cursor = "";
+            do {
+                const page = await ListDirectory(folder, cursor, 0);
+                token = token || page.token;
+                entries.push(...page.entries);
+                next.push(...page.entries.filter((e) => e.isDir).map((e) => e.path));
+                cursor = page.nextCursor;
+            } while (cursor);
+        }
+        onLevel([...entries]);
+        level = next;
+    }
+    return token;
+};
+
+// lists the whole vault the first time, then applies only what changed
 const loadVaultContents = async (setFiles) => {
     try {
-        const fileList = await ListVaultContents();
-        setFiles(fileList);
+        if (contentsToken) {
+            const changes = await GetVaultChangesSince(contentsToken);
+            contentsToken = changes.token;
+            if (!changes.reset) {
+                setFiles((files) => applyVaultChanges(files, changes));
+                return;
+            }
+        }
+        contentsToken = await listVaultTree(setFiles);
     } catch (error) {
+        contentsToken = "";
         console.error("Error loading vault:", error);
         throw error;
     }
 };
 
-//  TODO: implement createVault flow
-const createVault = async (parentPath, vaultName) => {
+// one page of a folder's entries ("" for the vault root); pass the page's
+// nextCursor to get the next one
+const listDirectory = async (path, cursor = "", limit = 0) => {
     try {
-        // const vaultPath = await CreateVault(parentPath, vaultName);
-        return "";
+        return await ListDirectory(path, cursor, limit);
+    } catch (error) {
+        throw new Error("Error listing folder: " + error);
+    }
+};
+
+// calls onProgress with { scanned, done } while a vault is scanned; returns
+// an unsubscribe function
+const watchVaultScan = (onProgress) => {
+    return EventsOn("vault:scan-progress", onProgress);
+};
+
+// template is "empty", a built-in template name, or the path of a vault to copy
+const createVault = async (parentPath, vaultName, template = "empty") => {
+    try {
+        return await CreateVault(parentPath, vaultName, template);
     } catch (error) {
         throw new Error("Error creating vault: " + error);
     }
 };
 
-const openVault = async (path, setVaultPath, setFiles) => {
+// mode is "exclusive", "read-only" (another process has the vault open) or
+// "take-over" (open it anyway; the other process continues read-only)
+const openVault = async (path, setVaultPath, setFiles, mode = "exclusive") => {
     try {
-        await OpenVault(path);
+        if (mode === "read-only") {
+            await OpenVaultReadOnly(path);
+        } else if (mode === "take-over") {
+            await TakeOverVault(path);
+        } else {
+            await OpenVault(path);
+        }
+        contentsToken = "";
         setVaultPath(path);
         await loadVaultContents(setFiles);
     } catch (error) {
+        // matches ErrVaultInUse in internal/lock.go
+        if (String(error).includes("vault is in use")) {
+            const inUse = new Error("Vault is in use: " + error);
+            inUse.vaultInUse = { path, lock: await getVaultLock(path) };
+            throw inUse;
+        }
         throw new Error("Error opening vault: " + error);
     }
 };
 
+// who holds the vault's lock, or null
+const getVaultLock = async (path) => {
+    try {
+        return await GetVaultLock(path);
+    } catch (error) {
+        console.error("Error getting vault lock:", error);
+        return null;
+    }
+};
+
+// calls onLost when another process took the open vault over; returns an
+// unsubscribe function
+const watchVaultLock = (onLost) => {
+    return EventsOn("vault:lock-lost", onLost);
+};
+
 const selectVaultFolder = async () => {
     try {
         const folderPath = await SelectVaultFolder();
@@ -43,4 +151,58 @@ const selectVaultFolder = async () => {
     }
 };
 
-export { loadVaultContents, createVault, openVault, selectVaultFolder };
+const listRecentVaults = async () => {
+    try {
+        return await ListRecentVaults();
+    } catch (error) {
+        console.error("Error listing recent vaults:", error);
+        throw error;
+    }
+};
+
+const forgetVault = async (path) => {
+    try {
+        await ForgetVault(path);
+    } catch (error) {
+        throw new Error("Error forgetting vault: " + error);
+    }
+};
+
+const getStartupVault = async () => {
+    try {
+        return await GetStartupVault();
+    } catch (error) {
+        console.error("Error getting startup vault:", error);
+        return "";
+    }
+};
+
+// the vault and note passed on the command line or by a chalkmd:// link, or null
+const getLaunchRequest = async () => {
+    try {
+        return await GetLaunchRequest();
+    } catch (error) {
+        console.error("Error getting launch request:", error);
+        return null;
+    }
+};
+
+// calls onRequest when another launch request arrives; returns an unsubscribe function
+const watchLaunchRequests = (onRequest) => {
+    return EventsOn("app:launch-request", onRequest);
+};
+
+export {
+    loadVaultContents,
+    listDirectory,
+    watchVaultScan,
+    createVault,
+    openVault,
+    selectVaultFolder,
+    listRecentVaults,
+    forgetVault,
+    getStartupVault,
+    getLaunchRequest,
+    watchLaunchRequests,
+    watchVaultLock,
+};
@@ -1,14 +1,31 @@
 import {
-    OpenNewInstance
+    OpenNewInstance,
+    OpenVaultInNewInstance,
+    ListInstances,
 } from "../../wailsjs/go/internal/App";
 
-const spawnInstance = async () => {
+// opens a new window, with vaultPath (and file, which may end in #heading) if given
+const spawnInstance = async (vaultPath, file = "") => {
     try {
-        await OpenNewInstance();
+        if (vaultPath) {
+            await OpenVaultInNewInstance(vaultPath, file);
+        } else {
+            await OpenNewInstance();
+        }
     } catch (error) {
         console.error("Error spawning new instance:", error);
         throw error;
     }
 };
 
-export { spawnInstance };
+// other running windows and the vault each has open
+const listInstances = async () => {
+    try {
+        return (await ListInstances()) || [];
+    } catch (error) {
+        console.error("Error listing instances:", error);
+        return [];
+    }
+};
+
+export { spawnInstance, listInstances };
@@ -60,6 +60,10 @@ export function createMockWailsBindings(mockFS) {
       return mockFS.listFiles()
     }),
 
+    ListDirectory: vi.fn(async () => ({ entries: [], nextCursor: '', token: 'mock-0' })),
+
+    GetVaultChangesSince: vi.fn(async () => ({ token: 'mock-0', reset: true, updated: [], removed: [] })),
+
     SelectVault: vi.fn(async () => {
       return '/mock/vault/path'
     }),
@@ -77,6 +81,21 @@ export function createMockWailsBindings(mockFS) {
       return null
     }),
 
+    QueueSave: vi.fn(async (path, content) => {
+      mockFS.addFile(path, content)
+      return null
+    }),
+
+    FlushSaves: vi.fn(async () => null),
+
+    StashBuffer: vi.fn(async () => null),
+
+    ListRecoverableBuffers: vi.fn(async () => []),
+
+    ListTags: vi.fn(async () => []),
+
+    GetNotesWithTag: vi.fn(async () => []),
+
     CreateFile: vi.fn(async (path) => {
       const finalPath = path.endsWith('.md') ? path : `${path}.md`
       mockFS.addFile(finalPath, '')
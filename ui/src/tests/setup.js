@@ -47,6 +47,8 @@ vi.mock('./fs/file.js')
 vi.mock('./fs/vault.js')
 vi.mock('./fs/assets.js')
 vi.mock('./fs/window.js')
+vi.mock('./fs/config.js')
+vi.mock('./fs/recovery.js')
 
 // Mock settings.json
 vi.mock('../settings.json', () => ({